@@ -0,0 +1,262 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+const sessionCookieName = "session"
+
+type Service interface {
+	Register(ctx context.Context, name string, email string, password string) (*Session, error)
+	Login(ctx context.Context, email string, password string) (*Session, error)
+	Logout(ctx context.Context, token string) error
+	GetUserBySession(ctx context.Context, token string) (*User, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+}
+
+type Controller struct {
+	service      Service
+	html         fs.FS
+	csrf         *internal.CSRF
+	secureCookie bool
+}
+
+func NewController(service Service, html fs.FS, csrf *internal.CSRF, secureCookie bool) *Controller {
+	return &Controller{
+		service:      service,
+		html:         html,
+		csrf:         csrf,
+		secureCookie: secureCookie,
+	}
+}
+
+type FormPageData struct {
+	Name      string
+	Email     string
+	Token     string
+	Next      string
+	CSRFToken string
+	Error     string
+	Message   string
+}
+
+// NavData is the logged in user of the header with the token of the logout form, the user is nil for anonymous requests
+type NavData struct {
+	User      *User
+	CSRFToken string
+}
+
+func (c *Controller) LoginPage(w http.ResponseWriter, r *http.Request) {
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error loading login form", http.StatusInternalServerError)
+		return
+	}
+	c.render(w, "account/login.tmpl", FormPageData{Next: safeRedirect(r.URL.Query().Get("next")), CSRFToken: token})
+}
+
+func (c *Controller) Login(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := FormPageData{
+		Email:     r.Form.Get("email"),
+		Next:      safeRedirect(r.Form.Get("next")),
+		CSRFToken: r.Form.Get(internal.CSRFFieldName),
+	}
+	session, err := c.service.Login(r.Context(), data.Email, r.Form.Get("password"))
+	if errors.Is(err, ErrInvalidCredentials) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnauthorized)
+		c.render(w, "account/login.tmpl", data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error logging in: %s", err)
+		data.Error = "Something went wrong, please try again."
+		w.WriteHeader(http.StatusInternalServerError)
+		c.render(w, "account/login.tmpl", data)
+		return
+	}
+	c.setSessionCookie(w, session)
+	http.Redirect(w, r, data.Next, http.StatusSeeOther)
+}
+
+func (c *Controller) RegisterPage(w http.ResponseWriter, _ *http.Request) {
+	c.render(w, "account/register.tmpl", FormPageData{})
+}
+
+func (c *Controller) Register(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := FormPageData{
+		Name:  r.Form.Get("name"),
+		Email: r.Form.Get("email"),
+	}
+	session, err := c.service.Register(r.Context(), data.Name, data.Email, r.Form.Get("password"))
+	if isValidationError(err) || errors.Is(err, ErrEmailTaken) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.render(w, "account/register.tmpl", data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error registering: %s", err)
+		data.Error = "Something went wrong, please try again."
+		w.WriteHeader(http.StatusInternalServerError)
+		c.render(w, "account/register.tmpl", data)
+		return
+	}
+	c.setSessionCookie(w, session)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (c *Controller) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		err = c.service.Logout(r.Context(), cookie.Value)
+		if err != nil {
+			log.Printf("Error logging out: %s", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (c *Controller) ForgotPasswordPage(w http.ResponseWriter, _ *http.Request) {
+	c.render(w, "account/forgotPassword.tmpl", FormPageData{})
+}
+
+func (c *Controller) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := FormPageData{Email: r.Form.Get("email")}
+	err = c.service.RequestPasswordReset(r.Context(), data.Email)
+	if errors.Is(err, ErrInvalidEmail) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.render(w, "account/forgotPassword.tmpl", data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error requesting password reset: %s", err)
+		data.Error = "Something went wrong, please try again."
+		w.WriteHeader(http.StatusInternalServerError)
+		c.render(w, "account/forgotPassword.tmpl", data)
+		return
+	}
+	data.Message = "If an account exists for this email, a link to reset the password has been sent."
+	c.render(w, "account/forgotPassword.tmpl", data)
+}
+
+func (c *Controller) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	c.render(w, "account/resetPassword.tmpl", FormPageData{Token: r.URL.Query().Get("token")})
+}
+
+func (c *Controller) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := FormPageData{Token: r.Form.Get("token")}
+	err = c.service.ResetPassword(r.Context(), data.Token, r.Form.Get("password"))
+	if isValidationError(err) || errors.Is(err, ErrInvalidToken) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.render(w, "account/resetPassword.tmpl", data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error resetting password: %s", err)
+		data.Error = "Something went wrong, please try again."
+		w.WriteHeader(http.StatusInternalServerError)
+		c.render(w, "account/resetPassword.tmpl", data)
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// Nav renders the account links of the header, loaded by htmx so that every page shows the login state
+func (c *Controller) Nav(w http.ResponseWriter, r *http.Request) {
+	data := NavData{User: UserFromContext(r.Context())}
+	if data.User != nil {
+		token, err := c.csrf.Token(w, r)
+		if err != nil {
+			log.Printf("Error creating CSRF token: %s", err)
+			http.Error(w, "Error loading navigation", http.StatusInternalServerError)
+			return
+		}
+		data.CSRFToken = token
+	}
+	tmpl, err := template.ParseFS(c.html, "account/nav.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) render(w http.ResponseWriter, page string, data FormPageData) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) setSessionCookie(w http.ResponseWriter, session *Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   c.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func isValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidName) || errors.Is(err, ErrInvalidEmail) ||
+		errors.Is(err, ErrPasswordTooShort) || errors.Is(err, ErrPasswordTooLong)
+}
+
+// safeRedirect only allows redirecting to a path on this site after logging in
+func safeRedirect(next string) string {
+	if len(next) < 1 || next[0] != '/' || (len(next) > 1 && (next[1] == '/' || next[1] == '\\')) {
+		return "/"
+	}
+	return next
+}
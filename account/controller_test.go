@@ -0,0 +1,95 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+	"github.com/aattwwss/ihf-referee-rules/public"
+)
+
+// fakeService rejects every login
+type fakeService struct {
+	Service
+}
+
+func (s *fakeService) Login(_ context.Context, _ string, _ string) (*Session, error) {
+	return nil, ErrInvalidCredentials
+}
+
+func newTestController(t *testing.T) *Controller {
+	htmlFS, err := public.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewController(&fakeService{}, htmlFS, internal.NewCSRF(false), false)
+}
+
+// csrfField is the hidden field of a form posting the token
+func csrfField(token string) string {
+	return `name="csrf_token" value="` + token + `"`
+}
+
+func TestLoginFormPostsCSRFToken(t *testing.T) {
+	controller := newTestController(t)
+	w := httptest.NewRecorder()
+	controller.LoginPage(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "" {
+		t.Fatalf("login page set cookies %v, want the CSRF token", cookies)
+	}
+	token := cookies[0].Value
+	if !strings.Contains(w.Body.String(), csrfField(token)) {
+		t.Errorf("login form does not post the token %q", token)
+	}
+
+	form := url.Values{"email": {"referee@example.com"}, "password": {"wrong horse"}, internal.CSRFFieldName: {token}}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	controller.Login(w, r)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), csrfField(token)) {
+		t.Errorf("failed login: status %d, want 401 with the form posting the token again", w.Code)
+	}
+}
+
+func TestNavLogoutPostsCSRFToken(t *testing.T) {
+	controller := newTestController(t)
+	r := httptest.NewRequest(http.MethodGet, "/account/nav", nil)
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+	w := httptest.NewRecorder()
+	controller.Nav(w, r.WithContext(WithUser(r.Context(), &User{ID: 1, Name: "Referee", Role: RoleReferee})))
+	if body := w.Body.String(); !strings.Contains(body, `action="/logout"`) || !strings.Contains(body, csrfField("token")) {
+		t.Errorf("nav of a user = %q, want the logout form posting the token", body)
+	}
+
+	w = httptest.NewRecorder()
+	controller.Nav(w, httptest.NewRequest(http.MethodGet, "/account/nav", nil))
+	if body := w.Body.String(); strings.Contains(body, "/logout") || len(w.Result().Cookies()) != 0 {
+		t.Errorf("nav of an anonymous user = %q, want the login link without a token", body)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"", "/"},
+		{"/dashboard", "/dashboard"},
+		{"/sets/abc?x=1", "/sets/abc?x=1"},
+		{"https://example.com", "/"},
+		{"//example.com", "/"},
+		{`/\example.com`, "/"},
+		{"dashboard", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeRedirect(tt.next); got != tt.want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
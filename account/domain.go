@@ -0,0 +1,95 @@
+package account
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidEmail       = errors.New("email is not valid")
+	ErrInvalidName        = errors.New("name is required")
+	ErrPasswordTooShort   = errors.New("password must be at least 8 characters")
+	ErrPasswordTooLong    = errors.New("password must be at most 72 characters")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("token is invalid or expired")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrForbidden          = errors.New("you do not have permission to do this")
+)
+
+type UserEntity struct {
+	ID           int
+	Email        string
+	Name         string
+	PasswordHash string
+	CreatedAt    time.Time
+	Role         Role
+}
+
+type SessionEntity struct {
+	TokenHash string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type PasswordResetEntity struct {
+	TokenHash string
+	UserID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type User struct {
+	ID        int
+	Email     string
+	Name      string
+	CreatedAt time.Time
+	// Role decides what the user can do besides practising, it is only set in the database
+	Role Role
+}
+
+// Can reports whether the user has the permission, anonymous users have none
+func (u *User) Can(permission Permission) bool {
+	return u != nil && u.Role.Can(permission)
+}
+
+// Role is what a user does on the site, new users are referees
+type Role string
+
+const (
+	// RoleAdmin manages the content and the feedback, and can do everything an instructor can
+	RoleAdmin Role = "admin"
+	// RoleInstructor manages classes and question sets
+	RoleInstructor Role = "instructor"
+	// RoleReferee practises, which every logged in user can
+	RoleReferee Role = "referee"
+)
+
+type Permission string
+
+const (
+	// PermissionManageContent is editing the questions and importing editions
+	PermissionManageContent  Permission = "manage_content"
+	PermissionManageFeedback Permission = "manage_feedback"
+	PermissionManageClasses  Permission = "manage_classes"
+	PermissionManageSets     Permission = "manage_sets"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:      {PermissionManageContent, PermissionManageFeedback, PermissionManageClasses, PermissionManageSets},
+	RoleInstructor: {PermissionManageClasses, PermissionManageSets},
+	RoleReferee:    {},
+}
+
+func (r Role) Can(permission Permission) bool {
+	return slices.Contains(rolePermissions[r], permission)
+}
+
+// Session is a logged in session, Token is only known when the session is created as only its hash is stored
+type Session struct {
+	Token     string
+	UserID    int
+	ExpiresAt time.Time
+}
@@ -0,0 +1,83 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type contextKey int
+
+const userContextKey contextKey = iota
+
+// WithUser returns a copy of the context carrying the logged in user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the logged in user of the request, nil for anonymous requests
+func UserFromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(userContextKey).(*User)
+	return user
+}
+
+// Authorize returns ErrForbidden unless the user of the context has the permission. Services check it
+// so that the permissions hold whichever route or caller reaches them.
+func Authorize(ctx context.Context, permission Permission) error {
+	if !UserFromContext(ctx).Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Session loads the user of the session cookie into the request context.
+// Requests without a valid session continue anonymously.
+func (c *Controller) Session(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || cookie.Value == "" || strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := c.service.GetUserBySession(r.Context(), cookie.Value)
+		if err != nil {
+			if !errors.Is(err, ErrUserNotFound) {
+				log.Printf("Error getting session user: %s", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), user)))
+	})
+}
+
+// RequireUser redirects anonymous requests to the login page, returning to the requested page after logging in
+func RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if UserFromContext(r.Context()) != nil {
+			next(w, r)
+			return
+		}
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", "/login")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	}
+}
+
+// RequirePermission only lets users with the permission through, other users are forbidden
+// and anonymous requests are sent to the login page
+func RequirePermission(permission Permission, next http.HandlerFunc) http.HandlerFunc {
+	return RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		if !UserFromContext(r.Context()).Can(permission) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,78 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *User
+		allowed []Permission
+	}{
+		{"anonymous", nil, nil},
+		{"referee", &User{ID: 1, Role: RoleReferee}, nil},
+		{"instructor", &User{ID: 1, Role: RoleInstructor}, []Permission{PermissionManageClasses, PermissionManageSets}},
+		{"admin", &User{ID: 1, Role: RoleAdmin}, []Permission{PermissionManageContent, PermissionManageFeedback, PermissionManageClasses, PermissionManageSets}},
+		{"unknown role", &User{ID: 1, Role: "owner"}, nil},
+	}
+	permissions := []Permission{PermissionManageContent, PermissionManageFeedback, PermissionManageClasses, PermissionManageSets}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithUser(context.Background(), tt.user)
+			for _, permission := range permissions {
+				want := false
+				for _, allowed := range tt.allowed {
+					want = want || allowed == permission
+				}
+				if got := tt.user.Can(permission); got != want {
+					t.Errorf("Can(%s) = %t, want %t", permission, got, want)
+				}
+				err := Authorize(ctx, permission)
+				if want && err != nil || !want && !errors.Is(err, ErrForbidden) {
+					t.Errorf("Authorize(%s) error = %v, want allowed %t", permission, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	tests := []struct {
+		name         string
+		user         *User
+		htmx         bool
+		wantStatus   int
+		wantLocation string
+	}{
+		{"anonymous", nil, false, http.StatusSeeOther, "/login?next=%2Fsets%3Fpage%3D2"},
+		{"anonymous htmx", nil, true, http.StatusUnauthorized, ""},
+		{"referee", &User{ID: 1, Role: RoleReferee}, false, http.StatusForbidden, ""},
+		{"instructor", &User{ID: 1, Role: RoleInstructor}, false, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/sets?page=2", nil)
+			r = r.WithContext(WithUser(r.Context(), tt.user))
+			if tt.htmx {
+				r.Header.Set("HX-Request", "true")
+			}
+			w := httptest.NewRecorder()
+			RequirePermission(PermissionManageSets, next)(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if tt.htmx && w.Header().Get("HX-Redirect") != "/login" {
+				t.Errorf("HX-Redirect = %q, want /login", w.Header().Get("HX-Redirect"))
+			}
+		})
+	}
+}
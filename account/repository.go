@@ -0,0 +1,138 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the postgres error code raised when a unique constraint is violated
+const uniqueViolation = "23505"
+
+type AccountRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *AccountRepository {
+	return &AccountRepository{
+		db: db,
+	}
+}
+
+func (r *AccountRepository) InsertUser(ctx context.Context, email string, name string, passwordHash string) (*User, error) {
+	query := fmt.Sprintf(`INSERT INTO "user" (email, name, password_hash) VALUES ($1, $2, $3) RETURNING id, email, name, password_hash, created_at, role`)
+	rows, err := r.db.Query(ctx, query, email, name, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	userEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[UserEntity])
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return nil, ErrEmailTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toUser(userEntity), nil
+}
+
+// FindUserEntityByEmail returns the user entity including the password hash, for verifying credentials
+func (r *AccountRepository) FindUserEntityByEmail(ctx context.Context, email string) (*UserEntity, error) {
+	query := fmt.Sprintf(`SELECT id, email, name, password_hash, created_at, role FROM "user" WHERE email = $1`)
+	rows, err := r.db.Query(ctx, query, email)
+	if err != nil {
+		return nil, err
+	}
+	userEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[UserEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &userEntity, nil
+}
+
+func (r *AccountRepository) UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error {
+	query := fmt.Sprintf(`UPDATE "user" SET password_hash = $2 WHERE id = $1`)
+	_, err := r.db.Exec(ctx, query, userID, passwordHash)
+	return err
+}
+
+func (r *AccountRepository) InsertSession(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error {
+	query := fmt.Sprintf("INSERT INTO session (token_hash, user_id, expires_at) VALUES ($1, $2, $3)")
+	_, err := r.db.Exec(ctx, query, tokenHash, userID, expiresAt)
+	return err
+}
+
+// FindUserBySessionTokenHash returns the user of an unexpired session
+func (r *AccountRepository) FindUserBySessionTokenHash(ctx context.Context, tokenHash string) (*User, error) {
+	query := fmt.Sprintf(`
+		SELECT u.id, u.email, u.name, u.password_hash, u.created_at, u.role
+		FROM session s JOIN "user" u ON s.user_id = u.id
+		WHERE s.token_hash = $1 AND s.expires_at > now()
+	`)
+	rows, err := r.db.Query(ctx, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	userEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[UserEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toUser(userEntity), nil
+}
+
+func (r *AccountRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	query := fmt.Sprintf("DELETE FROM session WHERE token_hash = $1")
+	_, err := r.db.Exec(ctx, query, tokenHash)
+	return err
+}
+
+func (r *AccountRepository) DeleteSessionsByUserID(ctx context.Context, userID int) error {
+	query := fmt.Sprintf("DELETE FROM session WHERE user_id = $1")
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
+
+func (r *AccountRepository) InsertPasswordReset(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error {
+	query := fmt.Sprintf("INSERT INTO password_reset (token_hash, user_id, expires_at) VALUES ($1, $2, $3)")
+	_, err := r.db.Exec(ctx, query, tokenHash, userID, expiresAt)
+	return err
+}
+
+// UsePasswordReset marks an unused and unexpired password reset token as used and returns its user id
+func (r *AccountRepository) UsePasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	query := fmt.Sprintf(`
+		UPDATE password_reset SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`)
+	var userID int
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func toUser(userEntity UserEntity) *User {
+	return &User{
+		ID:        userEntity.ID,
+		Email:     userEntity.Email,
+		Name:      userEntity.Name,
+		CreatedAt: userEntity.CreatedAt,
+		Role:      userEntity.Role,
+	}
+}
@@ -0,0 +1,207 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/mail"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionDuration       = 30 * 24 * time.Hour
+	passwordResetDuration = time.Hour
+	minPasswordLength     = 8
+	// maxPasswordLength is the most bytes bcrypt can hash
+	maxPasswordLength = 72
+	// dummyPasswordHash is compared with the password of unknown emails, so that logging in takes as long
+	// whether or not the email is registered. It is hashed with bcrypt.DefaultCost like the passwords.
+	dummyPasswordHash = "$2a$10$IufP0ZMF1gXAf2Qf7IqxFu2hLwfoyWXNJdJ8E4Fc5PmKkt00LWkQa"
+)
+
+type Repository interface {
+	InsertUser(ctx context.Context, email string, name string, passwordHash string) (*User, error)
+	FindUserEntityByEmail(ctx context.Context, email string) (*UserEntity, error)
+	UpdatePasswordHash(ctx context.Context, userID int, passwordHash string) error
+	InsertSession(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error
+	FindUserBySessionTokenHash(ctx context.Context, tokenHash string) (*User, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteSessionsByUserID(ctx context.Context, userID int) error
+	InsertPasswordReset(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error
+	UsePasswordReset(ctx context.Context, tokenHash string) (int, error)
+}
+
+type AccountService struct {
+	repository Repository
+	mailer     mail.Mailer
+	baseURL    string
+}
+
+func NewService(repository Repository, mailer mail.Mailer, baseURL string) *AccountService {
+	return &AccountService{
+		repository: repository,
+		mailer:     mailer,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Register creates a new user and logs them in
+func (s *AccountService) Register(ctx context.Context, name string, email string, password string) (*Session, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repository.InsertUser(ctx, email, name, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	return s.createSession(ctx, user.ID)
+}
+
+// Login verifies the credentials and creates a new session
+func (s *AccountService) Login(ctx context.Context, email string, password string) (*Session, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	userEntity, err := s.repository.FindUserEntityByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(userEntity.PasswordHash), []byte(password))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return s.createSession(ctx, userEntity.ID)
+}
+
+func (s *AccountService) Logout(ctx context.Context, token string) error {
+	return s.repository.DeleteSession(ctx, hashToken(token))
+}
+
+// GetUserBySession returns the user logged in with the session token, ErrUserNotFound if the session is unknown or expired
+func (s *AccountService) GetUserBySession(ctx context.Context, token string) (*User, error) {
+	return s.repository.FindUserBySessionTokenHash(ctx, hashToken(token))
+}
+
+// RequestPasswordReset emails a password reset link to the user.
+// Unknown emails are silently ignored so that the form cannot be used to find out who is registered.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	userEntity, err := s.repository.FindUserEntityByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	err = s.repository.InsertPasswordReset(ctx, hashToken(token), userEntity.ID, time.Now().Add(passwordResetDuration))
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, mail.Message{
+		To:      userEntity.Email,
+		Subject: "Reset your IHF Referee Trainer password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. The link expires in one hour.\n\n%s/reset-password?token=%s\n\nIf you did not ask to reset your password, you can ignore this email.",
+			userEntity.Name, s.baseURL, token),
+	})
+}
+
+// ResetPassword sets a new password with a password reset token and logs out every session of the user
+func (s *AccountService) ResetPassword(ctx context.Context, token string, password string) error {
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	userID, err := s.repository.UsePasswordReset(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	err = s.repository.UpdatePasswordHash(ctx, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	return s.repository.DeleteSessionsByUserID(ctx, userID)
+}
+
+func (s *AccountService) createSession(ctx context.Context, userID int) (*Session, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(sessionDuration)
+	err = s.repository.InsertSession(ctx, hashToken(token), userID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	address, err := netmail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// newToken returns a random url safe token for sessions and password resets
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes a token before it is stored, so that a leaked database does not leak usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,194 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeRepository keeps the users by email, the sessions and the password resets by token hash
+type fakeRepository struct {
+	Repository
+	users    map[string]UserEntity
+	sessions map[string]int
+	resets   map[string]int
+	err      error
+}
+
+func newFakeRepository(t *testing.T) *fakeRepository {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeRepository{
+		users:    map[string]UserEntity{"referee@example.com": {ID: 1, Email: "referee@example.com", Name: "Referee", PasswordHash: string(hash)}},
+		sessions: map[string]int{},
+		resets:   map[string]int{},
+	}
+}
+
+func (r *fakeRepository) InsertUser(_ context.Context, email string, name string, passwordHash string) (*User, error) {
+	if _, ok := r.users[email]; ok {
+		return nil, ErrEmailTaken
+	}
+	user := UserEntity{ID: len(r.users) + 1, Email: email, Name: name, PasswordHash: passwordHash}
+	r.users[email] = user
+	return &User{ID: user.ID, Email: email, Name: name}, nil
+}
+
+func (r *fakeRepository) FindUserEntityByEmail(_ context.Context, email string) (*UserEntity, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	user, ok := r.users[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (r *fakeRepository) UpdatePasswordHash(_ context.Context, userID int, passwordHash string) error {
+	for email, user := range r.users {
+		if user.ID == userID {
+			user.PasswordHash = passwordHash
+			r.users[email] = user
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) InsertSession(_ context.Context, tokenHash string, userID int, _ time.Time) error {
+	r.sessions[tokenHash] = userID
+	return nil
+}
+
+func (r *fakeRepository) DeleteSessionsByUserID(_ context.Context, userID int) error {
+	for tokenHash, id := range r.sessions {
+		if id == userID {
+			delete(r.sessions, tokenHash)
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) UsePasswordReset(_ context.Context, tokenHash string) (int, error) {
+	userID, ok := r.resets[tokenHash]
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	delete(r.resets, tokenHash)
+	return userID, nil
+}
+
+func TestLogin(t *testing.T) {
+	databaseDown := errors.New("database is down")
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		err      error
+		wantErr  error
+	}{
+		{"registered", "referee@example.com", "correct horse", nil, nil},
+		{"email as typed", " Referee@Example.com ", "correct horse", nil, nil},
+		{"wrong password", "referee@example.com", "wrong horse", nil, ErrInvalidCredentials},
+		{"unknown email", "someone@example.com", "correct horse", nil, ErrInvalidCredentials},
+		{"invalid email", "referee", "correct horse", nil, ErrInvalidCredentials},
+		{"database error", "referee@example.com", "correct horse", databaseDown, databaseDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newFakeRepository(t)
+			repository.err = tt.err
+			session, err := NewService(repository, nil, "").Login(context.Background(), tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(repository.sessions) != 0 {
+					t.Errorf("Login() saved sessions %v, want none", repository.sessions)
+				}
+				return
+			}
+			if session.UserID != 1 || repository.sessions[hashToken(session.Token)] != 1 {
+				t.Errorf("Login() = %+v, want a session of user 1 saved by the hash of its token", session)
+			}
+		})
+	}
+}
+
+// TestDummyPasswordHash checks that unknown emails are compared with a hash as slow to compare as the passwords,
+// a malformed hash would fail at once
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("cost of the dummy hash = %d, %v, want %d", cost, err, bcrypt.DefaultCost)
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte("correct horse"))
+	if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		t.Errorf("comparing with the dummy hash = %v, want %v", err, bcrypt.ErrMismatchedHashAndPassword)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		userName string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"new user", "Instructor", "Instructor@Example.com", "correct horse", nil},
+		{"no name", " ", "instructor@example.com", "correct horse", ErrInvalidName},
+		{"invalid email", "Instructor", "instructor", "correct horse", ErrInvalidEmail},
+		{"email with a name", "Instructor", "Instructor <instructor@example.com>", "correct horse", ErrInvalidEmail},
+		{"short password", "Instructor", "instructor@example.com", "horse", ErrPasswordTooShort},
+		{"long password", "Instructor", "instructor@example.com", string(make([]byte, maxPasswordLength+1)), ErrPasswordTooLong},
+		{"registered email", "Referee", "referee@example.com", "correct horse", ErrEmailTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newFakeRepository(t)
+			session, err := NewService(repository, nil, "").Register(context.Background(), tt.userName, tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Register() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			user, ok := repository.users["instructor@example.com"]
+			if !ok || repository.sessions[hashToken(session.Token)] != user.ID {
+				t.Fatalf("Register() saved users %v, want the user by lower case email and logged in", repository.users)
+			}
+			if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(tt.password)) != nil {
+				t.Error("Register() did not save the hash of the password")
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	repository := newFakeRepository(t)
+	repository.resets[hashToken("reset")] = 1
+	repository.sessions[hashToken("session")] = 1
+	service := NewService(repository, nil, "")
+
+	err := service.ResetPassword(context.Background(), "reset", "battery staple")
+	if err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if len(repository.sessions) != 0 {
+		t.Errorf("sessions after reset = %v, want every session logged out", repository.sessions)
+	}
+	_, err = service.Login(context.Background(), "referee@example.com", "battery staple")
+	if err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+	err = service.ResetPassword(context.Background(), "reset", "another staple")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ResetPassword() with a used token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
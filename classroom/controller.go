@@ -0,0 +1,487 @@
+package classroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/exam"
+	"github.com/aattwwss/ihf-referee-rules/internal"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+const dateTimeFormat = "2 Jan 2006 15:04"
+
+type Service interface {
+	GetRules(ctx context.Context) ([]trainer.Rule, error)
+	ListClasses(ctx context.Context, userID int) ([]Class, error)
+	CreateClass(ctx context.Context, instructorID int, name string) (int, error)
+	JoinClass(ctx context.Context, userID int, inviteCode string) (int, error)
+	GetClass(ctx context.Context, userID int, classID int) (*Class, []Assignment, []Member, error)
+	GetAttempts(ctx context.Context, userID int, assignments []Assignment) (map[int][]exam.Exam, error)
+	CreateAssignment(ctx context.Context, userID int, classID int, newAssignment NewAssignment) (int, error)
+	StartAssignment(ctx context.Context, userID int, assignmentID int) (int, error)
+	GetAssignmentReport(ctx context.Context, userID int, assignmentID int) (*AssignmentReport, error)
+}
+
+type Controller struct {
+	service Service
+	html    fs.FS
+	csrf    *internal.CSRF
+}
+
+func NewController(service Service, html fs.FS, csrf *internal.CSRF) *Controller {
+	return &Controller{
+		service: service,
+		html:    html,
+		csrf:    csrf,
+	}
+}
+
+type ClassesPageData struct {
+	Classes []ClassData
+	// CanCreate shows the form to create a class to instructors
+	CanCreate bool
+	Name      string
+	Code      string
+	Error     string
+	CSRFToken string
+}
+
+type ClassData struct {
+	ID           int
+	Name         string
+	IsInstructor bool
+}
+
+type ClassPageData struct {
+	ID           int
+	Name         string
+	IsInstructor bool
+	InviteCode   string
+	Members      []MemberData
+	Assignments  []AssignmentData
+	// Rules and Form fill in the form to create an assignment, for the instructor
+	Rules     []RuleCountData
+	Form      AssignmentForm
+	Error     string
+	CSRFToken string
+}
+
+type MemberData struct {
+	Name     string
+	Email    string
+	JoinedAt string
+}
+
+type AssignmentData struct {
+	ID              int
+	Title           string
+	DueAt           string
+	IsOpen          bool
+	QuestionCount   int
+	DurationMinutes int
+	PassMark        int
+	Scoring         string
+	// Attempts are the exams of the student, empty for the instructor
+	Attempts []AttemptData
+}
+
+type AttemptData struct {
+	ExamID      int
+	StartedAt   string
+	IsSubmitted bool
+	IsOpen      bool
+	Score       string
+	IsPassed    bool
+}
+
+type RuleCountData struct {
+	ID    string
+	Name  string
+	Count int
+}
+
+// AssignmentForm keeps what the instructor entered when the assignment could not be created
+type AssignmentForm struct {
+	Title           string
+	DueAt           string
+	DurationMinutes int
+	PassMark        int
+	Scoring         string
+	QuestionNumbers string
+}
+
+type AssignmentPageData struct {
+	ClassID         int
+	ClassName       string
+	Title           string
+	DueAt           string
+	QuestionCount   int
+	DurationMinutes int
+	PassMark        int
+	Scoring         string
+	Students        []StudentData
+}
+
+type StudentData struct {
+	Name     string
+	Email    string
+	Attempts []AttemptData
+}
+
+// Classes lists the classes the user teaches or is enrolled in, with the forms to create and join a class
+func (c *Controller) Classes(w http.ResponseWriter, r *http.Request) {
+	c.renderClasses(w, r, ClassesPageData{})
+}
+
+// Create creates a class taught by the user
+func (c *Controller) Create(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := ClassesPageData{Name: r.Form.Get("name")}
+	classID, err := c.service.CreateClass(r.Context(), user.ID, data.Name)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrInvalidClassName) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderClasses(w, r, data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating class: %s", err)
+		http.Error(w, "Error creating class", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/classes/%d", classID), http.StatusSeeOther)
+}
+
+// Join enrols the user in the class of the invite code
+func (c *Controller) Join(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := ClassesPageData{Code: r.Form.Get("code")}
+	classID, err := c.service.JoinClass(r.Context(), user.ID, data.Code)
+	if errors.Is(err, ErrInvalidInviteCode) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderClasses(w, r, data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error joining class: %s", err)
+		http.Error(w, "Error joining class", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/classes/%d", classID), http.StatusSeeOther)
+}
+
+// Class renders a class, its students and the form to add assignments for the instructor,
+// the assignments with the attempts of the user for a student
+func (c *Controller) Class(w http.ResponseWriter, r *http.Request) {
+	classID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	c.renderClass(w, r, classID, AssignmentForm{DurationMinutes: 30, PassMark: 80, Scoring: string(trainer.ScoringExact)}, "")
+}
+
+// CreateAssignment adds an assignment to a class taught by the user
+func (c *Controller) CreateAssignment(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	classID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	form := AssignmentForm{
+		Title:           r.Form.Get("title"),
+		DueAt:           r.Form.Get("due_at"),
+		Scoring:         r.Form.Get("scoring"),
+		QuestionNumbers: r.Form.Get("question_numbers"),
+	}
+	form.DurationMinutes, _ = strconv.Atoi(r.Form.Get("duration_minutes"))
+	form.PassMark, _ = strconv.Atoi(r.Form.Get("pass_mark"))
+
+	newAssignment := NewAssignment{
+		Title:           form.Title,
+		Duration:        time.Duration(form.DurationMinutes) * time.Minute,
+		PassMark:        form.PassMark,
+		Scoring:         form.Scoring,
+		QuestionNumbers: strings.FieldsFunc(form.QuestionNumbers, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' }),
+	}
+	newAssignment.DueAt, err = parseLocalTime(form.DueAt, r.Form.Get("timezone_offset"))
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderClass(w, r, classID, form, ErrInvalidDueDate.Error())
+		return
+	}
+	for key, values := range r.Form {
+		ruleID, ok := strings.CutPrefix(key, "rule_")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		count, err := strconv.Atoi(values[0])
+		if err == nil && count > 0 {
+			newAssignment.Rules = append(newAssignment.Rules, exam.TemplateRule{RuleID: ruleID, QuestionCount: count})
+		}
+	}
+
+	_, err = c.service.CreateAssignment(r.Context(), user.ID, classID, newAssignment)
+	if errors.Is(err, ErrClassNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrNotInstructor) || errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if isValidationError(err) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderClass(w, r, classID, form, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating assignment: %s", err)
+		http.Error(w, "Error creating assignment", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/classes/%d", classID), http.StatusSeeOther)
+}
+
+// StartAssignment starts the exam of an assignment and redirects to it
+func (c *Controller) StartAssignment(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	assignmentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	examID, err := c.service.StartAssignment(r.Context(), user.ID, assignmentID)
+	if errors.Is(err, ErrAssignmentNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrAssignmentClosed) || errors.Is(err, ErrNotInstructor) || errors.Is(err, exam.ErrNoQuestions) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting assignment: %s", err)
+		http.Error(w, "Error starting assignment", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/mock-exams/%d", examID), http.StatusSeeOther)
+}
+
+// Assignment renders the attempts of every student of the class at an assignment, for the instructor
+func (c *Controller) Assignment(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	assignmentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	report, err := c.service.GetAssignmentReport(r.Context(), user.ID, assignmentID)
+	if errors.Is(err, ErrAssignmentNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrNotInstructor) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting assignment report: %s", err)
+		http.Error(w, "Error getting assignment", http.StatusInternalServerError)
+		return
+	}
+
+	assignment := toAssignmentData(report.Assignment, nil)
+	data := AssignmentPageData{
+		ClassID:         report.Class.ID,
+		ClassName:       report.Class.Name,
+		Title:           assignment.Title,
+		DueAt:           assignment.DueAt,
+		QuestionCount:   assignment.QuestionCount,
+		DurationMinutes: assignment.DurationMinutes,
+		PassMark:        assignment.PassMark,
+		Scoring:         assignment.Scoring,
+	}
+	for _, student := range report.Students {
+		data.Students = append(data.Students, StudentData{
+			Name:     student.Member.Name,
+			Email:    student.Member.Email,
+			Attempts: toAttemptsData(student.Exams),
+		})
+	}
+	c.render(w, "classroom/assignment.tmpl", data)
+}
+
+func (c *Controller) renderClasses(w http.ResponseWriter, r *http.Request, data ClassesPageData) {
+	user := account.UserFromContext(r.Context())
+	classes, err := c.service.ListClasses(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error getting classes: %s", err)
+	}
+	data.CanCreate = user.Can(account.PermissionManageClasses)
+	data.CSRFToken, err = c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting classes", http.StatusInternalServerError)
+		return
+	}
+	for _, class := range classes {
+		data.Classes = append(data.Classes, ClassData{
+			ID:           class.ID,
+			Name:         class.Name,
+			IsInstructor: class.IsInstructor(user.ID),
+		})
+	}
+	c.render(w, "classroom/classes.tmpl", data)
+}
+
+func (c *Controller) renderClass(w http.ResponseWriter, r *http.Request, classID int, form AssignmentForm, errorMessage string) {
+	user := account.UserFromContext(r.Context())
+	class, assignments, members, err := c.service.GetClass(r.Context(), user.ID, classID)
+	if errors.Is(err, ErrClassNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting class: %s", err)
+		http.Error(w, "Error getting class", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting class", http.StatusInternalServerError)
+		return
+	}
+
+	data := ClassPageData{
+		ID:           class.ID,
+		Name:         class.Name,
+		IsInstructor: class.IsInstructor(user.ID),
+		Form:         form,
+		Error:        errorMessage,
+		CSRFToken:    token,
+	}
+	attempts := make(map[int][]exam.Exam)
+	if data.IsInstructor {
+		data.InviteCode = class.InviteCode
+		for _, member := range members {
+			data.Members = append(data.Members, MemberData{
+				Name:     member.Name,
+				Email:    member.Email,
+				JoinedAt: member.JoinedAt.Format(dateTimeFormat),
+			})
+		}
+		rules, err := c.service.GetRules(r.Context())
+		if err != nil {
+			log.Printf("Error getting rules: %s", err)
+		}
+		for _, rule := range rules {
+			count, _ := strconv.Atoi(r.Form.Get("rule_" + rule.ID))
+			data.Rules = append(data.Rules, RuleCountData{ID: rule.ID, Name: rule.Name, Count: count})
+		}
+	} else {
+		attempts, err = c.service.GetAttempts(r.Context(), user.ID, assignments)
+		if err != nil {
+			log.Printf("Error getting attempts: %s", err)
+		}
+	}
+	for _, assignment := range assignments {
+		data.Assignments = append(data.Assignments, toAssignmentData(assignment, attempts[assignment.ID]))
+	}
+	c.render(w, "classroom/class.tmpl", data)
+}
+
+func (c *Controller) render(w http.ResponseWriter, page string, data any) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func toAssignmentData(assignment Assignment, exams []exam.Exam) AssignmentData {
+	return AssignmentData{
+		ID:              assignment.ID,
+		Title:           assignment.Title,
+		DueAt:           assignment.DueAt.Format(dateTimeFormat),
+		IsOpen:          assignment.IsOpen(time.Now()),
+		QuestionCount:   assignment.Template.QuestionCount(),
+		DurationMinutes: int(assignment.Template.Duration.Minutes()),
+		PassMark:        assignment.Template.PassMark,
+		Scoring:         assignment.Template.Scoring.Description(),
+		Attempts:        toAttemptsData(exams),
+	}
+}
+
+func toAttemptsData(exams []exam.Exam) []AttemptData {
+	now := time.Now()
+	var attempts []AttemptData
+	for _, e := range exams {
+		attempt := AttemptData{
+			ExamID:      e.ID,
+			StartedAt:   e.StartedAt.Format(dateTimeFormat),
+			IsSubmitted: e.IsSubmitted(),
+			IsOpen:      !e.IsClosed(now),
+			IsPassed:    e.IsPassed,
+		}
+		if e.IsSubmitted() {
+			attempt.Score = fmt.Sprintf("%s / %d", strconv.FormatFloat(math.Round(e.Score*100)/100, 'f', -1, 64), e.QuestionCount)
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+// parseLocalTime parses the value of a datetime-local input, which has no time zone, with the offset in minutes
+// of the browser as returned by Date.getTimezoneOffset
+func parseLocalTime(value string, timezoneOffset string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, _ := strconv.Atoi(timezoneOffset)
+	return t.Add(time.Duration(offset) * time.Minute), nil
+}
+
+func isValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidTitle) || errors.Is(err, ErrInvalidDueDate) ||
+		errors.Is(err, ErrInvalidDuration) || errors.Is(err, ErrInvalidPassMark) ||
+		errors.Is(err, ErrInvalidQuestion) || errors.Is(err, ErrAssignmentNoSource) ||
+		errors.Is(err, trainer.ErrInvalidScoring) || errors.Is(err, exam.ErrNoQuestions)
+}
@@ -0,0 +1,108 @@
+package classroom
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/exam"
+)
+
+var (
+	// ErrClassNotFound is also returned for classes the user neither teaches nor is enrolled in
+	ErrClassNotFound      = errors.New("class not found")
+	ErrInvalidClassName   = errors.New("class name must not be empty")
+	ErrInvalidInviteCode  = errors.New("invalid invite code")
+	ErrNotInstructor      = errors.New("only the instructor of the class can do this")
+	ErrAssignmentNotFound = errors.New("assignment not found")
+	// ErrAssignmentClosed is returned when starting an assignment after its due date
+	ErrAssignmentClosed   = errors.New("assignment is past its due date")
+	ErrInvalidTitle       = errors.New("assignment title must not be empty")
+	ErrInvalidDueDate     = errors.New("due date must be in the future")
+	ErrInvalidDuration    = errors.New("duration must be at least 1 minute")
+	ErrInvalidPassMark    = errors.New("pass mark must be between 0 and 100")
+	ErrInvalidQuestion    = errors.New("unknown question number")
+	ErrAssignmentNoSource = errors.New("select rules or list question numbers for the assignment")
+)
+
+type ClassEntity struct {
+	ID           int
+	Name         string
+	InstructorID int
+	InviteCode   string
+	CreatedAt    time.Time
+}
+
+type MemberEntity struct {
+	UserID   int
+	Name     string
+	Email    string
+	JoinedAt time.Time
+}
+
+type AssignmentEntity struct {
+	ID             int
+	ClassID        int
+	Title          string
+	ExamTemplateID int
+	DueAt          time.Time
+	CreatedAt      time.Time
+}
+
+// Class is a cohort of referees run by an instructor, referees enrol with the invite code
+type Class struct {
+	ID           int
+	Name         string
+	InstructorID int
+	InviteCode   string
+	CreatedAt    time.Time
+}
+
+func (c Class) IsInstructor(userID int) bool {
+	return c.InstructorID == userID
+}
+
+type Member struct {
+	UserID   int
+	Name     string
+	Email    string
+	JoinedAt time.Time
+}
+
+// Assignment is an exam the students of a class are asked to take before the due date
+type Assignment struct {
+	ID        int
+	ClassID   int
+	Title     string
+	Template  exam.Template
+	DueAt     time.Time
+	CreatedAt time.Time
+}
+
+func (a Assignment) IsOpen(now time.Time) bool {
+	return now.Before(a.DueAt)
+}
+
+// NewAssignment is what an instructor fills in to create an assignment. The questions are either the
+// QuestionNumbers, such as 8.3 or SAR1, or drawn from the Rules when no question number is given.
+type NewAssignment struct {
+	Title           string
+	DueAt           time.Time
+	Duration        time.Duration
+	PassMark        int
+	Scoring         string
+	Rules           []exam.TemplateRule
+	QuestionNumbers []string
+}
+
+// StudentAttempts are the exams a student of the class took for an assignment, latest first
+type StudentAttempts struct {
+	Member Member
+	Exams  []exam.Exam
+}
+
+// AssignmentReport is the instructor view of an assignment
+type AssignmentReport struct {
+	Class      Class
+	Assignment Assignment
+	Students   []StudentAttempts
+}
@@ -0,0 +1,188 @@
+package classroom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/exam"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the postgres error code raised when a unique constraint is violated
+const uniqueViolation = "23505"
+
+// errInviteCodeTaken is returned when a new class draws an invite code that is already used
+var errInviteCodeTaken = errors.New("invite code taken")
+
+type ClassRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *ClassRepository {
+	return &ClassRepository{
+		db: db,
+	}
+}
+
+func (r *ClassRepository) InsertClass(ctx context.Context, name string, instructorID int, inviteCode string) (int, error) {
+	query := fmt.Sprintf("INSERT INTO class (name, instructor_id, invite_code) VALUES ($1, $2, $3) RETURNING id")
+	var id int
+	err := r.db.QueryRow(ctx, query, name, instructorID, inviteCode).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return 0, errInviteCodeTaken
+	}
+	return id, err
+}
+
+func (r *ClassRepository) FindClassByID(ctx context.Context, id int) (*Class, error) {
+	query := fmt.Sprintf("SELECT id, name, instructor_id, invite_code, created_at FROM class WHERE id = $1")
+	return r.findClass(ctx, query, id)
+}
+
+func (r *ClassRepository) FindClassByInviteCode(ctx context.Context, inviteCode string) (*Class, error) {
+	query := fmt.Sprintf("SELECT id, name, instructor_id, invite_code, created_at FROM class WHERE invite_code = $1")
+	return r.findClass(ctx, query, inviteCode)
+}
+
+func (r *ClassRepository) findClass(ctx context.Context, query string, args ...any) (*Class, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	classEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[ClassEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrClassNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	class := toClass(classEntity)
+	return &class, nil
+}
+
+// ListClassesByUserID returns the classes the user teaches or is enrolled in, by name
+func (r *ClassRepository) ListClassesByUserID(ctx context.Context, userID int) ([]Class, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, instructor_id, invite_code, created_at FROM class c
+		WHERE instructor_id = $1 OR EXISTS (SELECT 1 FROM class_member m WHERE m.class_id = c.id AND m.user_id = $1)
+		ORDER BY name
+	`)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	classEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ClassEntity])
+	if err != nil {
+		return nil, err
+	}
+	var classes []Class
+	for _, classEntity := range classEntities {
+		classes = append(classes, toClass(classEntity))
+	}
+	return classes, nil
+}
+
+// InsertMember enrols the user in the class, enrolling twice is ignored
+func (r *ClassRepository) InsertMember(ctx context.Context, classID int, userID int) error {
+	query := fmt.Sprintf("INSERT INTO class_member (class_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")
+	_, err := r.db.Exec(ctx, query, classID, userID)
+	return err
+}
+
+func (r *ClassRepository) IsMember(ctx context.Context, classID int, userID int) (bool, error) {
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM class_member WHERE class_id = $1 AND user_id = $2)")
+	var isMember bool
+	err := r.db.QueryRow(ctx, query, classID, userID).Scan(&isMember)
+	return isMember, err
+}
+
+// ListMembers returns the students of the class by name
+func (r *ClassRepository) ListMembers(ctx context.Context, classID int) ([]Member, error) {
+	query := fmt.Sprintf(`
+		SELECT u.id, u.name, u.email, m.joined_at
+		FROM class_member m JOIN "user" u ON m.user_id = u.id
+		WHERE m.class_id = $1
+		ORDER BY u.name, u.id
+	`)
+	rows, err := r.db.Query(ctx, query, classID)
+	if err != nil {
+		return nil, err
+	}
+	memberEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[MemberEntity])
+	if err != nil {
+		return nil, err
+	}
+	var members []Member
+	for _, memberEntity := range memberEntities {
+		members = append(members, Member(memberEntity))
+	}
+	return members, nil
+}
+
+// InsertAssignment saves the exam template of the assignment and the assignment in a single transaction,
+// returning the id of the assignment
+func (r *ClassRepository) InsertAssignment(ctx context.Context, classID int, template exam.Template, dueAt time.Time) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	templateID, err := exam.InsertTemplate(ctx, tx, template)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO assignment (class_id, title, exam_template_id, due_at) VALUES ($1, $2, $3, $4) RETURNING id
+	`)
+	var id int
+	err = tx.QueryRow(ctx, query, classID, template.Name, templateID, dueAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+func (r *ClassRepository) FindAssignmentByID(ctx context.Context, id int) (*AssignmentEntity, error) {
+	query := fmt.Sprintf("SELECT id, class_id, title, exam_template_id, due_at, created_at FROM assignment WHERE id = $1")
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	assignmentEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[AssignmentEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAssignmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &assignmentEntity, nil
+}
+
+// ListAssignmentsByClassID returns the assignments of the class by due date
+func (r *ClassRepository) ListAssignmentsByClassID(ctx context.Context, classID int) ([]AssignmentEntity, error) {
+	query := fmt.Sprintf(`
+		SELECT id, class_id, title, exam_template_id, due_at, created_at FROM assignment
+		WHERE class_id = $1 ORDER BY due_at, id
+	`)
+	rows, err := r.db.Query(ctx, query, classID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[AssignmentEntity])
+}
+
+func toClass(classEntity ClassEntity) Class {
+	return Class{
+		ID:           classEntity.ID,
+		Name:         classEntity.Name,
+		InstructorID: classEntity.InstructorID,
+		InviteCode:   classEntity.InviteCode,
+		CreatedAt:    classEntity.CreatedAt,
+	}
+}
@@ -0,0 +1,358 @@
+package classroom
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/exam"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+const (
+	inviteCodeLength = 8
+	// inviteCodeAlphabet leaves out the characters that are easily mixed up when read out loud
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	inviteCodeAttempts = 5
+)
+
+type Repository interface {
+	InsertClass(ctx context.Context, name string, instructorID int, inviteCode string) (int, error)
+	FindClassByID(ctx context.Context, id int) (*Class, error)
+	FindClassByInviteCode(ctx context.Context, inviteCode string) (*Class, error)
+	ListClassesByUserID(ctx context.Context, userID int) ([]Class, error)
+	InsertMember(ctx context.Context, classID int, userID int) error
+	IsMember(ctx context.Context, classID int, userID int) (bool, error)
+	ListMembers(ctx context.Context, classID int) ([]Member, error)
+	InsertAssignment(ctx context.Context, classID int, template exam.Template, dueAt time.Time) (int, error)
+	FindAssignmentByID(ctx context.Context, id int) (*AssignmentEntity, error)
+	ListAssignmentsByClassID(ctx context.Context, classID int) ([]AssignmentEntity, error)
+}
+
+// ExamService is the part of the exam service the assignments are taken with
+type ExamService interface {
+	GetTemplate(ctx context.Context, templateID int) (*exam.Template, error)
+	ValidateTemplate(ctx context.Context, template exam.Template) error
+	StartPrivateExam(ctx context.Context, userID int, templateID int) (int, error)
+	ListExams(ctx context.Context, userID int) ([]exam.Exam, error)
+	ListTemplateExams(ctx context.Context, templateID int) ([]exam.Exam, error)
+}
+
+// QuestionService is the part of the trainer service the assignments pick their questions from
+type QuestionService interface {
+	GetAllQuestions(ctx context.Context) ([]trainer.Question, error)
+	GetAllRules(ctx context.Context) ([]trainer.Rule, error)
+}
+
+type ClassService struct {
+	repository      Repository
+	examService     ExamService
+	questionService QuestionService
+}
+
+func NewService(repository Repository, examService ExamService, questionService QuestionService) *ClassService {
+	return &ClassService{
+		repository:      repository,
+		examService:     examService,
+		questionService: questionService,
+	}
+}
+
+func (s *ClassService) GetRules(ctx context.Context) ([]trainer.Rule, error) {
+	return s.questionService.GetAllRules(ctx)
+}
+
+func (s *ClassService) ListClasses(ctx context.Context, userID int) ([]Class, error) {
+	return s.repository.ListClassesByUserID(ctx, userID)
+}
+
+// CreateClass creates a class taught by the user with a new invite code and returns its id
+func (s *ClassService) CreateClass(ctx context.Context, instructorID int, name string) (int, error) {
+	err := account.Authorize(ctx, account.PermissionManageClasses)
+	if err != nil {
+		return 0, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, ErrInvalidClassName
+	}
+	for range inviteCodeAttempts {
+		inviteCode, err := newInviteCode()
+		if err != nil {
+			return 0, err
+		}
+		id, err := s.repository.InsertClass(ctx, name, instructorID, inviteCode)
+		if errors.Is(err, errInviteCodeTaken) {
+			continue
+		}
+		return id, err
+	}
+	return 0, errInviteCodeTaken
+}
+
+// JoinClass enrols the user in the class of the invite code and returns its id
+func (s *ClassService) JoinClass(ctx context.Context, userID int, inviteCode string) (int, error) {
+	class, err := s.repository.FindClassByInviteCode(ctx, strings.ToUpper(strings.TrimSpace(inviteCode)))
+	if errors.Is(err, ErrClassNotFound) {
+		return 0, ErrInvalidInviteCode
+	}
+	if err != nil {
+		return 0, err
+	}
+	if class.IsInstructor(userID) {
+		return class.ID, nil
+	}
+	return class.ID, s.repository.InsertMember(ctx, class.ID, userID)
+}
+
+// GetClass returns the class with its assignments and, for its instructor, its students.
+// ErrClassNotFound if the user neither teaches the class nor is enrolled in it.
+func (s *ClassService) GetClass(ctx context.Context, userID int, classID int) (*Class, []Assignment, []Member, error) {
+	class, err := s.getClass(ctx, userID, classID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	assignmentEntities, err := s.repository.ListAssignmentsByClassID(ctx, classID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var assignments []Assignment
+	for _, assignmentEntity := range assignmentEntities {
+		assignment, err := s.toAssignment(ctx, assignmentEntity)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		assignments = append(assignments, *assignment)
+	}
+	var members []Member
+	if class.IsInstructor(userID) {
+		members, err = s.repository.ListMembers(ctx, classID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return class, assignments, members, nil
+}
+
+// GetAttempts returns the exams the user took for each assignment of the class, by assignment id
+func (s *ClassService) GetAttempts(ctx context.Context, userID int, assignments []Assignment) (map[int][]exam.Exam, error) {
+	exams, err := s.examService.ListExams(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make(map[int][]exam.Exam)
+	for _, assignment := range assignments {
+		for _, e := range exams {
+			if e.Template.ID == assignment.Template.ID {
+				attempts[assignment.ID] = append(attempts[assignment.ID], e)
+			}
+		}
+	}
+	return attempts, nil
+}
+
+// CreateAssignment creates an assignment in a class taught by the user and returns its id.
+// The exam of the assignment is a private template, with the listed questions or drawn from the rules.
+func (s *ClassService) CreateAssignment(ctx context.Context, userID int, classID int, newAssignment NewAssignment) (int, error) {
+	err := account.Authorize(ctx, account.PermissionManageClasses)
+	if err != nil {
+		return 0, err
+	}
+	class, err := s.getClass(ctx, userID, classID)
+	if err != nil {
+		return 0, err
+	}
+	if !class.IsInstructor(userID) {
+		return 0, ErrNotInstructor
+	}
+	template, err := s.validateAssignment(ctx, newAssignment)
+	if err != nil {
+		return 0, err
+	}
+	err = s.examService.ValidateTemplate(ctx, *template)
+	if err != nil {
+		return 0, err
+	}
+	return s.repository.InsertAssignment(ctx, classID, *template, newAssignment.DueAt)
+}
+
+// StartAssignment starts an exam of the assignment for a student of its class and returns the id of the exam
+func (s *ClassService) StartAssignment(ctx context.Context, userID int, assignmentID int) (int, error) {
+	assignment, class, err := s.getAssignment(ctx, userID, assignmentID)
+	if err != nil {
+		return 0, err
+	}
+	if class.IsInstructor(userID) {
+		return 0, ErrNotInstructor
+	}
+	if !assignment.IsOpen(time.Now()) {
+		return 0, ErrAssignmentClosed
+	}
+	return s.examService.StartPrivateExam(ctx, userID, assignment.Template.ID)
+}
+
+// GetAssignmentReport returns the attempts of every student of the class at the assignment, for its instructor
+func (s *ClassService) GetAssignmentReport(ctx context.Context, userID int, assignmentID int) (*AssignmentReport, error) {
+	assignment, class, err := s.getAssignment(ctx, userID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !class.IsInstructor(userID) {
+		return nil, ErrNotInstructor
+	}
+	members, err := s.repository.ListMembers(ctx, class.ID)
+	if err != nil {
+		return nil, err
+	}
+	exams, err := s.examService.ListTemplateExams(ctx, assignment.Template.ID)
+	if err != nil {
+		return nil, err
+	}
+	report := &AssignmentReport{
+		Class:      *class,
+		Assignment: *assignment,
+	}
+	for _, member := range members {
+		attempts := StudentAttempts{Member: member}
+		for _, e := range exams {
+			if e.UserID == member.UserID {
+				attempts.Exams = append(attempts.Exams, e)
+			}
+		}
+		report.Students = append(report.Students, attempts)
+	}
+	return report, nil
+}
+
+// getClass returns the class if the user teaches it or is enrolled in it
+func (s *ClassService) getClass(ctx context.Context, userID int, classID int) (*Class, error) {
+	class, err := s.repository.FindClassByID(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+	if class.IsInstructor(userID) {
+		return class, nil
+	}
+	isMember, err := s.repository.IsMember(ctx, classID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, ErrClassNotFound
+	}
+	return class, nil
+}
+
+// getAssignment returns the assignment and its class if the user teaches the class or is enrolled in it
+func (s *ClassService) getAssignment(ctx context.Context, userID int, assignmentID int) (*Assignment, *Class, error) {
+	assignmentEntity, err := s.repository.FindAssignmentByID(ctx, assignmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	class, err := s.getClass(ctx, userID, assignmentEntity.ClassID)
+	if errors.Is(err, ErrClassNotFound) {
+		return nil, nil, ErrAssignmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	assignment, err := s.toAssignment(ctx, *assignmentEntity)
+	if err != nil {
+		return nil, nil, err
+	}
+	return assignment, class, nil
+}
+
+// validateAssignment checks the assignment and returns the private exam template it is taken with
+func (s *ClassService) validateAssignment(ctx context.Context, newAssignment NewAssignment) (*exam.Template, error) {
+	title := strings.TrimSpace(newAssignment.Title)
+	if title == "" {
+		return nil, ErrInvalidTitle
+	}
+	if !newAssignment.DueAt.After(time.Now()) {
+		return nil, ErrInvalidDueDate
+	}
+	if newAssignment.Duration < time.Minute {
+		return nil, ErrInvalidDuration
+	}
+	if newAssignment.PassMark < 0 || newAssignment.PassMark > 100 {
+		return nil, ErrInvalidPassMark
+	}
+	scoring, err := trainer.ParseScoring(newAssignment.Scoring)
+	if err != nil {
+		return nil, err
+	}
+	template := &exam.Template{
+		Name:     title,
+		Duration: newAssignment.Duration,
+		PassMark: newAssignment.PassMark,
+		Scoring:  scoring,
+		IsPublic: false,
+	}
+
+	if len(newAssignment.QuestionNumbers) > 0 {
+		questions, err := s.questionService.GetAllQuestions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, number := range newAssignment.QuestionNumbers {
+			idx := slices.IndexFunc(questions, func(question trainer.Question) bool {
+				return strings.EqualFold(question.RuleQuestionNumber, number)
+			})
+			if idx < 0 {
+				return nil, ErrInvalidQuestion
+			}
+			if !slices.Contains(template.QuestionIDs, questions[idx].ID) {
+				template.QuestionIDs = append(template.QuestionIDs, questions[idx].ID)
+			}
+		}
+		return template, nil
+	}
+
+	rules, err := s.questionService.GetAllRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range newAssignment.Rules {
+		isRule := slices.ContainsFunc(rules, func(r trainer.Rule) bool { return r.ID == rule.RuleID })
+		if isRule && rule.QuestionCount > 0 {
+			template.Rules = append(template.Rules, rule)
+		}
+	}
+	if len(template.Rules) == 0 {
+		return nil, ErrAssignmentNoSource
+	}
+	return template, nil
+}
+
+func (s *ClassService) toAssignment(ctx context.Context, assignmentEntity AssignmentEntity) (*Assignment, error) {
+	template, err := s.examService.GetTemplate(ctx, assignmentEntity.ExamTemplateID)
+	if err != nil {
+		return nil, err
+	}
+	return &Assignment{
+		ID:        assignmentEntity.ID,
+		ClassID:   assignmentEntity.ClassID,
+		Title:     assignmentEntity.Title,
+		Template:  *template,
+		DueAt:     assignmentEntity.DueAt,
+		CreatedAt: assignmentEntity.CreatedAt,
+	}, nil
+}
+
+// newInviteCode returns a random code that is easy to read out to a class
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)]
+	}
+	return string(b), nil
+}
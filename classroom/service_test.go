@@ -0,0 +1,117 @@
+package classroom
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/exam"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+// fakeClassRepository has one class taught by user 1 with user 2 enrolled, and counts the assignments inserted
+type fakeClassRepository struct {
+	Repository
+	inserted int
+	template exam.Template
+}
+
+func (r *fakeClassRepository) FindClassByID(_ context.Context, id int) (*Class, error) {
+	if id != 1 {
+		return nil, ErrClassNotFound
+	}
+	return &Class{ID: 1, Name: "U15 referees", InstructorID: 1}, nil
+}
+
+func (r *fakeClassRepository) IsMember(_ context.Context, _ int, userID int) (bool, error) {
+	return userID == 2, nil
+}
+
+func (r *fakeClassRepository) InsertAssignment(_ context.Context, _ int, template exam.Template, _ time.Time) (int, error) {
+	r.inserted++
+	r.template = template
+	return r.inserted, nil
+}
+
+// fakeExamService refuses the templates when err is set
+type fakeExamService struct {
+	ExamService
+	err error
+}
+
+func (s *fakeExamService) ValidateTemplate(_ context.Context, _ exam.Template) error {
+	return s.err
+}
+
+// fakeQuestionService has question 8.1
+type fakeQuestionService struct {
+	QuestionService
+}
+
+func (s *fakeQuestionService) GetAllQuestions(_ context.Context) ([]trainer.Question, error) {
+	return []trainer.Question{{ID: 81, RuleQuestionNumber: "8.1"}}, nil
+}
+
+func TestCreateAssignmentSavesItsTemplate(t *testing.T) {
+	ctx := account.WithUser(context.Background(), &account.User{ID: 1, Role: account.RoleInstructor})
+	newAssignment := NewAssignment{
+		Title:           "Rule 8",
+		DueAt:           time.Now().Add(24 * time.Hour),
+		Duration:        30 * time.Minute,
+		PassMark:        80,
+		Scoring:         string(trainer.ScoringExact),
+		QuestionNumbers: []string{"8.1"},
+	}
+	tests := []struct {
+		name         string
+		validateErr  error
+		wantInserted int
+	}{
+		{"valid template", nil, 1},
+		{"refused template", exam.ErrNoQuestions, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeClassRepository{}
+			service := NewService(repository, &fakeExamService{err: tt.validateErr}, &fakeQuestionService{})
+			_, err := service.CreateAssignment(ctx, 1, 1, newAssignment)
+			if !errors.Is(err, tt.validateErr) {
+				t.Fatalf("CreateAssignment() error = %v, want %v", err, tt.validateErr)
+			}
+			if repository.inserted != tt.wantInserted {
+				t.Fatalf("inserted %d assignments, want %d", repository.inserted, tt.wantInserted)
+			}
+			if tt.wantInserted > 0 && (repository.template.Name != "Rule 8" || !slices.Equal(repository.template.QuestionIDs, []int{81})) {
+				t.Errorf("saved template %+v, want the questions of the assignment", repository.template)
+			}
+		})
+	}
+}
+
+func TestCreateAssignmentInOwnClassOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		wantErr error
+	}{
+		{"enrolled instructor", 2, ErrNotInstructor},
+		{"instructor of another class", 3, ErrClassNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeClassRepository{}
+			service := NewService(repository, nil, nil)
+			ctx := account.WithUser(context.Background(), &account.User{ID: tt.userID, Role: account.RoleInstructor})
+			_, err := service.CreateAssignment(ctx, tt.userID, 1, NewAssignment{Title: "Rule 8", QuestionNumbers: []string{"8.1"}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAssignment() error = %v, want %v", err, tt.wantErr)
+			}
+			if repository.inserted != 0 {
+				t.Errorf("inserted %d assignments, want none", repository.inserted)
+			}
+		})
+	}
+}
@@ -3,14 +3,33 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/classroom"
+	"github.com/aattwwss/ihf-referee-rules/edition"
+	"github.com/aattwwss/ihf-referee-rules/exam"
 	"github.com/aattwwss/ihf-referee-rules/internal"
+	"github.com/aattwwss/ihf-referee-rules/live"
+	"github.com/aattwwss/ihf-referee-rules/mail"
+	"github.com/aattwwss/ihf-referee-rules/notify"
 	"github.com/aattwwss/ihf-referee-rules/public"
 	"github.com/aattwwss/ihf-referee-rules/trainer"
+	"github.com/aattwwss/ihf-referee-rules/webhook"
 	"github.com/caarlos0/env/v6"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"io/fs"
 	"log"
 	"net/http"
+	"time"
+)
+
+const (
+	// feedbackRateLimit is the number of feedback and reports an IP can submit within feedbackRateWindow
+	feedbackRateLimit  = 5
+	feedbackRateWindow = 10 * time.Minute
+	// liveJoinRateLimit is the number of wrong live quiz PINs an IP can try within liveJoinRateWindow
+	liveJoinRateLimit  = 10
+	liveJoinRateWindow = 10 * time.Minute
 )
 
 func main() {
@@ -43,33 +62,212 @@ func main() {
 		log.Fatal(err)
 	}
 
+	var mailer mail.Mailer = mail.NewLogMailer()
+	if cfg.SmtpHost != "" {
+		mailer = mail.NewSMTPMailer(cfg.SmtpHost, cfg.SmtpPort, cfg.SmtpUsername, cfg.SmtpPassword, cfg.SmtpFrom)
+	}
+	if len(cfg.WebhookUrls) > 0 && cfg.WebhookSecret == "" {
+		log.Fatal("WEBHOOK_SECRET must be set to sign the deliveries to WEBHOOK_URLS")
+	}
+	webhooks := webhook.NewSender(cfg.WebhookUrls, cfg.WebhookSecret)
+	notifier := notify.NewFeedbackNotifier(mailer, webhooks, cfg.FeedbackEmails, cfg.BaseUrl)
+
 	repo := trainer.NewRepository(db)
-	service := trainer.NewService(repo)
-	controller := trainer.NewController(service, htmlFS)
+	service := trainer.NewService(repo, notifier)
+	csrf := internal.NewCSRF(cfg.CookieSecure)
+	feedbackLimiter := internal.NewRateLimiter(feedbackRateLimit, feedbackRateWindow)
+	signer, err := internal.NewSigner(cfg.SigningSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+	controller := trainer.NewController(service, htmlFS, csrf, feedbackLimiter, cfg.TrustProxy, signer)
+	apiController := trainer.NewAPIController(service, public.OpenAPI())
 
-	// Create a file server to serve static files from the directory
-	fileServer := http.FileServer(http.FS(staticFS))
+	accountRepo := account.NewRepository(db)
+	accountService := account.NewService(accountRepo, mailer, cfg.BaseUrl)
+	accountController := account.NewController(accountService, htmlFS, csrf, cfg.CookieSecure)
 
-	// Handle requests to /static/ using the file server
-	http.Handle("GET /static/", http.StripPrefix("/static/", fileServer))
-
-	// Handle the root URL ("/") by serving an HTML file (e.g., index.html)
-	http.HandleFunc("GET /", controller.Home)
-	http.HandleFunc("GET /feedback", controller.Feedback)
-	http.HandleFunc("POST /feedback", controller.SubmitFeedback)
-	//http.HandleFunc("GET /random-question", controller.RandomQuestion)
-	//http.HandleFunc("GET /question-list", controller.QuestionList)
-	//http.HandleFunc("GET /question", controller.QuestionByID)
-	//
-	//http.HandleFunc("POST /submit/", controller.Result)
-	//http.HandleFunc("GET /new-question", controller.NewQuestion)
-	http.HandleFunc("GET /health", controller.Health)
+	examRepo := exam.NewRepository(db)
+	examService := exam.NewService(examRepo, service)
+	examController := exam.NewController(examService, htmlFS)
+
+	classRepo := classroom.NewRepository(db)
+	classService := classroom.NewService(classRepo, examService, service)
+	classController := classroom.NewController(classService, htmlFS, csrf)
+
+	editionRepo := edition.NewRepository(db)
+	editionService := edition.NewService(editionRepo)
+	editionController := edition.NewController(editionService, htmlFS, csrf)
+
+	liveHub := live.NewHub(service)
+	go liveHub.Run(ctx)
+	liveJoinLimiter := internal.NewRateLimiter(liveJoinRateLimit, liveJoinRateWindow)
+	liveController := live.NewController(liveHub, htmlFS, cfg.CookieSecure, liveJoinLimiter, cfg.TrustProxy)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, controllers{
+		static:  staticFS,
+		trainer: controller,
+		api:     apiController,
+		account: accountController,
+		exam:    examController,
+		class:   classController,
+		edition: editionController,
+		live:    liveController,
+		csrf:    csrf,
+	})
 
 	// Set up and start the HTTP server on port 8080
 	port := "8080"
 	log.Printf("Server is listening on :%s...", port)
-	err = http.ListenAndServe(":"+port, nil)
+	err = http.ListenAndServe(":"+port, accountController.Session(mux))
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// controllers are what the routes are served by
+type controllers struct {
+	static  fs.FS
+	trainer *trainer.Controller
+	api     *trainer.APIController
+	account *account.Controller
+	exam    *exam.Controller
+	class   *classroom.Controller
+	edition *edition.Controller
+	live    *live.Controller
+	// csrf checks the token of the forms that change accounts, sets, classes and the content
+	csrf *internal.CSRF
+}
+
+// access is who can reach a route
+type access struct {
+	// loggedIn routes send anonymous users to the login page
+	loggedIn bool
+	// permission is needed on top of being logged in when set
+	permission account.Permission
+}
+
+var (
+	anyone = access{}
+	users  = access{loggedIn: true}
+)
+
+// permitted is the access of the users with the permission
+func permitted(permission account.Permission) access {
+	return access{loggedIn: true, permission: permission}
+}
+
+type route struct {
+	pattern string
+	access  access
+	handler http.HandlerFunc
+}
+
+// guarded returns the handler of the route behind the checks of its access
+func (r route) guarded() http.HandlerFunc {
+	switch {
+	case r.access.permission != "":
+		return account.RequirePermission(r.access.permission, r.handler)
+	case r.access.loggedIn:
+		return account.RequireUser(r.handler)
+	default:
+		return r.handler
+	}
+}
+
+// routes returns the pages and actions of the site with who can reach them
+func routes(c controllers) []route {
+	return []route{
+		{"GET /", anyone, c.trainer.Home},
+		{"POST /progress/answers/{id}", users, c.trainer.SaveAnswer},
+		{"POST /progress/read/{id}", users, c.trainer.SaveRead},
+		{"POST /progress/clear", users, c.trainer.ClearProgress},
+		{"POST /progress/import", users, c.trainer.ImportProgress},
+		{"GET /exam", anyone, c.trainer.Exam},
+		{"POST /exam", anyone, c.trainer.SubmitExam},
+		{"GET /sets", permitted(account.PermissionManageSets), c.trainer.QuestionSets},
+		{"POST /sets", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.CreateQuestionSet)},
+		{"GET /sets/{code}", anyone, c.trainer.QuestionSet},
+		{"POST /sets/{code}", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.UpdateQuestionSet)},
+		{"POST /sets/{code}/delete", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.DeleteQuestionSet)},
+		{"GET /sets/{code}/search", permitted(account.PermissionManageSets), c.trainer.SearchQuestionSet},
+		{"POST /sets/{code}/questions", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.AddToQuestionSet)},
+		{"POST /sets/{code}/questions/{questionID}/remove", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.RemoveFromQuestionSet)},
+		{"POST /sets/{code}/questions/{questionID}/move", permitted(account.PermissionManageSets), c.csrf.Protect(c.trainer.MoveInQuestionSet)},
+		{"GET /dashboard", users, c.trainer.Dashboard},
+		{"GET /review", users, c.trainer.Review},
+		{"GET /review/next", users, c.trainer.NextReview},
+		{"POST /review/{id}", users, c.trainer.SubmitReview},
+		{"GET /mock-exams", users, c.exam.Exams},
+		{"POST /mock-exams", users, c.exam.Start},
+		{"GET /mock-exams/{id}", users, c.exam.Exam},
+		{"POST /mock-exams/{id}/questions/{position}", users, c.exam.SaveSelection},
+		{"POST /mock-exams/{id}/submit", users, c.exam.Submit},
+		{"GET /mock-exams/{id}/result", users, c.exam.Result},
+		{"GET /classes", users, c.class.Classes},
+		{"POST /classes", permitted(account.PermissionManageClasses), c.csrf.Protect(c.class.Create)},
+		{"POST /classes/join", users, c.csrf.Protect(c.class.Join)},
+		{"GET /classes/{id}", users, c.class.Class},
+		{"POST /classes/{id}/assignments", permitted(account.PermissionManageClasses), c.csrf.Protect(c.class.CreateAssignment)},
+		{"GET /assignments/{id}", users, c.class.Assignment},
+		{"POST /assignments/{id}/start", users, c.csrf.Protect(c.class.StartAssignment)},
+		{"GET /live", anyone, c.live.Lobby},
+		{"POST /live", permitted(account.PermissionManageClasses), c.live.Start},
+		{"POST /live/join", anyone, c.live.Join},
+		{"GET /live/{pin}", anyone, c.live.Play},
+		{"POST /live/{pin}/answer", anyone, c.live.Answer},
+		{"GET /live/{pin}/events", anyone, c.live.Events},
+		{"GET /live/{pin}/host", permitted(account.PermissionManageClasses), c.live.Host},
+		{"POST /live/{pin}/questions", permitted(account.PermissionManageClasses), c.live.PushQuestion},
+		{"POST /live/{pin}/reveal", permitted(account.PermissionManageClasses), c.live.Reveal},
+		{"POST /live/{pin}/end", permitted(account.PermissionManageClasses), c.live.End},
+		{"GET /feedback", anyone, c.trainer.Feedback},
+		{"POST /feedback", anyone, c.trainer.SubmitFeedback},
+		{"GET /admin/feedback", permitted(account.PermissionManageFeedback), c.trainer.AdminFeedback},
+		{"POST /admin/feedback/{id}", permitted(account.PermissionManageFeedback), c.csrf.Protect(c.trainer.SetFeedbackStatus)},
+		{"GET /admin/questions/{id}", permitted(account.PermissionManageContent), c.trainer.EditQuestionPage},
+		{"POST /admin/questions/{id}", permitted(account.PermissionManageContent), c.csrf.Protect(c.trainer.EditQuestion)},
+		{"POST /admin/questions/{id}/revisions/{revisionID}/revert", permitted(account.PermissionManageContent), c.csrf.Protect(c.trainer.RevertQuestionRevision)},
+		{"GET /admin/imports", permitted(account.PermissionManageContent), c.edition.Imports},
+		// the upload checks its CSRF token itself, once its size is bounded
+		{"POST /admin/imports", permitted(account.PermissionManageContent), c.edition.Upload},
+		{"GET /admin/imports/{id}", permitted(account.PermissionManageContent), c.edition.Import},
+		{"POST /admin/imports/{id}/publish", permitted(account.PermissionManageContent), c.csrf.Protect(c.edition.Publish)},
+		{"GET /questions", anyone, c.trainer.Questions},
+		{"GET /question-list", anyone, c.trainer.QuestionList},
+		{"GET /questions/{id}/report", anyone, c.trainer.ReportQuestionPage},
+		{"POST /questions/{id}/report", anyone, c.trainer.ReportQuestion},
+		{"GET /random-question", anyone, c.trainer.RandomQuestion},
+		{"GET /question", anyone, c.trainer.QuestionByID},
+		{"POST /submit/{id}", anyone, c.trainer.Result},
+		{"GET /new-question", anyone, c.trainer.NewQuestion},
+		{"GET /health", anyone, c.trainer.Health},
+
+		// accounts
+		{"GET /account/nav", anyone, c.account.Nav},
+		{"GET /login", anyone, c.account.LoginPage},
+		{"POST /login", anyone, c.csrf.Protect(c.account.Login)},
+		{"POST /logout", anyone, c.csrf.Protect(c.account.Logout)},
+		{"GET /register", anyone, c.account.RegisterPage},
+		{"POST /register", anyone, c.account.Register},
+		{"GET /forgot-password", anyone, c.account.ForgotPasswordPage},
+		{"POST /forgot-password", anyone, c.account.ForgotPassword},
+		{"GET /reset-password", anyone, c.account.ResetPasswordPage},
+		{"POST /reset-password", anyone, c.account.ResetPassword},
+	}
+}
+
+// registerRoutes adds the static files, the routes of the site and the JSON api to the mux
+func registerRoutes(mux *http.ServeMux, c controllers) {
+	// Create a file server to serve static files from the directory
+	fileServer := http.FileServer(http.FS(c.static))
+
+	// Handle requests to /static/ using the file server
+	mux.Handle("GET /static/", http.StripPrefix("/static/", fileServer))
+
+	for _, route := range routes(c) {
+		mux.HandleFunc(route.pattern, route.guarded())
+	}
+	c.api.Register(mux)
+}
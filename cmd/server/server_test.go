@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+)
+
+// guardedRoutes are the routes that are not open to anyone, with who can reach them
+var guardedRoutes = map[string]access{
+	"POST /progress/answers/{id}":                     users,
+	"POST /progress/read/{id}":                        users,
+	"POST /progress/clear":                            users,
+	"POST /progress/import":                           users,
+	"GET /sets":                                       permitted(account.PermissionManageSets),
+	"POST /sets":                                      permitted(account.PermissionManageSets),
+	"POST /sets/{code}":                               permitted(account.PermissionManageSets),
+	"POST /sets/{code}/delete":                        permitted(account.PermissionManageSets),
+	"GET /sets/{code}/search":                         permitted(account.PermissionManageSets),
+	"POST /sets/{code}/questions":                     permitted(account.PermissionManageSets),
+	"POST /sets/{code}/questions/{questionID}/remove": permitted(account.PermissionManageSets),
+	"POST /sets/{code}/questions/{questionID}/move":   permitted(account.PermissionManageSets),
+	"GET /dashboard":                                  users,
+	"GET /review":                                     users,
+	"GET /review/next":                                users,
+	"POST /review/{id}":                               users,
+	"GET /mock-exams":                                 users,
+	"POST /mock-exams":                                users,
+	"GET /mock-exams/{id}":                            users,
+	"POST /mock-exams/{id}/questions/{position}":      users,
+	"POST /mock-exams/{id}/submit":                    users,
+	"GET /mock-exams/{id}/result":                     users,
+	"GET /classes":                                    users,
+	"POST /classes":                                   permitted(account.PermissionManageClasses),
+	"POST /classes/join":                              users,
+	"GET /classes/{id}":                               users,
+	"POST /classes/{id}/assignments":                  permitted(account.PermissionManageClasses),
+	"GET /assignments/{id}":                           users,
+	"POST /assignments/{id}/start":                    users,
+	"POST /live":                                      permitted(account.PermissionManageClasses),
+	"GET /live/{pin}/host":                            permitted(account.PermissionManageClasses),
+	"POST /live/{pin}/questions":                      permitted(account.PermissionManageClasses),
+	"POST /live/{pin}/reveal":                         permitted(account.PermissionManageClasses),
+	"POST /live/{pin}/end":                            permitted(account.PermissionManageClasses),
+	"GET /admin/feedback":                             permitted(account.PermissionManageFeedback),
+	"POST /admin/feedback/{id}":                       permitted(account.PermissionManageFeedback),
+	"GET /admin/questions/{id}":                       permitted(account.PermissionManageContent),
+	"POST /admin/questions/{id}":                      permitted(account.PermissionManageContent),
+	"POST /admin/questions/{id}/revisions/{revisionID}/revert": permitted(account.PermissionManageContent),
+	"GET /admin/imports":               permitted(account.PermissionManageContent),
+	"POST /admin/imports":              permitted(account.PermissionManageContent),
+	"GET /admin/imports/{id}":          permitted(account.PermissionManageContent),
+	"POST /admin/imports/{id}/publish": permitted(account.PermissionManageContent),
+}
+
+func TestRouteAccess(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, route := range routes(controllers{}) {
+		seen[route.pattern] = true
+		want, ok := guardedRoutes[route.pattern]
+		if !ok {
+			want = anyone
+		}
+		if route.access != want {
+			t.Errorf("%s has access %+v, want %+v", route.pattern, route.access, want)
+		}
+	}
+	for pattern := range guardedRoutes {
+		if !seen[pattern] {
+			t.Errorf("%s is not a route", pattern)
+		}
+	}
+}
+
+var wildcard = regexp.MustCompile(`\{\w+\}`)
+
+// exampleRequest returns a request of the user matching the pattern, with its wildcards filled
+func exampleRequest(pattern string, user *account.User) *http.Request {
+	method, path, _ := strings.Cut(pattern, " ")
+	r := httptest.NewRequest(method, wildcard.ReplaceAllString(path, "1"), nil)
+	if user != nil {
+		r = r.WithContext(account.WithUser(context.Background(), user))
+	}
+	return r
+}
+
+// reached stands for the handler of a route, answering 200 when the guard lets the request through
+func reached(w http.ResponseWriter, r *http.Request) {}
+
+// TestGuardedRoutes serves every guarded route to anonymous users, referees, instructors and admins
+func TestGuardedRoutes(t *testing.T) {
+	roles := []account.Role{account.RoleReferee, account.RoleInstructor, account.RoleAdmin}
+	for _, route := range routes(controllers{}) {
+		if route.access == anyone {
+			continue
+		}
+		route.handler = reached
+		t.Run(route.pattern, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := exampleRequest(route.pattern, nil)
+			route.guarded()(w, r)
+			if want := "/login?next=" + url.QueryEscape(r.URL.RequestURI()); w.Code != http.StatusSeeOther || w.Header().Get("Location") != want {
+				t.Errorf("anonymous: status %d to %q, want 303 to %q", w.Code, w.Header().Get("Location"), want)
+			}
+			for _, role := range roles {
+				w := httptest.NewRecorder()
+				route.guarded()(w, exampleRequest(route.pattern, &account.User{ID: 1, Role: role}))
+				want := http.StatusOK
+				if route.access.permission != "" && !role.Can(route.access.permission) {
+					want = http.StatusForbidden
+				}
+				if w.Code != want {
+					t.Errorf("%s: status %d, want %d", role, w.Code, want)
+				}
+			}
+		})
+	}
+}
+
+// TestGuardedRoutesByRole spells out what referees, instructors and admins can reach
+func TestGuardedRoutesByRole(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		referee    bool
+		instructor bool
+		admin      bool
+	}{
+		{"GET /dashboard", true, true, true},
+		{"POST /mock-exams", true, true, true},
+		{"GET /sets", false, true, true},
+		{"POST /classes", false, true, true},
+		{"POST /live", false, true, true},
+		{"GET /live/{pin}/host", false, true, true},
+		{"POST /live/{pin}/end", false, true, true},
+		{"GET /admin/feedback", false, false, true},
+		{"POST /admin/questions/{id}", false, false, true},
+		{"POST /admin/imports/{id}/publish", false, false, true},
+	}
+	byPattern := make(map[string]route)
+	for _, route := range routes(controllers{}) {
+		route.handler = reached
+		byPattern[route.pattern] = route
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			route, ok := byPattern[tt.pattern]
+			if !ok {
+				t.Fatal("not a route")
+			}
+			for role, want := range map[account.Role]bool{account.RoleReferee: tt.referee, account.RoleInstructor: tt.instructor, account.RoleAdmin: tt.admin} {
+				w := httptest.NewRecorder()
+				route.guarded()(w, exampleRequest(tt.pattern, &account.User{ID: 1, Role: role}))
+				if got := w.Code == http.StatusOK; got != want {
+					t.Errorf("%s: status %d, want allowed %t", role, w.Code, want)
+				}
+			}
+		})
+	}
+}
+
+// formRoutes are the forms that are only accepted with the CSRF token of the browser
+var formRoutes = []string{
+	"POST /login",
+	"POST /logout",
+	"POST /sets",
+	"POST /sets/{code}",
+	"POST /sets/{code}/delete",
+	"POST /sets/{code}/questions",
+	"POST /sets/{code}/questions/{questionID}/remove",
+	"POST /sets/{code}/questions/{questionID}/move",
+	"POST /classes",
+	"POST /classes/join",
+	"POST /classes/{id}/assignments",
+	"POST /assignments/{id}/start",
+	"POST /admin/feedback/{id}",
+	"POST /admin/questions/{id}",
+	"POST /admin/questions/{id}/revisions/{revisionID}/revert",
+	"POST /admin/imports/{id}/publish",
+}
+
+// TestFormRoutesCheckCSRF posts every form as an admin without the token, which the controllers must not be reached by
+func TestFormRoutesCheckCSRF(t *testing.T) {
+	byPattern := make(map[string]route)
+	for _, route := range routes(controllers{}) {
+		byPattern[route.pattern] = route
+	}
+	for _, pattern := range formRoutes {
+		route, ok := byPattern[pattern]
+		if !ok {
+			t.Errorf("%s is not a route", pattern)
+			continue
+		}
+		w := httptest.NewRecorder()
+		r := exampleRequest(pattern, &account.User{ID: 1, Role: account.RoleAdmin})
+		r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+		route.guarded()(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s without the token: status %d, want 403", pattern, w.Code)
+		}
+	}
+}
+
+// TestRegisterRoutes checks that the mux serves every route under its own pattern
+func TestRegisterRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	registerRoutes(mux, controllers{static: fstest.MapFS{}})
+	for _, route := range routes(controllers{}) {
+		if _, pattern := mux.Handler(exampleRequest(route.pattern, nil)); pattern != route.pattern {
+			t.Errorf("%s is served as %q", route.pattern, pattern)
+		}
+	}
+	if _, pattern := mux.Handler(exampleRequest("GET /api/v1/questions/{id}", nil)); pattern != "GET /api/v1/questions/{id}" {
+		t.Errorf("the api is served as %q", pattern)
+	}
+}
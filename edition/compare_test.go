@@ -0,0 +1,94 @@
+package edition
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/parser"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+func TestCompare(t *testing.T) {
+	questionText, err := os.ReadFile("testdata/questions.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	answerText, err := os.ReadFile("testdata/answers.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edition := parser.ParseEdition(string(questionText), string(answerText))
+	if len(edition.Problems) > 0 {
+		t.Fatalf("the fixtures have problems: %q", edition.Problems)
+	}
+
+	choices := func(answers ...string) []trainer.ChoiceContent {
+		var c []trainer.ChoiceContent
+		for _, choice := range []trainer.ChoiceContent{{Option: "a", Text: "Free-throw"}, {Option: "b", Text: "Throw-in"}} {
+			choice.IsAnswer = slices.Contains(answers, choice.Option)
+			c = append(c, choice)
+		}
+		return c
+	}
+	current := []CurrentQuestion{
+		// 8.1 is the same as in the import
+		{ID: 1, RuleID: "8", QuestionNumber: 1, Content: trainer.QuestionContent{
+			Text:       "A player pushes an opponent in the back. Correct decision?",
+			Choices:    []trainer.ChoiceContent{{Option: "a", Text: "Free-throw", IsAnswer: true}, {Option: "b", Text: "2-minute suspension", IsAnswer: true}},
+			References: []string{"8:3"},
+		}},
+		// 8.2 has a new wording with the same answer
+		{ID: 2, RuleID: "8", QuestionNumber: 2, Content: trainer.QuestionContent{
+			Text:    "The goalkeeper leaves the area with the ball. Correct decision?",
+			Choices: choices("a"),
+		}},
+		// 8.3 has a new answer
+		{ID: 3, RuleID: "8", QuestionNumber: 3, Content: trainer.QuestionContent{
+			Text:    "The ball hits the referee. Correct decision?",
+			Choices: []trainer.ChoiceContent{{Option: "a", Text: "Play on"}, {Option: "b", Text: "Referee throw", IsAnswer: true}},
+		}},
+		// 8.9 is not in the import
+		{ID: 9, RuleID: "8", QuestionNumber: 9, Content: trainer.QuestionContent{Text: "Removed?", Choices: choices("b")}},
+	}
+	diff := Compare(current, []string{"8"}, edition.Questions)
+
+	if diff.UnchangedCount != 1 {
+		t.Errorf("UnchangedCount = %d, want 1", diff.UnchangedCount)
+	}
+	tests := []struct {
+		name           string
+		diffs          []QuestionDiff
+		numbers        []string
+		questionIDs    []int
+		answersChanged []bool
+	}{
+		{"added", diff.Added, []string{"SAR1"}, []int{0}, []bool{false}},
+		{"changed", diff.Changed, []string{"8.2", "8.3"}, []int{2, 3}, []bool{false, true}},
+		{"missing", diff.Missing, []string{"8.9"}, []int{9}, []bool{false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var numbers []string
+			var questionIDs []int
+			var answersChanged []bool
+			for _, d := range tt.diffs {
+				numbers = append(numbers, d.Number())
+				questionIDs = append(questionIDs, d.QuestionID)
+				answersChanged = append(answersChanged, d.IsAnswerChanged())
+			}
+			if !slices.Equal(numbers, tt.numbers) || !slices.Equal(questionIDs, tt.questionIDs) {
+				t.Errorf("questions %q with ids %v, want %q with %v", numbers, questionIDs, tt.numbers, tt.questionIDs)
+			}
+			if !slices.Equal(answersChanged, tt.answersChanged) {
+				t.Errorf("answers changed %v, want %v", answersChanged, tt.answersChanged)
+			}
+		})
+	}
+	if diff.AnswerChangeCount() != 1 {
+		t.Errorf("AnswerChangeCount() = %d, want 1", diff.AnswerChangeCount())
+	}
+	if !slices.Equal(diff.NewRules, []string{"SAR"}) {
+		t.Errorf("NewRules = %q, want [SAR]", diff.NewRules)
+	}
+}
@@ -0,0 +1,240 @@
+package edition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+const (
+	// maxUploadBytes bounds the two PDFs of an upload together
+	maxUploadBytes = 32 << 20
+	// ruleNameField is followed by the rule id in the names of the fields naming the new rules
+	ruleNameField = "rule_name_"
+)
+
+type Service interface {
+	Upload(ctx context.Context, userID int, questionFile string, questions io.Reader, answerFile string, answers io.Reader) (int, error)
+	ListImports(ctx context.Context) ([]Import, error)
+	GetImport(ctx context.Context, id int) (*Import, *Diff, error)
+	Publish(ctx context.Context, userID int, id int, ruleNames map[string]string) error
+}
+
+type Controller struct {
+	service Service
+	html    fs.FS
+	csrf    *internal.CSRF
+}
+
+func NewController(service Service, html fs.FS, csrf *internal.CSRF) *Controller {
+	return &Controller{
+		service: service,
+		html:    html,
+		csrf:    csrf,
+	}
+}
+
+type ImportsPageData struct {
+	Imports   []Import
+	Error     string
+	CSRFToken string
+}
+
+type ImportPageData struct {
+	Import *Import
+	Diff   *Diff
+	// RuleNames are the names posted for the new rules, by rule id
+	RuleNames map[string]string
+	Error     string
+	CSRFToken string
+}
+
+// Imports lists the latest imports with the form to upload a new edition
+func (c *Controller) Imports(w http.ResponseWriter, r *http.Request) {
+	c.renderImports(w, r, http.StatusOK, "")
+}
+
+// Upload parses the uploaded questions and answers PDFs into an import and redirects to its preview.
+// It checks the CSRF token itself once the size of the upload is bounded, as reading the token reads the whole form.
+func (c *Controller) Upload(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	err := r.ParseMultipartForm(maxUploadBytes)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.renderImports(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("The PDFs must be at most %d MB together", maxUploadBytes>>20))
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	err = c.csrf.Verify(r)
+	if err != nil {
+		http.Error(w, "The form has expired, please reload the page and submit it again.", http.StatusForbidden)
+		return
+	}
+	questions, questionHeader, err := r.FormFile("questions")
+	if errors.Is(err, http.ErrMissingFile) {
+		c.renderImports(w, r, http.StatusUnprocessableEntity, ErrMissingFile.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	defer questions.Close()
+	answers, answerHeader, err := r.FormFile("answers")
+	if errors.Is(err, http.ErrMissingFile) {
+		c.renderImports(w, r, http.StatusUnprocessableEntity, ErrMissingFile.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	defer answers.Close()
+
+	id, err := c.service.Upload(r.Context(), user.ID, questionHeader.Filename, questions, answerHeader.Filename, answers)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrUnreadablePDF) {
+		log.Printf("Error converting uploaded PDF: %s", err)
+		c.renderImports(w, r, http.StatusUnprocessableEntity, ErrUnreadablePDF.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving import: %s", err)
+		http.Error(w, "Error saving import", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/imports/%d", id), http.StatusSeeOther)
+}
+
+// Import previews an import, its problems and how it differs from the current edition
+func (c *Controller) Import(w http.ResponseWriter, r *http.Request) {
+	c.renderImport(w, r, http.StatusOK, "")
+}
+
+// Publish replaces the current edition with the import
+func (c *Controller) Publish(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	err = c.service.Publish(r.Context(), user.ID, id, postedRuleNames(r))
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrImportNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrAlreadyPublished) {
+		c.renderImport(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, ErrHasProblems) || errors.Is(err, ErrInvalidRuleName) {
+		c.renderImport(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error publishing import: %s", err)
+		http.Error(w, "Error publishing import", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/imports/%d", id), http.StatusSeeOther)
+}
+
+func (c *Controller) renderImports(w http.ResponseWriter, r *http.Request, status int, errorMessage string) {
+	imports, err := c.service.ListImports(r.Context())
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting imports: %s", err)
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting imports", http.StatusInternalServerError)
+		return
+	}
+	c.render(w, status, "admin/imports.tmpl", ImportsPageData{Imports: imports, Error: errorMessage, CSRFToken: token})
+}
+
+// renderImport renders the preview of the import in the path
+func (c *Controller) renderImport(w http.ResponseWriter, r *http.Request, status int, errorMessage string) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	imp, diff, err := c.service.GetImport(r.Context(), id)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrImportNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting import: %s", err)
+		http.Error(w, "Error getting import", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting import", http.StatusInternalServerError)
+		return
+	}
+	data := ImportPageData{Import: imp, Diff: diff, RuleNames: postedRuleNames(r), Error: errorMessage, CSRFToken: token}
+	c.render(w, status, "admin/import.tmpl", data)
+}
+
+// postedRuleNames returns the names posted for the new rules, by rule id
+func postedRuleNames(r *http.Request) map[string]string {
+	names := make(map[string]string)
+	for field, values := range r.PostForm {
+		if ruleID, ok := strings.CutPrefix(field, ruleNameField); ok && len(values) > 0 {
+			names[ruleID] = values[0]
+		}
+	}
+	return names
+}
+
+func (c *Controller) render(w http.ResponseWriter, status int, page string, data any) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	w.WriteHeader(status)
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
@@ -0,0 +1,56 @@
+package edition
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+// fakeUploadService counts the uploads it is given
+type fakeUploadService struct {
+	Service
+	uploads int
+}
+
+func (s *fakeUploadService) Upload(_ context.Context, _ int, _ string, _ io.Reader, _ string, _ io.Reader) (int, error) {
+	s.uploads++
+	return 1, nil
+}
+
+func TestUploadChecksCSRFToken(t *testing.T) {
+	for _, token := range []string{"token", "other"} {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField(internal.CSRFFieldName, token)
+		for _, field := range []string{"questions", "answers"} {
+			file, err := form.CreateFormFile(field, field+".pdf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.Write([]byte("%PDF"))
+		}
+		form.Close()
+		r := httptest.NewRequest(http.MethodPost, "/admin/imports", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+		r = r.WithContext(account.WithUser(r.Context(), &account.User{ID: 1, Role: account.RoleAdmin}))
+		service := &fakeUploadService{}
+		w := httptest.NewRecorder()
+		NewController(service, nil, internal.NewCSRF(false)).Upload(w, r)
+
+		wantStatus, wantUploads := http.StatusSeeOther, 1
+		if token != "token" {
+			wantStatus, wantUploads = http.StatusForbidden, 0
+		}
+		if w.Code != wantStatus || service.uploads != wantUploads {
+			t.Errorf("upload with token %q: status %d and %d uploads, want %d and %d", token, w.Code, service.uploads, wantStatus, wantUploads)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package edition
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aattwwss/ihf-referee-rules/parser"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+var (
+	ErrImportNotFound   = errors.New("import not found")
+	ErrAlreadyPublished = errors.New("the import has already been published")
+	ErrHasProblems      = errors.New("the documents have problems, fix them and upload the documents again")
+	ErrMissingFile      = errors.New("upload both the questions and the answers PDF")
+	ErrUnreadablePDF    = errors.New("the PDF could not be read")
+	// ErrInvalidRuleName is returned when a new rule is published without a name, the documents do not have them
+	ErrInvalidRuleName = errors.New("every new rule needs a name of at most 100 characters")
+)
+
+const maxRuleNameLength = 100
+
+// Import is an upload of the questions and answers documents of an edition, parsed and waiting to be published
+type Import struct {
+	ID           int
+	UserID       int
+	UserName     string
+	QuestionFile string
+	AnswerFile   string
+	// Questions are the parsed questions, only loaded for a single import
+	Questions     []parser.Question
+	QuestionCount int
+	// Problems are what was wrong in the documents, an import with problems cannot be published
+	Problems      []string
+	CreatedAt     time.Time
+	PublisherName *string
+	PublishedAt   *time.Time
+}
+
+func (i Import) IsPublished() bool {
+	return i.PublishedAt != nil
+}
+
+// CurrentQuestion is a question of the edition in the database
+type CurrentQuestion struct {
+	ID             int
+	RuleID         string
+	QuestionNumber int
+	Content        trainer.QuestionContent
+}
+
+type DiffKind string
+
+const (
+	DiffAdded     DiffKind = "added"
+	DiffChanged   DiffKind = "changed"
+	DiffUnchanged DiffKind = "unchanged"
+	// DiffMissing is a current question that is not in the import, it is kept when the import is published
+	// as answers, exams and sets refer to it
+	DiffMissing DiffKind = "missing"
+)
+
+// QuestionDiff is a question of the import compared with the current edition
+type QuestionDiff struct {
+	Kind DiffKind
+	// QuestionID is the id of the current question, zero for an added one
+	QuestionID     int
+	RuleID         string
+	QuestionNumber int
+	Before         trainer.QuestionContent
+	After          trainer.QuestionContent
+}
+
+// Number is the number of the question as shown to users, e.g. 18.7 or SAR1
+func (d QuestionDiff) Number() string {
+	if d.RuleID == "SAR" {
+		return fmt.Sprintf("%s%d", d.RuleID, d.QuestionNumber)
+	}
+	return fmt.Sprintf("%s.%d", d.RuleID, d.QuestionNumber)
+}
+
+func (d QuestionDiff) Changes() []trainer.RevisionChange {
+	return trainer.QuestionRevision{Before: d.Before, After: d.After}.Changes()
+}
+
+func (d QuestionDiff) IsAnswerChanged() bool {
+	return d.Kind == DiffChanged && trainer.IsAnswerChanged(d.Before, d.After)
+}
+
+// Diff is an import compared with the current edition
+type Diff struct {
+	Added          []QuestionDiff
+	Changed        []QuestionDiff
+	Missing        []QuestionDiff
+	UnchangedCount int
+	// NewRules are the rules of the import that are not in the database yet
+	NewRules []string
+}
+
+// ValidRuleNames returns the trimmed names given to the new rules, ErrInvalidRuleName if a name is missing or too long
+func ValidRuleNames(newRules []string, names map[string]string) (map[string]string, error) {
+	valid := make(map[string]string, len(newRules))
+	for _, ruleID := range newRules {
+		name := strings.TrimSpace(names[ruleID])
+		if name == "" || utf8.RuneCountInString(name) > maxRuleNameLength {
+			return nil, ErrInvalidRuleName
+		}
+		valid[ruleID] = name
+	}
+	return valid, nil
+}
+
+// AnswerChangeCount is the number of changed questions whose correct choices changed
+func (d Diff) AnswerChangeCount() int {
+	count := 0
+	for _, question := range d.Changed {
+		if question.IsAnswerChanged() {
+			count++
+		}
+	}
+	return count
+}
+
+// Compare compares the questions of an import with the current edition and its rules,
+// matching the questions by rule and question number
+func Compare(current []CurrentQuestion, rules []string, questions []parser.Question) Diff {
+	type key struct {
+		ruleID         string
+		questionNumber int
+	}
+	currentByKey := make(map[key]CurrentQuestion, len(current))
+	for _, question := range current {
+		currentByKey[key{question.RuleID, question.QuestionNumber}] = question
+	}
+	var diff Diff
+	imported := make(map[key]bool, len(questions))
+	for _, question := range questions {
+		k := key{question.Rule.ID, question.QuestionNumber}
+		imported[k] = true
+		if !slices.Contains(rules, question.Rule.ID) && !slices.Contains(diff.NewRules, question.Rule.ID) {
+			diff.NewRules = append(diff.NewRules, question.Rule.ID)
+		}
+		questionDiff := QuestionDiff{
+			Kind:           DiffAdded,
+			RuleID:         question.Rule.ID,
+			QuestionNumber: question.QuestionNumber,
+			After:          ContentOf(question),
+		}
+		existing, ok := currentByKey[k]
+		if !ok {
+			diff.Added = append(diff.Added, questionDiff)
+			continue
+		}
+		questionDiff.QuestionID = existing.ID
+		questionDiff.Before = existing.Content
+		if existing.Content.Equal(questionDiff.After) {
+			diff.UnchangedCount++
+			continue
+		}
+		questionDiff.Kind = DiffChanged
+		diff.Changed = append(diff.Changed, questionDiff)
+	}
+	for _, question := range current {
+		if imported[key{question.RuleID, question.QuestionNumber}] {
+			continue
+		}
+		diff.Missing = append(diff.Missing, QuestionDiff{
+			Kind:           DiffMissing,
+			QuestionID:     question.ID,
+			RuleID:         question.RuleID,
+			QuestionNumber: question.QuestionNumber,
+			Before:         question.Content,
+		})
+	}
+	return diff
+}
+
+// ContentOf returns the content of a parsed question as it is stored, with its choices in the order of their options
+func ContentOf(question parser.Question) trainer.QuestionContent {
+	content := trainer.QuestionContent{Text: strings.TrimSpace(question.Text)}
+	for _, choice := range question.Choices {
+		content.Choices = append(content.Choices, trainer.ChoiceContent{
+			Option:   choice.Option,
+			Text:     strings.TrimSpace(choice.Text),
+			IsAnswer: choice.IsAnswer,
+		})
+	}
+	slices.SortFunc(content.Choices, func(a, b trainer.ChoiceContent) int {
+		return strings.Compare(a.Option, b.Option)
+	})
+	for _, reference := range question.References {
+		if text := strings.TrimSpace(reference.Text); text != "" {
+			content.References = append(content.References, text)
+		}
+	}
+	return content
+}
@@ -0,0 +1,42 @@
+package edition
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestValidRuleNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		newRules []string
+		names    map[string]string
+		want     map[string]string
+		wantErr  error
+	}{
+		{"no new rules", nil, nil, map[string]string{}, nil},
+		{
+			"every new rule named",
+			[]string{"19", "SAR"},
+			map[string]string{"19": " Video Review ", "SAR": "Substitution Area Regulations", "8": "ignored"},
+			map[string]string{"19": "Video Review", "SAR": "Substitution Area Regulations"},
+			nil,
+		},
+		{"a new rule without a name", []string{"19", "20"}, map[string]string{"19": "Video Review"}, nil, ErrInvalidRuleName},
+		{"a blank name", []string{"19"}, map[string]string{"19": "   "}, nil, ErrInvalidRuleName},
+		{"a name too long", []string{"19"}, map[string]string{"19": strings.Repeat("é", maxRuleNameLength+1)}, nil, ErrInvalidRuleName},
+		{"the longest name", []string{"19"}, map[string]string{"19": strings.Repeat("é", maxRuleNameLength)}, map[string]string{"19": strings.Repeat("é", maxRuleNameLength)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidRuleNames(tt.newRules, tt.names)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidRuleNames() error = %v, want %v", err, tt.wantErr)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("ValidRuleNames() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package edition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aattwwss/ihf-referee-rules/parser"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const listImportsLimit = 20
+
+type EditionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *EditionRepository {
+	return &EditionRepository{
+		db: db,
+	}
+}
+
+// queryer runs queries on the pool or within a transaction
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (r *EditionRepository) InsertImport(ctx context.Context, imp Import) (int, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO edition_import (user_id, question_file, answer_file, questions, problems)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`)
+	questions, problems := imp.Questions, imp.Problems
+	if questions == nil {
+		questions = []parser.Question{}
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+	var id int
+	err := r.db.QueryRow(ctx, query, imp.UserID, imp.QuestionFile, imp.AnswerFile, questions, problems).Scan(&id)
+	return id, err
+}
+
+func (r *EditionRepository) FindImport(ctx context.Context, id int) (*Import, error) {
+	return findImport(ctx, r.db, id, "")
+}
+
+// findImport returns the import with its questions, lock is appended to the query to lock the row in a transaction
+func findImport(ctx context.Context, db queryer, id int, lock string) (*Import, error) {
+	query := fmt.Sprintf(`
+		SELECT i.id, i.user_id, u.name, i.question_file, i.answer_file, i.questions, jsonb_array_length(i.questions),
+			i.problems, i.created_at, p.name, i.published_at
+		FROM edition_import i JOIN "user" u ON u.id = i.user_id LEFT JOIN "user" p ON p.id = i.published_by
+		WHERE i.id = $1 %s
+	`, lock)
+	rows, err := db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	imp, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[Import])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// ListImports returns the latest imports without their questions, the latest first
+func (r *EditionRepository) ListImports(ctx context.Context) ([]Import, error) {
+	query := fmt.Sprintf(`
+		SELECT i.id, i.user_id, u.name, i.question_file, i.answer_file, '[]'::jsonb, jsonb_array_length(i.questions),
+			i.problems, i.created_at, p.name, i.published_at
+		FROM edition_import i JOIN "user" u ON u.id = i.user_id LEFT JOIN "user" p ON p.id = i.published_by
+		ORDER BY i.id DESC
+		LIMIT $1
+	`)
+	rows, err := r.db.Query(ctx, query, listImportsLimit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[Import])
+}
+
+// FindCurrentEdition returns every question in the database with its content, and the ids of the rules
+func (r *EditionRepository) FindCurrentEdition(ctx context.Context) ([]CurrentQuestion, []string, error) {
+	return findCurrentEdition(ctx, r.db)
+}
+
+func findCurrentEdition(ctx context.Context, db queryer) ([]CurrentQuestion, []string, error) {
+	query := fmt.Sprintf("SELECT id FROM rule ORDER BY sort_order")
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, nil, err
+	}
+	query = fmt.Sprintf("SELECT q.id, q.rule_id, q.question_number, q.text FROM question q ORDER BY q.rule_id, q.question_number")
+	rows, err = db.Query(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var questions []CurrentQuestion
+	index := make(map[int]int)
+	var question CurrentQuestion
+	_, err = pgx.ForEachRow(rows, []any{&question.ID, &question.RuleID, &question.QuestionNumber, &question.Content.Text}, func() error {
+		index[question.ID] = len(questions)
+		questions = append(questions, question)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	query = fmt.Sprintf("SELECT question_id, option, text, is_answer FROM choice ORDER BY question_id, option")
+	rows, err = db.Query(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var questionID int
+	var choice trainer.ChoiceContent
+	_, err = pgx.ForEachRow(rows, []any{&questionID, &choice.Option, &choice.Text, &choice.IsAnswer}, func() error {
+		if i, ok := index[questionID]; ok {
+			questions[i].Content.Choices = append(questions[i].Content.Choices, choice)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	query = fmt.Sprintf("SELECT question_id, text FROM reference ORDER BY question_id, id")
+	rows, err = db.Query(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reference string
+	_, err = pgx.ForEachRow(rows, []any{&questionID, &reference}, func() error {
+		if i, ok := index[questionID]; ok {
+			questions[i].Content.References = append(questions[i].Content.References, reference)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return questions, rules, nil
+}
+
+// PublishImport replaces the current edition with the questions of the import in a single transaction.
+// New rules and questions are added, changed questions are updated with a revision by the user so that they show
+// in their history, and questions missing from the import are kept. The questions are locked against edits meanwhile.
+func (r *EditionRepository) PublishImport(ctx context.Context, importID int, userID int, ruleNames map[string]string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	imp, err := findImport(ctx, tx, importID, "FOR UPDATE OF i")
+	if err != nil {
+		return err
+	}
+	if imp.IsPublished() {
+		return ErrAlreadyPublished
+	}
+	if len(imp.Problems) > 0 {
+		return ErrHasProblems
+	}
+	query := fmt.Sprintf("LOCK TABLE question IN EXCLUSIVE MODE")
+	_, err = tx.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+	current, rules, err := findCurrentEdition(ctx, tx)
+	if err != nil {
+		return err
+	}
+	diff := Compare(current, rules, imp.Questions)
+	// the rules added since the preview have not been named
+	ruleNames, err = ValidRuleNames(diff.NewRules, ruleNames)
+	if err != nil {
+		return err
+	}
+
+	for _, ruleID := range diff.NewRules {
+		query = fmt.Sprintf("INSERT INTO rule (id, name, sort_order) VALUES ($1, $2, (SELECT coalesce(max(sort_order), 0) + 1 FROM rule))")
+		_, err = tx.Exec(ctx, query, ruleID, ruleNames[ruleID])
+		if err != nil {
+			return err
+		}
+	}
+	if len(diff.Added) > 0 {
+		// the questions were first loaded with their ids, move the identity past them before adding more
+		query = fmt.Sprintf("SELECT setval(pg_get_serial_sequence('question', 'id'), greatest((SELECT max(id) FROM question), 1))")
+		_, err = tx.Exec(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+	for _, question := range diff.Added {
+		query = fmt.Sprintf("INSERT INTO question (text, rule_id, question_number, tsv) VALUES ($1, $2, $3, setweight(to_tsvector($1), 'A')) RETURNING id")
+		var questionID int
+		err = tx.QueryRow(ctx, query, question.After.Text, question.RuleID, question.QuestionNumber).Scan(&questionID)
+		if err != nil {
+			return err
+		}
+		err = trainer.WriteQuestionContent(ctx, tx, questionID, question.After)
+		if err != nil {
+			return err
+		}
+	}
+	for _, question := range diff.Changed {
+		query = fmt.Sprintf("UPDATE question SET text = $2, tsv = setweight(to_tsvector($2), 'A') WHERE id = $1")
+		_, err = tx.Exec(ctx, query, question.QuestionID, question.After.Text)
+		if err != nil {
+			return err
+		}
+		err = trainer.WriteQuestionContent(ctx, tx, question.QuestionID, question.After)
+		if err != nil {
+			return err
+		}
+		query = fmt.Sprintf(`
+			INSERT INTO question_revision (question_id, user_id, before, after, is_answer_changed)
+			VALUES ($1, $2, $3, $4, $5)
+		`)
+		_, err = tx.Exec(ctx, query, question.QuestionID, userID, question.Before, question.After, question.IsAnswerChanged())
+		if err != nil {
+			return err
+		}
+	}
+	// new words of the texts are offered as search corrections
+	query = fmt.Sprintf(`
+		INSERT INTO search_word
+		SELECT DISTINCT unnest(tsvector_to_array(to_tsvector('simple', text))) FROM question
+		ON CONFLICT DO NOTHING
+	`)
+	_, err = tx.Exec(ctx, query)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf("UPDATE edition_import SET published_by = $2, published_at = now() WHERE id = $1")
+	_, err = tx.Exec(ctx, query, importID, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,91 @@
+package edition
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/parser"
+	"github.com/aattwwss/ihf-referee-rules/pdf"
+)
+
+type Repository interface {
+	InsertImport(ctx context.Context, imp Import) (int, error)
+	FindImport(ctx context.Context, id int) (*Import, error)
+	ListImports(ctx context.Context) ([]Import, error)
+	FindCurrentEdition(ctx context.Context) ([]CurrentQuestion, []string, error)
+	PublishImport(ctx context.Context, importID int, userID int, ruleNames map[string]string) error
+}
+
+type EditionService struct {
+	repository Repository
+}
+
+func NewService(repository Repository) *EditionService {
+	return &EditionService{
+		repository: repository,
+	}
+}
+
+// Upload converts the questions and answers PDFs to text the way cmd/parse does and saves the parsed edition
+// with its problems as an import, returning its id
+func (s *EditionService) Upload(ctx context.Context, userID int, questionFile string, questions io.Reader, answerFile string, answers io.Reader) (int, error) {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return 0, err
+	}
+	questionText, err := pdf.PdfToText(questions)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %w", ErrUnreadablePDF, questionFile, err)
+	}
+	answerText, err := pdf.PdfToText(answers)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s: %w", ErrUnreadablePDF, answerFile, err)
+	}
+	edition := parser.ParseEdition(questionText, answerText)
+	return s.repository.InsertImport(ctx, Import{
+		UserID:       userID,
+		QuestionFile: questionFile,
+		AnswerFile:   answerFile,
+		Questions:    edition.Questions,
+		Problems:     edition.Problems,
+	})
+}
+
+func (s *EditionService) ListImports(ctx context.Context) ([]Import, error) {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.ListImports(ctx)
+}
+
+// GetImport returns the import with its questions compared with the current edition
+func (s *EditionService) GetImport(ctx context.Context, id int) (*Import, *Diff, error) {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return nil, nil, err
+	}
+	imp, err := s.repository.FindImport(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, rules, err := s.repository.FindCurrentEdition(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	diff := Compare(current, rules, imp.Questions)
+	return imp, &diff, nil
+}
+
+// Publish applies the import to the questions, the comparison is made again within the transaction
+// so that edits made since the preview are accounted for. The new rules are created with the names
+// confirmed by the admin, by rule id.
+func (s *EditionService) Publish(ctx context.Context, userID int, id int, ruleNames map[string]string) error {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return err
+	}
+	return s.repository.PublishImport(ctx, id, userID, ruleNames)
+}
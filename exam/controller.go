@@ -0,0 +1,372 @@
+package exam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+type Service interface {
+	GetTemplates(ctx context.Context) ([]Template, error)
+	ListExams(ctx context.Context, userID int) ([]Exam, error)
+	StartExam(ctx context.Context, userID int, templateID int) (int, error)
+	GetOpenExam(ctx context.Context, userID int, examID int) (*Exam, []trainer.Question, error)
+	SaveSelection(ctx context.Context, userID int, examID int, position int, selected []string) error
+	Submit(ctx context.Context, userID int, examID int) (*Result, error)
+	GetResult(ctx context.Context, userID int, examID int) (*Result, error)
+}
+
+type Controller struct {
+	service Service
+	html    fs.FS
+}
+
+func NewController(service Service, html fs.FS) *Controller {
+	return &Controller{
+		service: service,
+		html:    html,
+	}
+}
+
+type ExamsPageData struct {
+	Templates []TemplateData
+	Exams     []ExamData
+	Error     string
+}
+
+type TemplateData struct {
+	ID              int
+	Name            string
+	Description     string
+	QuestionCount   int
+	DurationMinutes int
+	PassMark        int
+	Scoring         string
+}
+
+type ExamData struct {
+	ID          int
+	Name        string
+	StartedAt   string
+	IsSubmitted bool
+	IsOpen      bool
+	Score       string
+	IsPassed    bool
+}
+
+type ExamPageData struct {
+	ID   int
+	Name string
+	// DeadlineUnixMilli is used by the countdown, the deadline itself is enforced by the server
+	DeadlineUnixMilli int64
+	Questions         []QuestionData
+}
+
+type QuestionData struct {
+	Position           int
+	RuleQuestionNumber string
+	Text               string
+	Choices            []ChoiceData
+}
+
+type ChoiceData struct {
+	Option     string
+	Text       string
+	IsSelected bool
+}
+
+type ResultPageData struct {
+	ID           int
+	Name         string
+	Scoring      string
+	CorrectCount int
+	Score        string
+	Total        int
+	Percentage   int
+	PassMark     int
+	IsPassed     bool
+	Rules        []RuleResultData
+	Questions    []QuestionResultData
+}
+
+type RuleResultData struct {
+	Name         string
+	CorrectCount int
+	Score        string
+	Total        int
+	Percentage   int
+}
+
+type QuestionResultData struct {
+	Position           int
+	RuleQuestionNumber string
+	Text               string
+	IsCorrect          bool
+	Score              string
+	Choices            []trainer.ChoiceVerdict
+}
+
+// Exams lists the exam templates to start and the exams taken by the user
+func (c *Controller) Exams(w http.ResponseWriter, r *http.Request) {
+	c.renderExams(w, r, "")
+}
+
+// Start starts an exam of the submitted template
+func (c *Controller) Start(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	templateID, err := strconv.Atoi(r.Form.Get("template"))
+	if err != nil {
+		http.Error(w, "Invalid exam template", http.StatusBadRequest)
+		return
+	}
+	examID, err := c.service.StartExam(r.Context(), user.ID, templateID)
+	if errors.Is(err, ErrTemplateNotFound) || errors.Is(err, ErrNoQuestions) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderExams(w, r, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting exam: %s", err)
+		http.Error(w, "Error starting exam", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/mock-exams/%d", examID), http.StatusSeeOther)
+}
+
+// Exam renders an open exam without the answers, closed exams redirect to their result
+func (c *Controller) Exam(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	examID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	exam, questions, err := c.service.GetOpenExam(r.Context(), user.ID, examID)
+	if errors.Is(err, ErrExamClosed) {
+		http.Redirect(w, r, fmt.Sprintf("/mock-exams/%d/result", examID), http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, ErrExamNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting exam: %s", err)
+		http.Error(w, "Error getting exam", http.StatusInternalServerError)
+		return
+	}
+
+	data := ExamPageData{
+		ID:                exam.ID,
+		Name:              exam.Template.Name,
+		DeadlineUnixMilli: exam.Deadline.UnixMilli(),
+	}
+	for i, question := range questions {
+		examQuestion := exam.Questions[i]
+		var choices []ChoiceData
+		for _, choice := range question.Choices {
+			choices = append(choices, ChoiceData{
+				Option:     choice.Option,
+				Text:       choice.Text,
+				IsSelected: slices.Contains(examQuestion.SelectedOptions, choice.Option),
+			})
+		}
+		data.Questions = append(data.Questions, QuestionData{
+			Position:           examQuestion.Position,
+			RuleQuestionNumber: question.RuleQuestionNumber,
+			Text:               question.Text,
+			Choices:            choices,
+		})
+	}
+	c.render(w, "exam/mockExam.tmpl", data)
+}
+
+// SaveSelection saves the options selected for a question, sent by htmx whenever a choice changes
+func (c *Controller) SaveSelection(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	examID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	position, err := strconv.Atoi(r.PathValue("position"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	err = c.service.SaveSelection(r.Context(), user.ID, examID, position, r.Form["choices"])
+	if errors.Is(err, ErrExamClosed) {
+		w.Header().Set("HX-Redirect", fmt.Sprintf("/mock-exams/%d/result", examID))
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrExamNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, trainer.ErrInvalidOption) {
+		http.Error(w, "Invalid option", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving exam selection: %s", err)
+		http.Error(w, "Error saving answer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Submit closes the exam and redirects to its result
+func (c *Controller) Submit(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	examID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, err = c.service.Submit(r.Context(), user.ID, examID)
+	if errors.Is(err, ErrExamNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error submitting exam: %s", err)
+		http.Error(w, "Error submitting exam", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/mock-exams/%d/result", examID), http.StatusSeeOther)
+}
+
+// Result renders the score of a closed exam broken down by rule, with the answers revealed
+func (c *Controller) Result(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	examID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	result, err := c.service.GetResult(r.Context(), user.ID, examID)
+	if errors.Is(err, ErrExamInProgress) {
+		http.Redirect(w, r, fmt.Sprintf("/mock-exams/%d", examID), http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, ErrExamNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting exam result: %s", err)
+		http.Error(w, "Error getting exam result", http.StatusInternalServerError)
+		return
+	}
+
+	data := ResultPageData{
+		ID:           result.Exam.ID,
+		Name:         result.Exam.Template.Name,
+		Scoring:      result.Exam.Template.Scoring.Description(),
+		CorrectCount: result.Exam.CorrectCount,
+		Score:        formatScore(result.Exam.Score),
+		Total:        len(result.Questions),
+		Percentage:   result.Percentage,
+		PassMark:     result.Exam.Template.PassMark,
+		IsPassed:     result.Exam.IsPassed,
+	}
+	for _, rule := range result.Rules {
+		data.Rules = append(data.Rules, RuleResultData{
+			Name:         rule.Rule.Name,
+			CorrectCount: rule.CorrectCount,
+			Score:        formatScore(rule.Score),
+			Total:        rule.Total,
+			Percentage:   rule.Percentage(),
+		})
+	}
+	for _, question := range result.Questions {
+		data.Questions = append(data.Questions, QuestionResultData{
+			Position:           question.Position,
+			RuleQuestionNumber: question.Question.RuleQuestionNumber,
+			Text:               question.Question.Text,
+			IsCorrect:          question.IsCorrect,
+			Score:              formatScore(question.Score),
+			Choices:            question.Result.Choices,
+		})
+	}
+	c.render(w, "exam/mockExamResult.tmpl", data)
+}
+
+func (c *Controller) renderExams(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	user := account.UserFromContext(r.Context())
+	templates, err := c.service.GetTemplates(r.Context())
+	if err != nil {
+		log.Printf("Error getting exam templates: %s", err)
+	}
+	exams, err := c.service.ListExams(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error getting exams: %s", err)
+	}
+
+	data := ExamsPageData{Error: errorMessage}
+	for _, template := range templates {
+		data.Templates = append(data.Templates, TemplateData{
+			ID:              template.ID,
+			Name:            template.Name,
+			Description:     template.Description,
+			QuestionCount:   template.QuestionCount(),
+			DurationMinutes: int(template.Duration.Minutes()),
+			PassMark:        template.PassMark,
+			Scoring:         template.Scoring.Description(),
+		})
+	}
+	now := time.Now()
+	for _, exam := range exams {
+		examData := ExamData{
+			ID:          exam.ID,
+			Name:        exam.Template.Name,
+			StartedAt:   exam.StartedAt.Format("2 Jan 2006 15:04"),
+			IsSubmitted: exam.IsSubmitted(),
+			IsOpen:      !exam.IsClosed(now),
+			IsPassed:    exam.IsPassed,
+		}
+		if exam.IsSubmitted() {
+			examData.Score = fmt.Sprintf("%s / %d", formatScore(exam.Score), exam.QuestionCount)
+		}
+		data.Exams = append(data.Exams, examData)
+	}
+	c.render(w, "exam/mockExams.tmpl", data)
+}
+
+// formatScore shows a score with at most 2 decimals, whole scores without any
+func formatScore(score float64) string {
+	return strconv.FormatFloat(math.Round(score*100)/100, 'f', -1, 64)
+}
+
+func (c *Controller) render(w http.ResponseWriter, page string, data any) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
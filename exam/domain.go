@@ -0,0 +1,292 @@
+package exam
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+// gracePeriod is how long after the deadline answers are still accepted, to allow for network latency
+const gracePeriod = 10 * time.Second
+
+var (
+	ErrTemplateNotFound = errors.New("exam template not found")
+	ErrExamNotFound     = errors.New("exam not found")
+	// ErrExamClosed is returned when changing an exam that was submitted or whose deadline has passed
+	ErrExamClosed = errors.New("exam is closed")
+	// ErrExamInProgress is returned when asking for the result of an exam that can still be answered
+	ErrExamInProgress = errors.New("exam is in progress")
+	ErrNoQuestions    = errors.New("exam template has no questions")
+)
+
+type TemplateEntity struct {
+	ID              int
+	Name            string
+	Description     string
+	DurationMinutes int
+	PassMark        int
+	Scoring         string
+	IsPublic        bool
+}
+
+type TemplateQuestionEntity struct {
+	TemplateID int
+	Position   int
+	QuestionID int
+}
+
+type TemplateRuleEntity struct {
+	TemplateID    int
+	RuleID        string
+	QuestionCount int
+}
+
+type ExamEntity struct {
+	ID            int
+	TemplateID    int
+	UserID        int
+	StartedAt     time.Time
+	Deadline      time.Time
+	SubmittedAt   *time.Time
+	CorrectCount  *int
+	Score         *float64
+	IsPassed      *bool
+	QuestionCount int
+}
+
+type ExamQuestionEntity struct {
+	ExamID          int
+	Position        int
+	QuestionID      int
+	SelectedOptions []string
+	IsCorrect       *bool
+	Score           *float64
+	Choices         []GradedChoiceEntity
+}
+
+// GradedChoiceEntity is a choice of a question as it was graded when the exam was submitted
+type GradedChoiceEntity struct {
+	Option   string          `json:"option"`
+	Text     string          `json:"text"`
+	IsAnswer bool            `json:"is_answer"`
+	Selected bool            `json:"selected"`
+	Verdict  trainer.Verdict `json:"verdict"`
+}
+
+// Template describes a kind of exam, how many questions of each rule or which questions, how long it lasts,
+// how the answers are scored and the mark to pass
+type Template struct {
+	ID          int
+	Name        string
+	Description string
+	Duration    time.Duration
+	// PassMark is the percentage of the maximum score needed to pass
+	PassMark int
+	Scoring  trainer.Scoring
+	// IsPublic templates are offered to every user, the others are only taken through an assignment
+	IsPublic bool
+	Rules    []TemplateRule
+	// QuestionIDs are the questions of every exam of the template in order, when empty they are drawn from Rules
+	QuestionIDs []int
+}
+
+type TemplateRule struct {
+	RuleID        string
+	QuestionCount int
+}
+
+func (t Template) QuestionCount() int {
+	if len(t.QuestionIDs) > 0 {
+		return len(t.QuestionIDs)
+	}
+	count := 0
+	for _, rule := range t.Rules {
+		count += rule.QuestionCount
+	}
+	return count
+}
+
+// Exam is an instance of a template taken by a user, with its questions drawn when it was started
+type Exam struct {
+	ID          int
+	Template    Template
+	UserID      int
+	StartedAt   time.Time
+	Deadline    time.Time
+	SubmittedAt *time.Time
+	// CorrectCount, Score and IsPassed are only set once the exam is submitted
+	CorrectCount  int
+	Score         float64
+	IsPassed      bool
+	QuestionCount int
+	Questions     []ExamQuestion
+}
+
+type ExamQuestion struct {
+	Position        int
+	QuestionID      int
+	SelectedOptions []string
+	// IsCorrect, Score and Choices are the grading saved when the exam is submitted,
+	// Choices is empty for the exams submitted before the choices were saved
+	IsCorrect bool
+	Score     float64
+	Choices   []trainer.ChoiceVerdict
+}
+
+func (e Exam) IsSubmitted() bool {
+	return e.SubmittedAt != nil
+}
+
+// IsClosed returns whether the exam can no longer be answered
+func (e Exam) IsClosed(now time.Time) bool {
+	return e.IsSubmitted() || now.After(e.Deadline.Add(gracePeriod))
+}
+
+// GradedQuestion is the grading of a question when an exam is submitted
+type GradedQuestion struct {
+	Position  int
+	IsCorrect bool
+	Score     float64
+	Choices   []trainer.ChoiceVerdict
+}
+
+// Result is a submitted exam with the answers revealed and broken down by rule
+type Result struct {
+	Exam       Exam
+	Questions  []QuestionResult
+	Rules      []RuleResult
+	Percentage int
+}
+
+type QuestionResult struct {
+	Position  int
+	Question  trainer.Question
+	Result    trainer.AnswerResult
+	IsCorrect bool
+	Score     float64
+}
+
+type RuleResult struct {
+	Rule         trainer.Rule
+	CorrectCount int
+	Score        float64
+	Total        int
+}
+
+func (r RuleResult) Percentage() int {
+	return percentage(r.Score, r.Total)
+}
+
+// scoreEpsilon absorbs the rounding errors of adding up partial scores such as 1/3
+const scoreEpsilon = 1e-9
+
+// percentage returns the score as a percentage of the maximum score rounded down, every question being worth 1 point
+func percentage(score float64, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return int(math.Floor(score*100/float64(total) + scoreEpsilon))
+}
+
+// passes tells if the score reaches the pass mark, a percentage of the maximum score
+func passes(score float64, total int, passMark int) bool {
+	return score*100+scoreEpsilon >= float64(passMark*total)
+}
+
+// grade grades the options selected in the exam with the scoring method of its template,
+// the questions are matched to the exam by id
+func grade(exam Exam, questions []trainer.Question) ([]GradedQuestion, error) {
+	byID := questionsByID(questions)
+	gradedQuestions := make([]GradedQuestion, 0, len(exam.Questions))
+	for _, examQuestion := range exam.Questions {
+		question, ok := byID[examQuestion.QuestionID]
+		if !ok {
+			return nil, trainer.ErrQuestionNotFound
+		}
+		answerResult := gradeChoices(question, examQuestion.SelectedOptions)
+		gradedQuestions = append(gradedQuestions, GradedQuestion{
+			Position:  examQuestion.Position,
+			IsCorrect: answerResult.IsCorrect,
+			Score:     exam.Template.Scoring.Score(answerResult),
+			Choices:   answerResult.Choices,
+		})
+	}
+	return gradedQuestions, nil
+}
+
+// gradeChoices grades the options selected against the current choices of the question. An option removed
+// from the question since it was selected is left out, so that an edit cannot make the exam impossible to grade.
+func gradeChoices(question trainer.Question, selected []string) *trainer.AnswerResult {
+	selected = slices.DeleteFunc(slices.Clone(selected), func(option string) bool {
+		return !slices.ContainsFunc(question.Choices, func(choice trainer.Choice) bool { return choice.Option == option })
+	})
+	answerResult, _ := trainer.GradeAnswer(question.ID, question.Choices, selected)
+	return answerResult
+}
+
+// totals returns the number of fully correct questions and the score of the graded questions
+func totals(gradedQuestions []GradedQuestion) (int, float64) {
+	correctCount := 0
+	score := 0.0
+	for _, gradedQuestion := range gradedQuestions {
+		if gradedQuestion.IsCorrect {
+			correctCount++
+		}
+		score += gradedQuestion.Score
+	}
+	return correctCount, score
+}
+
+// newResult breaks the grading saved when the exam was submitted down by question and rule, so that the result
+// always agrees with the score and pass of the exam whatever was edited in the questions since
+func newResult(exam Exam, questions []trainer.Question) (*Result, error) {
+	byID := questionsByID(questions)
+	result := &Result{
+		Exam:       exam,
+		Percentage: percentage(exam.Score, len(exam.Questions)),
+	}
+	rulesMap := make(map[string]*RuleResult)
+	for _, examQuestion := range exam.Questions {
+		question, ok := byID[examQuestion.QuestionID]
+		if !ok {
+			return nil, trainer.ErrQuestionNotFound
+		}
+		answerResult := trainer.AnswerResult{QuestionID: question.ID, Choices: examQuestion.Choices, IsCorrect: examQuestion.IsCorrect}
+		if len(examQuestion.Choices) == 0 {
+			answerResult.Choices = gradeChoices(question, examQuestion.SelectedOptions).Choices
+		}
+		result.Questions = append(result.Questions, QuestionResult{
+			Position:  examQuestion.Position,
+			Question:  question,
+			Result:    answerResult,
+			IsCorrect: examQuestion.IsCorrect,
+			Score:     examQuestion.Score,
+		})
+		ruleResult, ok := rulesMap[question.Rule.ID]
+		if !ok {
+			ruleResult = &RuleResult{Rule: question.Rule}
+			rulesMap[question.Rule.ID] = ruleResult
+		}
+		ruleResult.Total++
+		ruleResult.Score += examQuestion.Score
+		if examQuestion.IsCorrect {
+			ruleResult.CorrectCount++
+		}
+	}
+	for _, ruleResult := range rulesMap {
+		result.Rules = append(result.Rules, *ruleResult)
+	}
+	slices.SortFunc(result.Rules, func(a, b RuleResult) int { return a.Rule.SortOrder - b.Rule.SortOrder })
+	return result, nil
+}
+
+func questionsByID(questions []trainer.Question) map[int]trainer.Question {
+	byID := make(map[int]trainer.Question, len(questions))
+	for _, question := range questions {
+		byID[question.ID] = question
+	}
+	return byID
+}
@@ -0,0 +1,37 @@
+package exam
+
+import "testing"
+
+func TestPassMark(t *testing.T) {
+	// three questions scored 1/3 each add up to slightly less than 1
+	third := 1.0 / 3
+	sumOfThirds := third + third + third
+	tests := []struct {
+		name       string
+		score      float64
+		total      int
+		passMark   int
+		percentage int
+		passed     bool
+	}{
+		{"exactly the pass mark", 7, 10, 70, 70, true},
+		{"just below the pass mark", 6.9, 10, 70, 69, false},
+		{"above the pass mark", 8, 10, 70, 80, true},
+		{"sum of partial scores at the pass mark", sumOfThirds + 6, 10, 70, 70, true},
+		{"0.29 of a question", 0.29, 1, 29, 29, true},
+		{"0.57 of a question", 0.57, 1, 57, 57, true},
+		{"rounded down", 2, 3, 67, 66, false},
+		{"no questions", 0, 0, 0, 0, true},
+		{"nothing right", 0, 5, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentage(tt.score, tt.total); got != tt.percentage {
+				t.Errorf("percentage(%v, %d) = %d, want %d", tt.score, tt.total, got, tt.percentage)
+			}
+			if got := passes(tt.score, tt.total, tt.passMark); got != tt.passed {
+				t.Errorf("passes(%v, %d, %d) = %t, want %t", tt.score, tt.total, tt.passMark, got, tt.passed)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package exam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExamRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *ExamRepository {
+	return &ExamRepository{
+		db: db,
+	}
+}
+
+// GetAllTemplates returns the public templates
+func (r *ExamRepository) GetAllTemplates(ctx context.Context) ([]Template, error) {
+	return r.findTemplates(ctx, true)
+}
+
+// findTemplates returns the templates ordered by name, only the public ones if publicOnly is true
+func (r *ExamRepository) findTemplates(ctx context.Context, publicOnly bool) ([]Template, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, description, duration_minutes, pass_mark, scoring, is_public
+		FROM exam_template WHERE is_public OR NOT $1 ORDER BY name
+	`)
+	rows, err := r.db.Query(ctx, query, publicOnly)
+	if err != nil {
+		return nil, err
+	}
+	templateEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[TemplateEntity])
+	if err != nil {
+		return nil, err
+	}
+	rulesMap, err := r.findTemplateRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	questionsMap, err := r.findTemplateQuestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var templates []Template
+	for _, templateEntity := range templateEntities {
+		templates = append(templates, toTemplate(templateEntity, rulesMap[templateEntity.ID], questionsMap[templateEntity.ID]))
+	}
+	return templates, nil
+}
+
+func (r *ExamRepository) FindTemplateByID(ctx context.Context, id int) (*Template, error) {
+	query := fmt.Sprintf("SELECT id, name, description, duration_minutes, pass_mark, scoring, is_public FROM exam_template WHERE id = $1")
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	templateEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[TemplateEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	rulesMap, err := r.findTemplateRules(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	questionsMap, err := r.findTemplateQuestions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	template := toTemplate(templateEntity, rulesMap[id], questionsMap[id])
+	return &template, nil
+}
+
+// findTemplateQuestions returns a map of template id to its questions in order, of all templates when no id is given
+func (r *ExamRepository) findTemplateQuestions(ctx context.Context, templateIDs ...int) (map[int][]int, error) {
+	query := fmt.Sprintf(`
+		SELECT exam_template_id, position, question_id
+		FROM exam_template_question
+		WHERE cardinality($1::bigint[]) = 0 OR exam_template_id = ANY($1)
+		ORDER BY exam_template_id, position
+	`)
+	if templateIDs == nil {
+		templateIDs = []int{}
+	}
+	rows, err := r.db.Query(ctx, query, templateIDs)
+	if err != nil {
+		return nil, err
+	}
+	questionEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[TemplateQuestionEntity])
+	if err != nil {
+		return nil, err
+	}
+	questionsMap := make(map[int][]int)
+	for _, questionEntity := range questionEntities {
+		questionsMap[questionEntity.TemplateID] = append(questionsMap[questionEntity.TemplateID], questionEntity.QuestionID)
+	}
+	return questionsMap, nil
+}
+
+// CreateTemplate saves the template with its rules or questions and returns its id
+func (r *ExamRepository) CreateTemplate(ctx context.Context, template Template) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	templateID, err := InsertTemplate(ctx, tx, template)
+	if err != nil {
+		return 0, err
+	}
+	return templateID, tx.Commit(ctx)
+}
+
+// InsertTemplate saves the template with its rules or questions in the transaction and returns its id,
+// for the callers that save a template together with what uses it
+func InsertTemplate(ctx context.Context, tx pgx.Tx, template Template) (int, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO exam_template (name, description, duration_minutes, pass_mark, scoring, is_public)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`)
+	var templateID int
+	err := tx.QueryRow(ctx, query, template.Name, template.Description, int(template.Duration.Minutes()),
+		template.PassMark, template.Scoring, template.IsPublic).Scan(&templateID)
+	if err != nil {
+		return 0, err
+	}
+	for _, rule := range template.Rules {
+		query = fmt.Sprintf("INSERT INTO exam_template_rule (exam_template_id, rule_id, question_count) VALUES ($1, $2, $3)")
+		_, err = tx.Exec(ctx, query, templateID, rule.RuleID, rule.QuestionCount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(template.QuestionIDs) > 0 {
+		query = fmt.Sprintf(`
+			INSERT INTO exam_template_question (exam_template_id, position, question_id)
+			SELECT $1, q.position, q.id FROM unnest($2::bigint[]) WITH ORDINALITY AS q(id, position)
+		`)
+		_, err = tx.Exec(ctx, query, templateID, template.QuestionIDs)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return templateID, nil
+}
+
+// findTemplateRules returns a map of template id to its rules, of all templates when no id is given
+func (r *ExamRepository) findTemplateRules(ctx context.Context, templateIDs ...int) (map[int][]TemplateRule, error) {
+	query := fmt.Sprintf(`
+		SELECT tr.exam_template_id, tr.rule_id, tr.question_count
+		FROM exam_template_rule tr JOIN rule r ON tr.rule_id = r.id
+		WHERE cardinality($1::bigint[]) = 0 OR tr.exam_template_id = ANY($1)
+		ORDER BY r.sort_order
+	`)
+	if templateIDs == nil {
+		templateIDs = []int{}
+	}
+	rows, err := r.db.Query(ctx, query, templateIDs)
+	if err != nil {
+		return nil, err
+	}
+	ruleEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[TemplateRuleEntity])
+	if err != nil {
+		return nil, err
+	}
+	rulesMap := make(map[int][]TemplateRule)
+	for _, ruleEntity := range ruleEntities {
+		rulesMap[ruleEntity.TemplateID] = append(rulesMap[ruleEntity.TemplateID], TemplateRule{
+			RuleID:        ruleEntity.RuleID,
+			QuestionCount: ruleEntity.QuestionCount,
+		})
+	}
+	return rulesMap, nil
+}
+
+// CreateExam starts an exam of the template for the user, with the questions of the template or drawing the number
+// of questions of each rule at random without repeats, and returns the id of the exam
+func (r *ExamRepository) CreateExam(ctx context.Context, template Template, userID int) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		INSERT INTO exam (exam_template_id, user_id, deadline)
+		VALUES ($1, $2, now() + make_interval(mins => $3))
+		RETURNING id
+	`)
+	var examID int
+	err = tx.QueryRow(ctx, query, template.ID, userID, int(template.Duration.Minutes())).Scan(&examID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = fmt.Sprintf(`
+		INSERT INTO exam_question (exam_id, position, question_id)
+		SELECT $1, position, question_id FROM exam_template_question WHERE exam_template_id = $2
+	`)
+	if len(template.QuestionIDs) == 0 {
+		query = fmt.Sprintf(`
+		INSERT INTO exam_question (exam_id, position, question_id)
+		SELECT $1, row_number() OVER (ORDER BY r.sort_order, drawn.draw), drawn.id
+		FROM (
+			SELECT q.id, q.rule_id, tr.question_count,
+				row_number() OVER (PARTITION BY q.rule_id ORDER BY RANDOM()) AS draw
+			FROM question q JOIN exam_template_rule tr ON q.rule_id = tr.rule_id
+			WHERE tr.exam_template_id = $2
+		) drawn JOIN rule r ON drawn.rule_id = r.id
+		WHERE drawn.draw <= drawn.question_count
+	`)
+	}
+	tag, err := tx.Exec(ctx, query, examID, template.ID)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, ErrNoQuestions
+	}
+	return examID, tx.Commit(ctx)
+}
+
+// FindExamByID returns the exam with its questions in order
+func (r *ExamRepository) FindExamByID(ctx context.Context, id int) (*Exam, error) {
+	query := fmt.Sprintf(`
+		SELECT id, exam_template_id, user_id, started_at, deadline, submitted_at, correct_count, score, is_passed,
+			(SELECT count(*) FROM exam_question WHERE exam_id = exam.id) AS question_count
+		FROM exam WHERE id = $1
+	`)
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	examEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[ExamEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrExamNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	template, err := r.FindTemplateByID(ctx, examEntity.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf(`
+		SELECT exam_id, position, question_id, selected_options, is_correct, score, choices
+		FROM exam_question WHERE exam_id = $1 ORDER BY position
+	`)
+	rows, err = r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	questionEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ExamQuestionEntity])
+	if err != nil {
+		return nil, err
+	}
+	exam := toExam(examEntity, *template)
+	for _, questionEntity := range questionEntities {
+		examQuestion := ExamQuestion{
+			Position:        questionEntity.Position,
+			QuestionID:      questionEntity.QuestionID,
+			SelectedOptions: questionEntity.SelectedOptions,
+		}
+		if questionEntity.IsCorrect != nil {
+			examQuestion.IsCorrect = *questionEntity.IsCorrect
+		}
+		if questionEntity.Score != nil {
+			examQuestion.Score = *questionEntity.Score
+		}
+		for _, choice := range questionEntity.Choices {
+			examQuestion.Choices = append(examQuestion.Choices, trainer.ChoiceVerdict{
+				Choice:   trainer.Choice{Option: choice.Option, Text: choice.Text, IsAnswer: choice.IsAnswer, IsSelected: choice.Selected},
+				Selected: choice.Selected,
+				Verdict:  choice.Verdict,
+			})
+		}
+		exam.Questions = append(exam.Questions, examQuestion)
+	}
+	return &exam, nil
+}
+
+// ListExamsByUserID returns the exams of a user, latest first, without their questions
+func (r *ExamRepository) ListExamsByUserID(ctx context.Context, userID int) ([]Exam, error) {
+	query := fmt.Sprintf(`
+		SELECT id, exam_template_id, user_id, started_at, deadline, submitted_at, correct_count, score, is_passed,
+			(SELECT count(*) FROM exam_question WHERE exam_id = exam.id) AS question_count
+		FROM exam WHERE user_id = $1 ORDER BY started_at DESC
+	`)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	examEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ExamEntity])
+	if err != nil {
+		return nil, err
+	}
+	templates, err := r.findTemplates(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	templatesMap := make(map[int]Template)
+	for _, template := range templates {
+		templatesMap[template.ID] = template
+	}
+	var exams []Exam
+	for _, examEntity := range examEntities {
+		exams = append(exams, toExam(examEntity, templatesMap[examEntity.TemplateID]))
+	}
+	return exams, nil
+}
+
+// SaveSelection saves the options selected for a question while the exam is open,
+// the deadline is checked by the database so that a slow request cannot sneak in a late answer
+func (r *ExamRepository) SaveSelection(ctx context.Context, examID int, position int, selected []string) error {
+	if selected == nil {
+		selected = []string{}
+	}
+	query := fmt.Sprintf(`
+		UPDATE exam_question eq SET selected_options = $3
+		FROM exam e
+		WHERE eq.exam_id = e.id AND e.id = $1 AND eq.position = $2
+			AND e.submitted_at IS NULL AND now() <= e.deadline + make_interval(secs => $4)
+	`)
+	tag, err := r.db.Exec(ctx, query, examID, position, selected, gracePeriod.Seconds())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrExamClosed
+	}
+	return nil
+}
+
+// SubmitExam saves the grading of an exam. It returns false without saving anything if the exam was already submitted.
+func (r *ExamRepository) SubmitExam(ctx context.Context, examID int, gradedQuestions []GradedQuestion, correctCount int, score float64, isPassed bool) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		UPDATE exam SET submitted_at = LEAST(now(), deadline), correct_count = $2, score = $3, is_passed = $4
+		WHERE id = $1 AND submitted_at IS NULL
+	`)
+	tag, err := tx.Exec(ctx, query, examID, correctCount, score, isPassed)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	for _, gradedQuestion := range gradedQuestions {
+		choices := make([]GradedChoiceEntity, 0, len(gradedQuestion.Choices))
+		for _, choice := range gradedQuestion.Choices {
+			choices = append(choices, GradedChoiceEntity{
+				Option:   choice.Choice.Option,
+				Text:     choice.Choice.Text,
+				IsAnswer: choice.Choice.IsAnswer,
+				Selected: choice.Selected,
+				Verdict:  choice.Verdict,
+			})
+		}
+		query = fmt.Sprintf("UPDATE exam_question SET is_correct = $3, score = $4, choices = $5 WHERE exam_id = $1 AND position = $2")
+		_, err = tx.Exec(ctx, query, examID, gradedQuestion.Position, gradedQuestion.IsCorrect, gradedQuestion.Score, choices)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit(ctx)
+}
+
+// ListExamsByTemplateID returns the exams of a template taken by any user, latest first, without their questions
+func (r *ExamRepository) ListExamsByTemplateID(ctx context.Context, templateID int) ([]Exam, error) {
+	query := fmt.Sprintf(`
+		SELECT id, exam_template_id, user_id, started_at, deadline, submitted_at, correct_count, score, is_passed,
+			(SELECT count(*) FROM exam_question WHERE exam_id = exam.id) AS question_count
+		FROM exam WHERE exam_template_id = $1 ORDER BY started_at DESC
+	`)
+	rows, err := r.db.Query(ctx, query, templateID)
+	if err != nil {
+		return nil, err
+	}
+	examEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ExamEntity])
+	if err != nil {
+		return nil, err
+	}
+	template, err := r.FindTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	var exams []Exam
+	for _, examEntity := range examEntities {
+		exams = append(exams, toExam(examEntity, *template))
+	}
+	return exams, nil
+}
+
+func toTemplate(templateEntity TemplateEntity, rules []TemplateRule, questionIDs []int) Template {
+	return Template{
+		ID:          templateEntity.ID,
+		Name:        templateEntity.Name,
+		Description: templateEntity.Description,
+		Duration:    time.Duration(templateEntity.DurationMinutes) * time.Minute,
+		PassMark:    templateEntity.PassMark,
+		Scoring:     trainer.Scoring(templateEntity.Scoring),
+		IsPublic:    templateEntity.IsPublic,
+		Rules:       rules,
+		QuestionIDs: questionIDs,
+	}
+}
+
+func toExam(examEntity ExamEntity, template Template) Exam {
+	exam := Exam{
+		ID:            examEntity.ID,
+		Template:      template,
+		UserID:        examEntity.UserID,
+		StartedAt:     examEntity.StartedAt,
+		Deadline:      examEntity.Deadline,
+		SubmittedAt:   examEntity.SubmittedAt,
+		QuestionCount: examEntity.QuestionCount,
+	}
+	if examEntity.CorrectCount != nil {
+		exam.CorrectCount = *examEntity.CorrectCount
+	}
+	if examEntity.Score != nil {
+		exam.Score = *examEntity.Score
+	}
+	if examEntity.IsPassed != nil {
+		exam.IsPassed = *examEntity.IsPassed
+	}
+	return exam
+}
@@ -0,0 +1,235 @@
+package exam
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+type Repository interface {
+	GetAllTemplates(ctx context.Context) ([]Template, error)
+	FindTemplateByID(ctx context.Context, id int) (*Template, error)
+	CreateTemplate(ctx context.Context, template Template) (int, error)
+	CreateExam(ctx context.Context, template Template, userID int) (int, error)
+	FindExamByID(ctx context.Context, id int) (*Exam, error)
+	ListExamsByUserID(ctx context.Context, userID int) ([]Exam, error)
+	ListExamsByTemplateID(ctx context.Context, templateID int) ([]Exam, error)
+	SaveSelection(ctx context.Context, examID int, position int, selected []string) error
+	SubmitExam(ctx context.Context, examID int, gradedQuestions []GradedQuestion, correctCount int, score float64, isPassed bool) (bool, error)
+}
+
+// QuestionService is the part of the trainer service the exams are built on
+type QuestionService interface {
+	GetQuestionsByIDs(ctx context.Context, ids []int) ([]trainer.Question, error)
+	RecordAnswer(ctx context.Context, userID int, questionID int, selected []string, source trainer.AnswerSource) (*trainer.Answer, error)
+}
+
+type ExamService struct {
+	repository      Repository
+	questionService QuestionService
+}
+
+func NewService(repository Repository, questionService QuestionService) *ExamService {
+	return &ExamService{
+		repository:      repository,
+		questionService: questionService,
+	}
+}
+
+func (s *ExamService) GetTemplates(ctx context.Context) ([]Template, error) {
+	return s.repository.GetAllTemplates(ctx)
+}
+
+func (s *ExamService) GetTemplate(ctx context.Context, templateID int) (*Template, error) {
+	return s.repository.FindTemplateByID(ctx, templateID)
+}
+
+// CreateTemplate saves a template after checking that its questions exist, returning its id
+func (s *ExamService) CreateTemplate(ctx context.Context, template Template) (int, error) {
+	err := s.ValidateTemplate(ctx, template)
+	if err != nil {
+		return 0, err
+	}
+	return s.repository.CreateTemplate(ctx, template)
+}
+
+// ValidateTemplate checks that the template has questions and that the questions it lists exist
+func (s *ExamService) ValidateTemplate(ctx context.Context, template Template) error {
+	if template.QuestionCount() == 0 {
+		return ErrNoQuestions
+	}
+	if len(template.QuestionIDs) > 0 {
+		questions, err := s.questionService.GetQuestionsByIDs(ctx, template.QuestionIDs)
+		if err != nil {
+			return err
+		}
+		if len(questions) != len(template.QuestionIDs) {
+			return trainer.ErrQuestionNotFound
+		}
+	}
+	return nil
+}
+
+// ListTemplateExams returns the exams of the template taken by every user, latest first
+func (s *ExamService) ListTemplateExams(ctx context.Context, templateID int) ([]Exam, error) {
+	return s.repository.ListExamsByTemplateID(ctx, templateID)
+}
+
+func (s *ExamService) ListExams(ctx context.Context, userID int) ([]Exam, error) {
+	return s.repository.ListExamsByUserID(ctx, userID)
+}
+
+// StartExam draws the questions of a new exam of a public template, the deadline starts running immediately
+func (s *ExamService) StartExam(ctx context.Context, userID int, templateID int) (int, error) {
+	template, err := s.repository.FindTemplateByID(ctx, templateID)
+	if err != nil {
+		return 0, err
+	}
+	if !template.IsPublic {
+		return 0, ErrTemplateNotFound
+	}
+	return s.repository.CreateExam(ctx, *template, userID)
+}
+
+// StartPrivateExam starts an exam of any template, the caller checks that the user may take it
+func (s *ExamService) StartPrivateExam(ctx context.Context, userID int, templateID int) (int, error) {
+	template, err := s.repository.FindTemplateByID(ctx, templateID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repository.CreateExam(ctx, *template, userID)
+}
+
+// GetOpenExam returns an exam of the user that can still be answered with its questions in order,
+// ErrExamClosed if it was submitted or its deadline has passed
+func (s *ExamService) GetOpenExam(ctx context.Context, userID int, examID int) (*Exam, []trainer.Question, error) {
+	exam, err := s.getExam(ctx, userID, examID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exam.IsClosed(time.Now()) {
+		return nil, nil, ErrExamClosed
+	}
+	questions, err := s.questionService.GetQuestionsByIDs(ctx, questionIDs(exam))
+	if err != nil {
+		return nil, nil, err
+	}
+	return exam, questions, nil
+}
+
+// SaveSelection saves the options selected for the question at the position of an open exam
+func (s *ExamService) SaveSelection(ctx context.Context, userID int, examID int, position int, selected []string) error {
+	exam, err := s.getExam(ctx, userID, examID)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(exam.Questions, func(question ExamQuestion) bool { return question.Position == position })
+	if idx < 0 {
+		return ErrExamNotFound
+	}
+	questions, err := s.questionService.GetQuestionsByIDs(ctx, []int{exam.Questions[idx].QuestionID})
+	if err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return trainer.ErrQuestionNotFound
+	}
+	_, err = trainer.GradeAnswer(questions[0].ID, questions[0].Choices, selected)
+	if err != nil {
+		return err
+	}
+	return s.repository.SaveSelection(ctx, examID, position, selected)
+}
+
+// Submit grades the answers saved until now and closes the exam. Submitting a closed exam returns its result.
+func (s *ExamService) Submit(ctx context.Context, userID int, examID int) (*Result, error) {
+	exam, err := s.getExam(ctx, userID, examID)
+	if err != nil {
+		return nil, err
+	}
+	return s.result(ctx, exam)
+}
+
+// GetResult returns the result of a submitted exam. An exam whose deadline has passed without being submitted
+// is submitted with the answers saved before the deadline, ErrExamInProgress is returned for an open exam.
+func (s *ExamService) GetResult(ctx context.Context, userID int, examID int) (*Result, error) {
+	exam, err := s.getExam(ctx, userID, examID)
+	if err != nil {
+		return nil, err
+	}
+	if !exam.IsClosed(time.Now()) {
+		return nil, ErrExamInProgress
+	}
+	return s.result(ctx, exam)
+}
+
+// getExam returns the exam if it belongs to the user, other users' exams are reported as not found
+func (s *ExamService) getExam(ctx context.Context, userID int, examID int) (*Exam, error) {
+	exam, err := s.repository.FindExamByID(ctx, examID)
+	if err != nil {
+		return nil, err
+	}
+	if exam.UserID != userID {
+		return nil, ErrExamNotFound
+	}
+	return exam, nil
+}
+
+// result returns the result of the exam, grading it and closing it first if it is not submitted yet
+func (s *ExamService) result(ctx context.Context, exam *Exam) (*Result, error) {
+	questions, err := s.questionService.GetQuestionsByIDs(ctx, questionIDs(exam))
+	if err != nil {
+		return nil, err
+	}
+	if !exam.IsSubmitted() {
+		exam, err = s.submit(ctx, exam, questions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newResult(*exam, questions)
+}
+
+// submit grades the answers saved until now and closes the exam, returning it with the grading saved.
+// The answers are added to the history of the user the first time the exam is submitted.
+func (s *ExamService) submit(ctx context.Context, exam *Exam, questions []trainer.Question) (*Exam, error) {
+	gradedQuestions, err := grade(*exam, questions)
+	if err != nil {
+		return nil, err
+	}
+	correctCount, score := totals(gradedQuestions)
+	isPassed := passes(score, len(gradedQuestions), exam.Template.PassMark)
+	isFirstSubmission, err := s.repository.SubmitExam(ctx, exam.ID, gradedQuestions, correctCount, score, isPassed)
+	if err != nil {
+		return nil, err
+	}
+	if isFirstSubmission {
+		err = s.recordAnswers(ctx, exam)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.repository.FindExamByID(ctx, exam.ID)
+}
+
+// recordAnswers adds the answers of the exam to the history of the user
+func (s *ExamService) recordAnswers(ctx context.Context, exam *Exam) error {
+	for _, question := range exam.Questions {
+		_, err := s.questionService.RecordAnswer(ctx, exam.UserID, question.QuestionID, question.SelectedOptions, trainer.AnswerSourceExam)
+		if err != nil && !errors.Is(err, trainer.ErrInvalidOption) {
+			return err
+		}
+	}
+	return nil
+}
+
+func questionIDs(exam *Exam) []int {
+	ids := make([]int, 0, len(exam.Questions))
+	for _, question := range exam.Questions {
+		ids = append(ids, question.QuestionID)
+	}
+	return ids
+}
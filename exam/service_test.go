@@ -0,0 +1,198 @@
+package exam
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+// fakeExamRepository holds one exam and saves its grading the way the database does
+type fakeExamRepository struct {
+	Repository
+	exam Exam
+}
+
+func (r *fakeExamRepository) FindExamByID(_ context.Context, id int) (*Exam, error) {
+	if id != r.exam.ID {
+		return nil, ErrExamNotFound
+	}
+	exam := r.exam
+	exam.Questions = slices.Clone(r.exam.Questions)
+	return &exam, nil
+}
+
+func (r *fakeExamRepository) SubmitExam(_ context.Context, _ int, gradedQuestions []GradedQuestion, correctCount int, score float64, isPassed bool) (bool, error) {
+	if r.exam.IsSubmitted() {
+		return false, nil
+	}
+	submittedAt := time.Now()
+	r.exam.SubmittedAt = &submittedAt
+	r.exam.CorrectCount = correctCount
+	r.exam.Score = score
+	r.exam.IsPassed = isPassed
+	for _, gradedQuestion := range gradedQuestions {
+		i := slices.IndexFunc(r.exam.Questions, func(question ExamQuestion) bool { return question.Position == gradedQuestion.Position })
+		r.exam.Questions[i].IsCorrect = gradedQuestion.IsCorrect
+		r.exam.Questions[i].Score = gradedQuestion.Score
+		r.exam.Questions[i].Choices = gradedQuestion.Choices
+	}
+	return true, nil
+}
+
+// fakeQuestionService returns the questions in the reverse order of the ids, as nothing promises their order
+type fakeQuestionService struct {
+	questions map[int]trainer.Question
+	recorded  int
+}
+
+func (s *fakeQuestionService) GetQuestionsByIDs(_ context.Context, ids []int) ([]trainer.Question, error) {
+	var questions []trainer.Question
+	for i := len(ids) - 1; i >= 0; i-- {
+		if question, ok := s.questions[ids[i]]; ok {
+			questions = append(questions, question)
+		}
+	}
+	return questions, nil
+}
+
+func (s *fakeQuestionService) RecordAnswer(_ context.Context, _ int, _ int, _ []string, _ trainer.AnswerSource) (*trainer.Answer, error) {
+	s.recorded++
+	return &trainer.Answer{}, nil
+}
+
+func testQuestion(id int, rule string, answers ...string) trainer.Question {
+	question := trainer.Question{ID: id, Rule: trainer.Rule{ID: rule, Name: "Rule " + rule}}
+	for _, option := range []string{"a", "b", "c"} {
+		question.Choices = append(question.Choices, trainer.Choice{Option: option, Text: option, IsAnswer: slices.Contains(answers, option)})
+	}
+	return question
+}
+
+func newTestExam() (*fakeExamRepository, *fakeQuestionService) {
+	repository := &fakeExamRepository{exam: Exam{
+		ID:       1,
+		UserID:   7,
+		Template: Template{PassMark: 50, Scoring: trainer.ScoringExact},
+		Deadline: time.Now().Add(time.Hour),
+		Questions: []ExamQuestion{
+			{Position: 1, QuestionID: 10, SelectedOptions: []string{"a"}},
+			{Position: 2, QuestionID: 20, SelectedOptions: []string{"b"}},
+			{Position: 3, QuestionID: 30, SelectedOptions: []string{"c"}},
+		},
+	}}
+	questionService := &fakeQuestionService{questions: map[int]trainer.Question{
+		10: testQuestion(10, "8", "a"),
+		20: testQuestion(20, "8", "a"),
+		30: testQuestion(30, "15", "c"),
+	}}
+	return repository, questionService
+}
+
+func TestSubmitMatchesQuestionsByID(t *testing.T) {
+	repository, questionService := newTestExam()
+	result, err := NewService(repository, questionService).Submit(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCorrect := []bool{true, false, true}
+	for i, question := range result.Questions {
+		if question.Position != i+1 || question.Question.ID != repository.exam.Questions[i].QuestionID {
+			t.Fatalf("question %d is question %d at position %d", i, question.Question.ID, question.Position)
+		}
+		if question.IsCorrect != wantCorrect[i] {
+			t.Errorf("question %d correct = %t, want %t", question.Position, question.IsCorrect, wantCorrect[i])
+		}
+	}
+	if result.Exam.CorrectCount != 2 || result.Exam.Score != 2 || !result.Exam.IsPassed || result.Percentage != 66 {
+		t.Errorf("exam = %d correct, score %v, passed %t, %d%%, want 2, 2, true, 66%%",
+			result.Exam.CorrectCount, result.Exam.Score, result.Exam.IsPassed, result.Percentage)
+	}
+	if questionService.recorded != 3 {
+		t.Errorf("recorded %d answers, want 3", questionService.recorded)
+	}
+}
+
+// TestResultAfterAnswerCorrection checks that the result of a submitted exam keeps the grading of the submission
+// after the answers of a question are corrected
+func TestResultAfterAnswerCorrection(t *testing.T) {
+	repository, questionService := newTestExam()
+	service := NewService(repository, questionService)
+	_, err := service.Submit(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	questionService.questions[10] = testQuestion(10, "8", "b")
+	questionService.questions[20] = testQuestion(20, "8", "b")
+
+	result, err := service.GetResult(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Questions[0].IsCorrect || result.Questions[1].IsCorrect {
+		t.Errorf("correct = %t, %t, want the verdicts of the submission true, false", result.Questions[0].IsCorrect, result.Questions[1].IsCorrect)
+	}
+	if result.Percentage != 66 || result.Exam.Score != 2 || !result.Exam.IsPassed {
+		t.Errorf("score %v, %d%%, passed %t, want 2, 66%%, true", result.Exam.Score, result.Percentage, result.Exam.IsPassed)
+	}
+	want := []RuleResult{
+		{Rule: trainer.Rule{ID: "8", Name: "Rule 8"}, CorrectCount: 1, Score: 1, Total: 2},
+		{Rule: trainer.Rule{ID: "15", Name: "Rule 15"}, CorrectCount: 1, Score: 1, Total: 1},
+	}
+	for _, ruleResult := range want {
+		i := slices.IndexFunc(result.Rules, func(r RuleResult) bool { return r.Rule.ID == ruleResult.Rule.ID })
+		if i < 0 || result.Rules[i] != ruleResult {
+			t.Errorf("rules = %+v, want %+v", result.Rules, ruleResult)
+		}
+	}
+	if questionService.recorded != 3 {
+		t.Errorf("recorded %d answers, want them recorded once", questionService.recorded)
+	}
+}
+
+// TestResultAfterChoiceRemoved edits a question behind a submitted exam, removing the option that was selected
+func TestResultAfterChoiceRemoved(t *testing.T) {
+	repository, questionService := newTestExam()
+	service := NewService(repository, questionService)
+	_, err := service.Submit(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := testQuestion(10, "8", "b")
+	edited.Choices = edited.Choices[1:]
+	questionService.questions[10] = edited
+
+	result, err := service.GetResult(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatalf("GetResult() error = %v, want the result as submitted", err)
+	}
+	first := result.Questions[0]
+	if !first.IsCorrect || len(first.Result.Choices) != 3 {
+		t.Fatalf("question 1 correct = %t with %d choices, want the 3 choices graded at submission", first.IsCorrect, len(first.Result.Choices))
+	}
+	if choice := first.Result.Choices[0]; choice.Choice.Option != "a" || !choice.Selected || choice.Verdict != trainer.VerdictCorrect {
+		t.Errorf("choice a = %+v, want selected and correct", choice)
+	}
+}
+
+// TestSubmitAfterChoiceRemoved submits an open exam after the option selected for a question was removed
+func TestSubmitAfterChoiceRemoved(t *testing.T) {
+	repository, questionService := newTestExam()
+	edited := testQuestion(10, "8", "b")
+	edited.Choices = edited.Choices[1:]
+	questionService.questions[10] = edited
+
+	result, err := NewService(repository, questionService).Submit(context.Background(), 7, 1)
+	if err != nil {
+		t.Fatalf("Submit() error = %v, want the removed option left out", err)
+	}
+	first := result.Questions[0]
+	if first.IsCorrect || first.Score != 0 || len(first.Result.Choices) != 2 {
+		t.Errorf("question 1 correct = %t score %v with %d choices, want unanswered among the 2 choices left", first.IsCorrect, first.Score, len(first.Result.Choices))
+	}
+	if result.Exam.CorrectCount != 1 {
+		t.Errorf("correct count = %d, want 1", result.Exam.CorrectCount)
+	}
+}
@@ -7,4 +7,26 @@ type EnvConfig struct {
 	DbPort     string `env:"DB_PORT"`
 	DbDatabase string `env:"DB_DATABASE"`
 	DbSchema   string `env:"DB_SCHEMA"`
+
+	// BaseUrl is the public url of the site, used for links in emails
+	BaseUrl string `env:"BASE_URL" envDefault:"http://localhost:8080"`
+	// CookieSecure marks the session cookie as https only, disable it for local development over http
+	CookieSecure bool `env:"COOKIE_SECURE" envDefault:"true"`
+	// SigningSecret signs the exams handed out to be graded when they are submitted.
+	// A random secret is used when it is empty, the exams in progress are then lost on restart.
+	SigningSecret string `env:"SIGNING_SECRET"`
+	// TrustProxy takes the client IP from X-Forwarded-For, only enable it behind a reverse proxy that sets the header
+	TrustProxy bool `env:"TRUST_PROXY" envDefault:"false"`
+
+	// SmtpHost is the SMTP server emails are sent through, emails are only logged when it is empty
+	SmtpHost     string `env:"SMTP_HOST"`
+	SmtpPort     int    `env:"SMTP_PORT" envDefault:"587"`
+	SmtpUsername string `env:"SMTP_USERNAME"`
+	SmtpPassword string `env:"SMTP_PASSWORD"`
+	SmtpFrom     string `env:"SMTP_FROM"`
+	// FeedbackEmails are the comma separated addresses told about new feedback
+	FeedbackEmails []string `env:"FEEDBACK_EMAILS" envSeparator:","`
+	// WebhookUrls are the comma separated urls the feedback events are posted to, signed with WebhookSecret
+	WebhookUrls   []string `env:"WEBHOOK_URLS" envSeparator:","`
+	WebhookSecret string   `env:"WEBHOOK_SECRET"`
 }
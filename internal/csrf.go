@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+const (
+	csrfCookieName = "csrf_token"
+	// CSRFFieldName is the form field the token is posted in, htmx requests may send it in CSRFHeaderName instead
+	CSRFFieldName  = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+	csrfTokenBytes = 32
+)
+
+var ErrInvalidCSRFToken = errors.New("invalid CSRF token")
+
+// CSRF protects forms with a token kept in a cookie that must be posted back with the form.
+// Another site can make the browser send the cookie but cannot read it to post the token.
+type CSRF struct {
+	secureCookie bool
+}
+
+func NewCSRF(secureCookie bool) *CSRF {
+	return &CSRF{secureCookie: secureCookie}
+}
+
+// Token returns the token of the browser to render in a form, setting a new one if the browser has none
+func (c *CSRF) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	b := make([]byte, csrfTokenBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.secureCookie,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// Verify checks that the token posted with the request matches the token of the browser
+func (c *CSRF) Verify(r *http.Request) error {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRFToken
+	}
+	token := r.Header.Get(CSRFHeaderName)
+	if token == "" {
+		token = r.PostFormValue(CSRFFieldName)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// Protect only lets requests with a valid token through to the handler of a form
+func (c *CSRF) Protect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.Verify(r) != nil {
+			http.Error(w, "The form has expired, please reload the page and submit it again.", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookie  string
+		field   string
+		header  string
+		wantErr bool
+	}{
+		{"token posted in the form", "token", "token", "", false},
+		{"token sent in the header", "token", "", "token", false},
+		{"header preferred over the form", "token", "other", "token", false},
+		{"no cookie", "", "token", "", true},
+		{"no token posted", "token", "", "", true},
+		{"other token posted", "token", "other", "", true},
+		{"other token in the header", "token", "token", "other", true},
+		{"token prefix", "token", "tok", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.field != "" {
+				form.Set(CSRFFieldName, tt.field)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeaderName, tt.header)
+			}
+			err := NewCSRF(false).Verify(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	csrf := NewCSRF(true)
+	w := httptest.NewRecorder()
+	token, err := csrf.Token(w, httptest.NewRequest(http.MethodGet, "/feedback", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != token || !cookies[0].Secure || !cookies[0].HttpOnly {
+		t.Fatalf("Token() set cookies %v, want one secure cookie with the token", cookies)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/feedback", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	again, err := csrf.Token(w, r)
+	if err != nil || again != token || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Token() with the cookie = %q, want the same token without a new cookie", again)
+	}
+}
+
+func TestCSRFProtect(t *testing.T) {
+	csrf := NewCSRF(false)
+	handler := csrf.Protect(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, token := range []string{"token", "other"} {
+		form := url.Values{CSRFFieldName: {token}}
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+		w := httptest.NewRecorder()
+		handler(w, r)
+		want := http.StatusNoContent
+		if token != "token" {
+			want = http.StatusForbidden
+		}
+		if w.Code != want {
+			t.Errorf("posting %q = %d, want %d", token, w.Code, want)
+		}
+	}
+}
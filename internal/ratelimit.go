@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRateLimited = errors.New("too many requests, please try again later")
+
+// RateLimiter allows a number of requests per key, such as a client IP, within a sliding window
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu   sync.Mutex
+	hits map[string][]time.Time
+	// lastSweep is when the keys without any request in the window were last removed
+	lastSweep time.Time
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow records a request of the key and returns whether it is within the limit, requests over the limit are not recorded
+func (l *RateLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limited(key, now) {
+		return false
+	}
+	l.hits[key] = append(l.hits[key], now)
+	return true
+}
+
+// Limited returns whether the key reached the limit without recording a request, for callers that only
+// Record the requests that count, such as failed attempts
+func (l *RateLimiter) Limited(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limited(key, now)
+}
+
+// Record records a request of the key
+func (l *RateLimiter) Record(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hits[key] = append(l.recent(l.hits[key], now), now)
+}
+
+// limited drops the hits out of the window and returns whether the key reached the limit. The lock must be held.
+func (l *RateLimiter) limited(key string, now time.Time) bool {
+	if now.Sub(l.lastSweep) > l.window {
+		for k, hits := range l.hits {
+			if len(l.recent(hits, now)) == 0 {
+				delete(l.hits, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	hits := l.recent(l.hits[key], now)
+	if len(hits) == 0 {
+		delete(l.hits, key)
+		return false
+	}
+	l.hits[key] = hits
+	return len(hits) >= l.limit
+}
+
+// recent returns the hits within the window, hits are kept oldest first
+func (l *RateLimiter) recent(hits []time.Time, now time.Time) []time.Time {
+	for i, hit := range hits {
+		if now.Sub(hit) < l.window {
+			return hits[i:]
+		}
+	}
+	return hits[:0]
+}
+
+// ClientIP returns the IP address of the client. Behind a reverse proxy, set trustProxy to use the address
+// the proxy appended to X-Forwarded-For, the addresses before it are sent by the client and can be forged.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := r.Header.Get("X-Forwarded-For")
+		if i := strings.LastIndex(forwarded, ","); i >= 0 {
+			forwarded = forwarded[i+1:]
+		}
+		if ip := strings.TrimSpace(forwarded); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
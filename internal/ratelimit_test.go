@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	tests := []struct {
+		name  string
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"first request", "a", 0, true},
+		{"second request", "a", 10 * time.Second, true},
+		{"over the limit", "a", 20 * time.Second, false},
+		{"other key", "b", 20 * time.Second, true},
+		{"refused requests are not counted", "a", 59 * time.Second, false},
+		{"first request out of the window", "a", time.Minute, true},
+		{"second request still in the window", "a", time.Minute + 5*time.Second, false},
+		{"both out of the window", "a", 2 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := limiter.Allow(tt.key, start.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: Allow(%q) = %t, want %t", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterSweepsIdleKeys(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, time.Minute)
+	limiter.Allow("a", start)
+	limiter.Allow("b", start)
+	limiter.Allow("c", start.Add(2*time.Minute))
+	if len(limiter.hits) != 1 {
+		t.Errorf("%d keys kept, want only the key with a request in the window", len(limiter.hits))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		trustProxy bool
+		want       string
+	}{
+		{"remote address", "192.0.2.1:1234", "", false, "192.0.2.1"},
+		{"IPv6 remote address", "[2001:db8::1]:1234", "", false, "2001:db8::1"},
+		{"remote address without a port", "192.0.2.1", "", false, "192.0.2.1"},
+		{"forwarded header ignored without a proxy", "192.0.2.1:1234", "198.51.100.7", false, "192.0.2.1"},
+		{"address appended by the proxy", "10.0.0.1:1234", "198.51.100.7", true, "198.51.100.7"},
+		{"addresses sent by the client skipped", "10.0.0.1:1234", "203.0.113.9, 198.51.100.7", true, "198.51.100.7"},
+		{"no forwarded header behind the proxy", "10.0.0.1:1234", "", true, "10.0.0.1"},
+		{"empty last forwarded address", "10.0.0.1:1234", "203.0.113.9, ", true, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r, tt.trustProxy); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
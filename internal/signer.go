@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const signerKeyBytes = 32
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer signs data handed to the browser so that it can be trusted when it is posted back
+type Signer struct {
+	key []byte
+}
+
+// NewSigner signs with the secret, or with a random key when the secret is empty,
+// in which case the signed data does not survive a restart
+func NewSigner(secret string) (*Signer, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, signerKeyBytes)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Signer{key: key}, nil
+}
+
+// Sign returns the payload with its signature as a token safe for urls and forms
+func (s *Signer) Sign(payload []byte) string {
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded))
+}
+
+// Verify returns the payload of a token made by Sign, ErrInvalidSignature if it was not signed by the signer
+func (s *Signer) Verify(token string) ([]byte, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, ErrInvalidSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	return payload, nil
+}
+
+func (s *Signer) mac(encoded string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,357 @@
+package live
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/internal"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+const (
+	// participantCookiePrefix is followed by the PIN, the cookie keeps the participant id across reconnects
+	participantCookiePrefix = "live_"
+	defaultTimeLimit        = 30
+	// heartbeatInterval keeps idle event streams from being closed by proxies
+	heartbeatInterval = 20 * time.Second
+	// retryMillis is how long the browser waits before reconnecting a dropped event stream
+	retryMillis = 2000
+)
+
+type Controller struct {
+	hub          *Hub
+	html         fs.FS
+	secureCookie bool
+	// joinLimiter limits the wrong PINs tried from an IP, so that the PINs of the sessions cannot be guessed
+	joinLimiter *internal.RateLimiter
+	trustProxy  bool
+}
+
+func NewController(hub *Hub, html fs.FS, secureCookie bool, joinLimiter *internal.RateLimiter, trustProxy bool) *Controller {
+	return &Controller{
+		hub:          hub,
+		html:         html,
+		secureCookie: secureCookie,
+		joinLimiter:  joinLimiter,
+		trustProxy:   trustProxy,
+	}
+}
+
+type LobbyPageData struct {
+	PIN   string
+	Name  string
+	Error string
+	// CanHost shows the form to start a session to users who manage classes
+	CanHost bool
+}
+
+type HostPageData struct {
+	PIN              string
+	Rules            []trainer.Rule
+	DefaultTimeLimit int
+}
+
+type PlayPageData struct {
+	PIN string
+}
+
+// Lobby renders the form to join a session with its PIN, and to start a session for users who manage classes
+func (c *Controller) Lobby(w http.ResponseWriter, r *http.Request) {
+	c.render(w, "live/lobby.tmpl", LobbyPageData{
+		PIN:     r.URL.Query().Get("pin"),
+		CanHost: account.UserFromContext(r.Context()).Can(account.PermissionManageClasses),
+	})
+}
+
+// Start starts a session hosted by the user
+func (c *Controller) Start(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	session, err := c.hub.Start(r.Context(), user.ID)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting live session: %s", err)
+		http.Error(w, "Error starting session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/live/%s/host", session.PIN), http.StatusSeeOther)
+}
+
+// Join adds the participant to the session of the PIN and keeps the participant id in a cookie
+func (c *Controller) Join(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := LobbyPageData{
+		PIN:     r.Form.Get("pin"),
+		Name:    r.Form.Get("name"),
+		CanHost: account.UserFromContext(r.Context()).Can(account.PermissionManageClasses),
+	}
+	ip := internal.ClientIP(r, c.trustProxy)
+	if c.joinLimiter.Limited(ip, time.Now()) {
+		data.Error = internal.ErrRateLimited.Error()
+		w.WriteHeader(http.StatusTooManyRequests)
+		c.render(w, "live/lobby.tmpl", data)
+		return
+	}
+	session, err := c.hub.Get(data.PIN)
+	var participantID string
+	if err == nil {
+		participantID, err = session.Join(data.Name, time.Now())
+	}
+	if errors.Is(err, ErrSessionNotFound) {
+		c.joinLimiter.Record(ip, time.Now())
+	}
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionEnded) || errors.Is(err, ErrSessionFull) || errors.Is(err, ErrInvalidName) {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.render(w, "live/lobby.tmpl", data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error joining live session: %s", err)
+		http.Error(w, "Error joining session", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     participantCookiePrefix + session.PIN,
+		Value:    participantID,
+		Path:     "/live/" + session.PIN,
+		MaxAge:   int(idleTimeout.Seconds()),
+		HttpOnly: true,
+		Secure:   c.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/live/"+session.PIN, http.StatusSeeOther)
+}
+
+// Play renders the page of a participant, who is sent back to the lobby without a valid cookie
+func (c *Controller) Play(w http.ResponseWriter, r *http.Request) {
+	session, _, err := c.participant(r)
+	if err != nil {
+		http.Redirect(w, r, "/live?pin="+r.PathValue("pin"), http.StatusSeeOther)
+		return
+	}
+	c.render(w, "live/play.tmpl", PlayPageData{PIN: session.PIN})
+}
+
+// Host renders the controls of the session for its host
+func (c *Controller) Host(w http.ResponseWriter, r *http.Request) {
+	session, ok := c.hostedSession(w, r)
+	if !ok {
+		return
+	}
+	rules, err := c.hub.GetRules(r.Context())
+	if err != nil {
+		log.Printf("Error getting rules: %s", err)
+	}
+	c.render(w, "live/host.tmpl", HostPageData{PIN: session.PIN, Rules: rules, DefaultTimeLimit: defaultTimeLimit})
+}
+
+// PushQuestion opens the next question, by its number or at random from the selected rules
+func (c *Controller) PushQuestion(w http.ResponseWriter, r *http.Request) {
+	session, ok := c.hostedSession(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	timeLimit, err := strconv.Atoi(r.Form.Get("time_limit"))
+	if err != nil {
+		timeLimit = defaultTimeLimit
+	}
+	err = c.hub.PushQuestion(r.Context(), session, r.Form.Get("number"), r.Form["rules"], time.Duration(timeLimit)*time.Second)
+	if errors.Is(err, trainer.ErrQuestionNotFound) || errors.Is(err, ErrInvalidTime) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, ErrSessionEnded) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error pushing live question: %s", err)
+		http.Error(w, "Error pushing question", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Reveal closes the open question before its time limit
+func (c *Controller) Reveal(w http.ResponseWriter, r *http.Request) {
+	session, ok := c.hostedSession(w, r)
+	if !ok {
+		return
+	}
+	err := session.Reveal(time.Now())
+	if errors.Is(err, ErrNoOpenRound) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// End ends the session
+func (c *Controller) End(w http.ResponseWriter, r *http.Request) {
+	session, ok := c.hostedSession(w, r)
+	if !ok {
+		return
+	}
+	session.End(time.Now())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Answer saves the answer of a participant to the open question
+func (c *Controller) Answer(w http.ResponseWriter, r *http.Request) {
+	session, participantID, err := c.participant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	number, err := strconv.Atoi(r.Form.Get("round"))
+	if err != nil {
+		http.Error(w, "Invalid round", http.StatusBadRequest)
+		return
+	}
+	err = session.Answer(participantID, number, r.Form["choices"], time.Now())
+	if errors.Is(err, ErrNoOpenRound) || errors.Is(err, ErrAlreadyAnswer) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, trainer.ErrInvalidOption) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error answering live question: %s", err)
+		http.Error(w, "Error saving answer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Events streams the state of the session as Server-Sent Events, to the host when ?host is set and to the
+// participant of the cookie otherwise. A reconnecting browser is sent the current state first.
+func (c *Controller) Events(w http.ResponseWriter, r *http.Request) {
+	var session *Session
+	var participantID string
+	var err error
+	if r.URL.Query().Has("host") {
+		user := account.UserFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Login required", http.StatusUnauthorized)
+			return
+		}
+		session, err = c.hub.GetHosted(r.PathValue("pin"), user.ID)
+	} else {
+		session, participantID, err = c.participant(r)
+	}
+	if errors.Is(err, ErrSessionNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	sub, err := session.Subscribe(participantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	defer session.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case state, ok := <-sub.states:
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(state)
+			if err != nil {
+				log.Printf("Error encoding live state: %s", err)
+				return
+			}
+			fmt.Fprintf(w, "event: state\ndata: %s\n\n", b)
+			flusher.Flush()
+		}
+	}
+}
+
+// participant returns the session of the PIN in the path and the participant of the cookie
+func (c *Controller) participant(r *http.Request) (*Session, string, error) {
+	session, err := c.hub.Get(r.PathValue("pin"))
+	if err != nil {
+		return nil, "", err
+	}
+	cookie, err := r.Cookie(participantCookiePrefix + session.PIN)
+	if err != nil || !session.IsParticipant(cookie.Value) {
+		return nil, "", ErrNotParticipant
+	}
+	return session, cookie.Value, nil
+}
+
+// hostedSession returns the session of the PIN in the path if it is hosted by the user,
+// writing the error response otherwise
+func (c *Controller) hostedSession(w http.ResponseWriter, r *http.Request) (*Session, bool) {
+	user := account.UserFromContext(r.Context())
+	session, err := c.hub.GetHosted(r.PathValue("pin"), user.ID)
+	if errors.Is(err, ErrSessionNotFound) {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if errors.Is(err, ErrNotHost) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return session, true
+}
+
+func (c *Controller) render(w http.ResponseWriter, page string, data any) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
@@ -0,0 +1,51 @@
+package live
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+	"github.com/aattwwss/ihf-referee-rules/public"
+)
+
+func TestJoinLimitsWrongPINs(t *testing.T) {
+	htmlFS, err := public.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const limit = 3
+	hub := NewHub(nil)
+	controller := NewController(hub, htmlFS, false, internal.NewRateLimiter(limit, time.Minute), false)
+	session := newSession("123456", 1, time.Now())
+	hub.sessions[session.PIN] = session
+
+	join := func(pin, remoteAddr string) int {
+		form := url.Values{"pin": {pin}, "name": {"Referee"}}
+		r := httptest.NewRequest(http.MethodPost, "/live/join", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		controller.Join(w, r)
+		return w.Code
+	}
+	for i := range limit {
+		if got := join("000000", "192.0.2.1:1234"); got != http.StatusUnprocessableEntity {
+			t.Fatalf("wrong PIN %d: status %d, want 422", i+1, got)
+		}
+	}
+	if got := join(session.PIN, "192.0.2.1:1234"); got != http.StatusTooManyRequests {
+		t.Errorf("after %d wrong PINs: status %d, want 429", limit, got)
+	}
+	if got := join(session.PIN, "192.0.2.2:1234"); got != http.StatusSeeOther {
+		t.Errorf("another IP: status %d, want 303 to the session", got)
+	}
+	for range limit + 1 {
+		if got := join(session.PIN, "192.0.2.2:1234"); got != http.StatusSeeOther {
+			t.Fatalf("right PIN: status %d, want joins with the right PIN not to count", got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package live
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+const (
+	// pinLength is the number of digits participants type to join a session
+	pinLength = 6
+	// maxParticipants keeps a session to the size of a clinic
+	maxParticipants = 200
+	maxNameLength   = 30
+	// leaderboardSize is the number of participants shown on the leaderboard
+	leaderboardSize = 10
+	// maxPoints is earned by a correct answer given at once, a correct answer given at the deadline earns half
+	maxPoints = 1000
+	// idleTimeout is how long a session without any activity is kept
+	idleTimeout = 3 * time.Hour
+	// endedTimeout is how long an ended session is kept so that participants see the final leaderboard
+	endedTimeout = 10 * time.Minute
+	// subscriberBuffer is the number of states queued for a slow subscriber before the oldest are dropped
+	subscriberBuffer = 4
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionEnded    = errors.New("session has ended")
+	ErrSessionFull     = errors.New("session is full")
+	ErrInvalidName     = errors.New("name must be between 1 and 30 characters")
+	// ErrNotParticipant is returned for a participant that did not join the session
+	ErrNotParticipant = errors.New("not a participant of the session")
+	ErrNotHost        = errors.New("only the host can do this")
+	ErrNoOpenRound    = errors.New("no question is open")
+	ErrAlreadyAnswer  = errors.New("question already answered")
+	ErrInvalidTime    = errors.New("time limit must be between 5 and 300 seconds")
+)
+
+// Status is the stage a session is in
+type Status string
+
+const (
+	// StatusLobby is a session waiting for the host to push the first question
+	StatusLobby Status = "lobby"
+	// StatusQuestion is a session with a question open for answers
+	StatusQuestion Status = "question"
+	// StatusReveal is a session showing the answers of the last question
+	StatusReveal Status = "reveal"
+	StatusEnded  Status = "ended"
+)
+
+// Participant is someone who joined a session with its PIN, no account is needed
+type Participant struct {
+	ID    string
+	Name  string
+	Score int
+}
+
+// round is a question pushed by the host
+type round struct {
+	number    int
+	question  trainer.Question
+	startedAt time.Time
+	deadline  time.Time
+	// answers are the options selected by each participant and when, by participant id
+	answers    map[string][]string
+	answeredAt map[string]time.Time
+	timer      *time.Timer
+}
+
+// State is what a subscriber is sent every time the session changes. It is tailored to the subscriber:
+// the answers are only revealed once the question is closed and the distribution is only shown to the host
+// while the question is open.
+type State struct {
+	PIN              string           `json:"pin"`
+	Status           Status           `json:"status"`
+	ParticipantCount int              `json:"participantCount"`
+	Round            *RoundState      `json:"round,omitempty"`
+	Leaderboard      []LeaderboardRow `json:"leaderboard"`
+	// You is the participant the state is sent to, nil for the host
+	You *YouState `json:"you,omitempty"`
+}
+
+type RoundState struct {
+	Number             int           `json:"number"`
+	RuleQuestionNumber string        `json:"ruleQuestionNumber"`
+	Text               string        `json:"text"`
+	Choices            []ChoiceState `json:"choices"`
+	DeadlineUnixMilli  int64         `json:"deadlineUnixMilli"`
+	AnswerCount        int           `json:"answerCount"`
+	// Distribution is the number of participants who selected each option
+	Distribution map[string]int `json:"distribution,omitempty"`
+	// CorrectOptions are only sent once the question is closed
+	CorrectOptions []string `json:"correctOptions,omitempty"`
+}
+
+type ChoiceState struct {
+	Option string `json:"option"`
+	Text   string `json:"text"`
+}
+
+type LeaderboardRow struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+type YouState struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+	// Selected are the options the participant answered the current question with, nil if not answered yet
+	Selected []string `json:"selected"`
+	// Points are earned on the current question, only sent once the question is closed
+	Points *int `json:"points,omitempty"`
+}
@@ -0,0 +1,168 @@
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+const (
+	// pinAttempts is how many PINs are drawn before giving up on finding one that is not in use
+	pinAttempts = 10
+	// cleanupInterval is how often expired sessions are removed
+	cleanupInterval = time.Minute
+)
+
+// QuestionService is the part of the trainer service the questions of a session are picked from
+type QuestionService interface {
+	GetAllQuestions(ctx context.Context) ([]trainer.Question, error)
+	GetRandomQuestion(ctx context.Context, rules []string, excludeIDs []int) (*trainer.Question, error)
+	GetAllRules(ctx context.Context) ([]trainer.Rule, error)
+}
+
+// Hub keeps the live sessions in memory, they do not survive a restart of the server
+type Hub struct {
+	questionService QuestionService
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewHub(questionService QuestionService) *Hub {
+	return &Hub{
+		questionService: questionService,
+		sessions:        make(map[string]*Session),
+	}
+}
+
+// Run removes the expired sessions until the context is done
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.removeExpired(now)
+		}
+	}
+}
+
+func (h *Hub) GetRules(ctx context.Context) ([]trainer.Rule, error) {
+	return h.questionService.GetAllRules(ctx)
+}
+
+// Start creates a session hosted by the user with a PIN that is not in use
+func (h *Hub) Start(ctx context.Context, hostID int) (*Session, error) {
+	err := account.Authorize(ctx, account.PermissionManageClasses)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for range pinAttempts {
+		pin, err := newPIN()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := h.sessions[pin]; ok {
+			continue
+		}
+		session := newSession(pin, hostID, time.Now())
+		h.sessions[pin] = session
+		return session, nil
+	}
+	return nil, fmt.Errorf("no free PIN after %d attempts", pinAttempts)
+}
+
+func (h *Hub) Get(pin string) (*Session, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	session, ok := h.sessions[strings.TrimSpace(pin)]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// GetHosted returns the session if it is hosted by the user
+func (h *Hub) GetHosted(pin string, hostID int) (*Session, error) {
+	session, err := h.Get(pin)
+	if err != nil {
+		return nil, err
+	}
+	if session.HostID != hostID {
+		return nil, ErrNotHost
+	}
+	return session, nil
+}
+
+// PushQuestion opens the question of the rule question number, such as 8.3, or a random question of the rules
+// not asked yet in the session when no number is given
+func (h *Hub) PushQuestion(ctx context.Context, session *Session, number string, rules []string, timeLimit time.Duration) error {
+	if timeLimit < 5*time.Second || timeLimit > 300*time.Second {
+		return ErrInvalidTime
+	}
+	var question *trainer.Question
+	if number = strings.TrimSpace(number); number != "" {
+		questions, err := h.questionService.GetAllQuestions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, q := range questions {
+			if strings.EqualFold(q.RuleQuestionNumber, number) {
+				question = &q
+				break
+			}
+		}
+		if question == nil {
+			return trainer.ErrQuestionNotFound
+		}
+	} else {
+		var err error
+		question, err = h.questionService.GetRandomQuestion(ctx, rules, session.Asked())
+		if err != nil {
+			return err
+		}
+	}
+	return session.Push(*question, timeLimit, time.Now())
+}
+
+func (h *Hub) removeExpired(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for pin, session := range h.sessions {
+		if session.isExpired(now) {
+			delete(h.sessions, pin)
+		}
+	}
+}
+
+// newPIN returns a random PIN of pinLength digits that does not start with 0
+func newPIN() (string, error) {
+	low := new(big.Int).Exp(big.NewInt(10), big.NewInt(pinLength-1), nil)
+	n, err := rand.Int(rand.Reader, new(big.Int).Mul(low, big.NewInt(9)))
+	if err != nil {
+		return "", err
+	}
+	return n.Add(n, low).String(), nil
+}
+
+// newParticipantID returns a random id that identifies a participant in the browser
+func newParticipantID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,339 @@
+package live
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+// Session is a live quiz run by a host. It is safe for concurrent use by the host, the participants
+// and the timer closing the open question.
+type Session struct {
+	PIN    string
+	HostID int
+
+	mu           sync.Mutex
+	status       Status
+	participants map[string]*Participant
+	// order keeps the participants in the order they joined, to break ties on the leaderboard
+	order       []string
+	round       *round
+	points      map[string]int
+	asked       []int
+	subscribers map[*subscriber]struct{}
+	lastActive  time.Time
+	endedAt     time.Time
+}
+
+// subscriber is an open event stream, participantID is empty for the host
+type subscriber struct {
+	participantID string
+	states        chan State
+}
+
+func newSession(pin string, hostID int, now time.Time) *Session {
+	return &Session{
+		PIN:          pin,
+		HostID:       hostID,
+		status:       StatusLobby,
+		participants: make(map[string]*Participant),
+		subscribers:  make(map[*subscriber]struct{}),
+		lastActive:   now,
+	}
+}
+
+// Join adds a participant with the name, returning the participant id to keep in the browser
+func (s *Session) Join(name string, now time.Time) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", ErrInvalidName
+	}
+	id, err := newParticipantID()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StatusEnded {
+		return "", ErrSessionEnded
+	}
+	if len(s.participants) >= maxParticipants {
+		return "", ErrSessionFull
+	}
+	s.participants[id] = &Participant{ID: id, Name: name}
+	s.order = append(s.order, id)
+	s.lastActive = now
+	s.broadcast()
+	return id, nil
+}
+
+// IsParticipant returns whether the id belongs to a participant who joined the session
+func (s *Session) IsParticipant(participantID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.participants[participantID]
+	return ok
+}
+
+// Asked returns the ids of the questions already pushed in the session
+func (s *Session) Asked() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.asked)
+}
+
+// Push opens a question for answers until the time limit, closing the question open before
+func (s *Session) Push(question trainer.Question, timeLimit time.Duration, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StatusEnded {
+		return ErrSessionEnded
+	}
+	if s.status == StatusQuestion {
+		s.closeRound()
+	}
+	number := 1
+	if s.round != nil {
+		number = s.round.number + 1
+	}
+	s.round = &round{
+		number:     number,
+		question:   question,
+		startedAt:  now,
+		deadline:   now.Add(timeLimit),
+		answers:    make(map[string][]string),
+		answeredAt: make(map[string]time.Time),
+	}
+	s.round.timer = time.AfterFunc(timeLimit, func() { s.timeout(number) })
+	s.points = nil
+	s.asked = append(s.asked, question.ID)
+	s.status = StatusQuestion
+	s.lastActive = now
+	s.broadcast()
+	return nil
+}
+
+// Answer saves the options selected by a participant for the question of the round number, only the first
+// answer counts. The number must be the open round, an answer to a question replaced meanwhile is refused.
+func (s *Session) Answer(participantID string, number int, selected []string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.participants[participantID]; !ok {
+		return ErrNotParticipant
+	}
+	if s.status != StatusQuestion || s.round.number != number || now.After(s.round.deadline) {
+		return ErrNoOpenRound
+	}
+	if _, ok := s.round.answers[participantID]; ok {
+		return ErrAlreadyAnswer
+	}
+	_, err := trainer.GradeAnswer(s.round.question.ID, s.round.question.Choices, selected)
+	if err != nil {
+		return err
+	}
+	if selected == nil {
+		selected = []string{}
+	}
+	s.round.answers[participantID] = selected
+	s.round.answeredAt[participantID] = now
+	s.lastActive = now
+	if len(s.round.answers) == len(s.participants) {
+		s.closeRound()
+	}
+	s.broadcast()
+	return nil
+}
+
+// Reveal closes the open question before its time limit
+func (s *Session) Reveal(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != StatusQuestion {
+		return ErrNoOpenRound
+	}
+	s.closeRound()
+	s.lastActive = now
+	s.broadcast()
+	return nil
+}
+
+// End closes the session, the subscribers get the final leaderboard
+func (s *Session) End(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == StatusQuestion {
+		s.closeRound()
+	}
+	s.status = StatusEnded
+	s.endedAt = now
+	s.broadcast()
+}
+
+// Subscribe returns a stream of the states of the session for the participant, or the host when participantID
+// is empty. The current state is sent first, so that a reconnecting browser catches up at once.
+func (s *Session) Subscribe(participantID string) (*subscriber, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.participants[participantID]; participantID != "" && !ok {
+		return nil, ErrNotParticipant
+	}
+	sub := &subscriber{
+		participantID: participantID,
+		states:        make(chan State, subscriberBuffer),
+	}
+	s.subscribers[sub] = struct{}{}
+	sub.states <- s.state(sub.participantID)
+	return sub, nil
+}
+
+func (s *Session) Unsubscribe(sub *subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.states)
+	}
+}
+
+// isExpired returns whether the session can be removed, closing the streams of its subscribers if so
+func (s *Session) isExpired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expired := now.Sub(s.lastActive) > idleTimeout || (s.status == StatusEnded && now.Sub(s.endedAt) > endedTimeout)
+	if !expired {
+		return false
+	}
+	if s.round != nil {
+		s.round.timer.Stop()
+	}
+	for sub := range s.subscribers {
+		delete(s.subscribers, sub)
+		close(sub.states)
+	}
+	return true
+}
+
+// timeout closes the question of the round number when its time limit is reached,
+// unless it was already closed or another question was pushed
+func (s *Session) timeout(number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != StatusQuestion || s.round.number != number {
+		return
+	}
+	s.closeRound()
+	s.broadcast()
+}
+
+// closeRound grades the answers of the open question. Correct answers earn between half and all of maxPoints,
+// the faster the more. The lock must be held.
+func (s *Session) closeRound() {
+	s.round.timer.Stop()
+	s.points = make(map[string]int)
+	limit := s.round.deadline.Sub(s.round.startedAt)
+	for participantID, selected := range s.round.answers {
+		result, err := trainer.GradeAnswer(s.round.question.ID, s.round.question.Choices, selected)
+		if err != nil || !result.IsCorrect {
+			s.points[participantID] = 0
+			continue
+		}
+		remaining := s.round.deadline.Sub(s.round.answeredAt[participantID])
+		points := maxPoints/2 + int(float64(maxPoints/2)*remaining.Seconds()/limit.Seconds())
+		s.points[participantID] = points
+		s.participants[participantID].Score += points
+	}
+	s.status = StatusReveal
+}
+
+// broadcast sends the state to every subscriber. A subscriber that is too slow to keep up loses its oldest
+// state, the latest state is always delivered. The lock must be held.
+func (s *Session) broadcast() {
+	for sub := range s.subscribers {
+		state := s.state(sub.participantID)
+		select {
+		case sub.states <- state:
+		default:
+			select {
+			case <-sub.states:
+			default:
+			}
+			sub.states <- state
+		}
+	}
+}
+
+// state returns the state of the session as seen by the participant, or the host. The lock must be held.
+func (s *Session) state(participantID string) State {
+	state := State{
+		PIN:              s.PIN,
+		Status:           s.status,
+		ParticipantCount: len(s.participants),
+	}
+
+	ranking := s.ranking()
+	for i, participant := range ranking {
+		if i == leaderboardSize {
+			break
+		}
+		state.Leaderboard = append(state.Leaderboard, LeaderboardRow{Rank: i + 1, Name: participant.Name, Score: participant.Score})
+	}
+
+	isClosed := s.status == StatusReveal || s.status == StatusEnded
+	if s.round != nil && s.status != StatusLobby {
+		question := s.round.question
+		roundState := &RoundState{
+			Number:             s.round.number,
+			RuleQuestionNumber: question.RuleQuestionNumber,
+			Text:               question.Text,
+			DeadlineUnixMilli:  s.round.deadline.UnixMilli(),
+			AnswerCount:        len(s.round.answers),
+		}
+		for _, choice := range question.Choices {
+			roundState.Choices = append(roundState.Choices, ChoiceState{Option: choice.Option, Text: choice.Text})
+			if isClosed && choice.IsAnswer {
+				roundState.CorrectOptions = append(roundState.CorrectOptions, choice.Option)
+			}
+		}
+		if participantID == "" || isClosed {
+			roundState.Distribution = make(map[string]int)
+			for _, selected := range s.round.answers {
+				for _, option := range selected {
+					roundState.Distribution[option]++
+				}
+			}
+		}
+		state.Round = roundState
+	}
+
+	if participant, ok := s.participants[participantID]; ok {
+		you := &YouState{
+			Name:  participant.Name,
+			Score: participant.Score,
+			Rank:  slices.Index(ranking, participant) + 1,
+		}
+		if s.round != nil {
+			you.Selected = s.round.answers[participantID]
+			if points, ok := s.points[participantID]; ok && isClosed {
+				you.Points = &points
+			}
+		}
+		state.You = you
+	}
+	return state
+}
+
+// ranking returns the participants by score, the first to join first on a tie. The lock must be held.
+func (s *Session) ranking() []*Participant {
+	ranking := make([]*Participant, 0, len(s.order))
+	for _, id := range s.order {
+		ranking = append(ranking, s.participants[id])
+	}
+	slices.SortStableFunc(ranking, func(a, b *Participant) int { return cmp.Compare(b.Score, a.Score) })
+	return ranking
+}
@@ -0,0 +1,440 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+)
+
+func testQuestion(id int) trainer.Question {
+	return trainer.Question{
+		ID:                 id,
+		RuleQuestionNumber: fmt.Sprintf("8.%d", id),
+		Text:               "Correct decision?",
+		Choices: []trainer.Choice{
+			{Option: "a", Text: "Free-throw", IsAnswer: true},
+			{Option: "b", Text: "7-metre throw"},
+		},
+	}
+}
+
+// receive waits for the next state of the subscriber, failing the test if none comes in time
+func receive(t *testing.T, sub *subscriber) State {
+	t.Helper()
+	select {
+	case state, ok := <-sub.states:
+		if !ok {
+			t.Fatal("subscriber closed")
+		}
+		return state
+	case <-time.After(5 * time.Second):
+		t.Fatal("no state received")
+	}
+	return State{}
+}
+
+// openRound returns the number of the round last pushed, as the page of a participant shows it
+func openRound(session *Session) int {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.round == nil {
+		return 0
+	}
+	return session.round.number
+}
+
+// drain returns the states queued for the subscriber without waiting
+func drain(sub *subscriber) []State {
+	var states []State
+	for {
+		select {
+		case state := <-sub.states:
+			states = append(states, state)
+		default:
+			return states
+		}
+	}
+}
+
+// TestSessionConcurrentRounds pushes questions while participants answer and the rounds time out,
+// run it with -race to check the locking of the session
+func TestSessionConcurrentRounds(t *testing.T) {
+	const participants = 20
+	const rounds = 30
+	session := newSession("123456", 1, time.Now())
+	ids := make([]string, 0, participants)
+	for i := range participants {
+		id, err := session.Join(fmt.Sprintf("Referee %d", i), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	host, err := session.Subscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for range host.states {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := range rounds {
+			err := session.Push(testQuestion(i+1), time.Duration(i%3)*time.Millisecond+time.Millisecond, time.Now())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(time.Millisecond)
+			if i%5 == 0 {
+				_ = session.Reveal(time.Now())
+			}
+		}
+	}()
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				selected := []string{"a"}
+				if i%2 == 1 {
+					selected = []string{"b"}
+				}
+				err := session.Answer(id, openRound(session), selected, time.Now())
+				if err != nil && !errors.Is(err, ErrNoOpenRound) && !errors.Is(err, ErrAlreadyAnswer) {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	session.End(time.Now())
+	session.Unsubscribe(host)
+	readers.Wait()
+
+	if asked := session.Asked(); len(asked) != rounds {
+		t.Errorf("asked %d questions, want %d", len(asked), rounds)
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.status != StatusEnded {
+		t.Errorf("status = %s, want ended", session.status)
+	}
+	for i, id := range ids {
+		score := session.participants[id].Score
+		if i%2 == 1 && score != 0 {
+			t.Errorf("participant answering wrong scored %d", score)
+		}
+		if score < 0 || score > rounds*maxPoints {
+			t.Errorf("score %d out of range", score)
+		}
+	}
+}
+
+func TestSessionTimeoutClosesRound(t *testing.T) {
+	session := newSession("123456", 1, time.Now())
+	id, err := session.Join("Referee", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := session.Subscribe(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, sub)
+
+	err = session.Push(testQuestion(1), 20*time.Millisecond, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := receive(t, sub); state.Status != StatusQuestion || state.Round.CorrectOptions != nil {
+		t.Fatalf("state = %+v, want the open question without its answers", state)
+	}
+	state := receive(t, sub)
+	if state.Status != StatusReveal || len(state.Round.CorrectOptions) != 1 {
+		t.Fatalf("state = %+v, want the question revealed at the deadline", state)
+	}
+	if err := session.Answer(id, 1, []string{"a"}, time.Now()); !errors.Is(err, ErrNoOpenRound) {
+		t.Errorf("Answer() after timeout error = %v, want ErrNoOpenRound", err)
+	}
+}
+
+// TestSessionTimeoutOfPreviousRound checks that the timer of a replaced question does not close the next one
+func TestSessionTimeoutOfPreviousRound(t *testing.T) {
+	session := newSession("123456", 1, time.Now())
+	err := session.Push(testQuestion(1), time.Minute, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Push(testQuestion(2), time.Minute, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.timeout(1)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.status != StatusQuestion || session.round.number != 2 {
+		t.Errorf("status = %s round = %d, want question 2 still open", session.status, session.round.number)
+	}
+}
+
+// TestAnswerRacingPush checks that an answer to a question the host replaced meanwhile is neither saved
+// nor scored against the next question
+func TestAnswerRacingPush(t *testing.T) {
+	session := newSession("123456", 1, time.Now())
+	id, err := session.Join("Referee", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.Join("Other referee", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Now()
+	err = session.Push(testQuestion(1), time.Minute, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the answer to question 1 is sent before question 2 is pushed but reaches the session after it
+	answeredAt := first.Add(time.Second)
+	err = session.Push(testQuestion(2), time.Minute, first.Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Answer(id, 1, []string{"a"}, answeredAt); !errors.Is(err, ErrNoOpenRound) {
+		t.Fatalf("Answer() to the replaced round error = %v, want ErrNoOpenRound", err)
+	}
+	if err := session.Answer(id, 3, []string{"a"}, first.Add(3*time.Second)); !errors.Is(err, ErrNoOpenRound) {
+		t.Errorf("Answer() to a round not pushed yet error = %v, want ErrNoOpenRound", err)
+	}
+	if err := session.Reveal(first.Add(4 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if _, ok := session.round.answers[id]; ok {
+		t.Error("the answer to the replaced round was saved for the open round")
+	}
+	if score := session.participants[id].Score; score != 0 {
+		t.Errorf("score = %d, want 0 for an answer to the replaced round", score)
+	}
+}
+
+func TestAnswerPointsByTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		answeredIn time.Duration
+		want       int
+	}{
+		{"at once", 0, maxPoints},
+		{"half way", 30 * time.Second, maxPoints * 3 / 4},
+		{"at the deadline", time.Minute, maxPoints / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newSession("123456", 1, time.Now())
+			id, err := session.Join("Referee", time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			pushed := time.Now()
+			err = session.Push(testQuestion(1), time.Minute, pushed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = session.Answer(id, 1, []string{"a"}, pushed.Add(tt.answeredIn))
+			if err != nil {
+				t.Fatal(err)
+			}
+			session.mu.Lock()
+			defer session.mu.Unlock()
+			if got := session.participants[id].Score; got != tt.want {
+				t.Errorf("score = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBroadcastDropsOldest(t *testing.T) {
+	session := newSession("123456", 1, time.Now())
+	host, err := session.Subscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const joins = subscriberBuffer * 3
+	for i := range joins {
+		_, err := session.Join(fmt.Sprintf("Referee %d", i), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states := drain(host)
+	if len(states) != subscriberBuffer {
+		t.Fatalf("queued %d states, want %d", len(states), subscriberBuffer)
+	}
+	for i, state := range states {
+		want := joins - subscriberBuffer + 1 + i
+		if state.ParticipantCount != want {
+			t.Errorf("state %d has %d participants, want %d", i, state.ParticipantCount, want)
+		}
+	}
+}
+
+func TestSubscribeReplaysState(t *testing.T) {
+	session := newSession("123456", 1, time.Now())
+	id, err := session.Join("Referee", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.Join("Other referee", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Push(testQuestion(1), time.Minute, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := session.Subscribe(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = session.Answer(id, 1, []string{"b"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Unsubscribe(first)
+	for range first.states {
+	}
+
+	reconnected, err := session.Subscribe(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := receive(t, reconnected)
+	if state.Status != StatusQuestion || state.Round == nil || state.Round.Number != 1 {
+		t.Fatalf("state = %+v, want the open question", state)
+	}
+	if state.You == nil || len(state.You.Selected) != 1 || state.You.Selected[0] != "b" {
+		t.Errorf("you = %+v, want the answer given before reconnecting", state.You)
+	}
+	if state.Round.Distribution != nil {
+		t.Errorf("distribution = %v, want none for a participant while the question is open", state.Round.Distribution)
+	}
+
+	if _, err := session.Subscribe("unknown"); !errors.Is(err, ErrNotParticipant) {
+		t.Errorf("Subscribe() of an unknown participant error = %v, want ErrNotParticipant", err)
+	}
+}
+
+func TestHubStartRequiresManageClasses(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *account.User
+		wantErr error
+	}{
+		{"anonymous", nil, account.ErrForbidden},
+		{"referee", &account.User{ID: 1, Role: account.RoleReferee}, account.ErrForbidden},
+		{"instructor", &account.User{ID: 1, Role: account.RoleInstructor}, nil},
+		{"admin", &account.User{ID: 1, Role: account.RoleAdmin}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub(nil)
+			session, err := hub.Start(account.WithUser(context.Background(), tt.user), 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(hub.sessions) != 0 {
+					t.Errorf("hub has %d sessions, want none started", len(hub.sessions))
+				}
+				return
+			}
+			if _, err := hub.Get(session.PIN); err != nil {
+				t.Errorf("Get() of the started session error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoveExpiredClosesSubscribers(t *testing.T) {
+	hub := NewHub(nil)
+	ctx := account.WithUser(context.Background(), &account.User{ID: 1, Role: account.RoleInstructor})
+	idle, err := hub.Start(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended, err := hub.Start(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := hub.Start(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = idle.Push(testQuestion(1), time.Minute, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idleSub, err := idle.Subscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended.End(now)
+	endedSub, err := ended.Subscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active.mu.Lock()
+	active.lastActive = now.Add(idleTimeout)
+	active.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, sub := range []*subscriber{idleSub, endedSub} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range sub.states {
+			}
+		}()
+	}
+	hub.removeExpired(now.Add(idleTimeout + time.Second))
+	wg.Wait()
+
+	for _, session := range []*Session{idle, ended} {
+		if _, err := hub.Get(session.PIN); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Get(%s) error = %v, want ErrSessionNotFound", session.PIN, err)
+		}
+	}
+	if _, err := hub.Get(active.PIN); err != nil {
+		t.Errorf("Get() of the active session error = %v", err)
+	}
+	idle.mu.Lock()
+	defer idle.mu.Unlock()
+	if idle.round.timer.Stop() {
+		t.Error("the timer of the removed session is still running")
+	}
+}
@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"context"
+	"log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// LogMailer writes the emails to the log instead of sending them, for development and until a real mailer is configured
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(_ context.Context, message Message) error {
+	log.Printf("Email to %s\nSubject: %s\n\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
@@ -0,0 +1,173 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the fake SMTP server received from a client
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// fakeSMTPServer accepts one connection on a local port and records the session, offering AUTH PLAIN but not STARTTLS
+func fakeSMTPServer(t *testing.T) (int, <-chan smtpSession) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+		text := textproto.NewConn(conn)
+		var session smtpSession
+		reply := func(line string) { _ = text.PrintfLine("%s", line) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command, argument, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(command) {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				session.auth = argument
+				reply("235 Authenticated")
+			case "MAIL":
+				session.from = argument
+				reply("250 OK")
+			case "RCPT":
+				session.to = append(session.to, argument)
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				session.data, err = text.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				sessions <- session
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	port, sessions := fakeSMTPServer(t)
+	mailer := NewSMTPMailer("127.0.0.1", port, "trainer", "s3cret", "trainer@example.com")
+	body := "New feedback on question 8.3 = the referee's decision\n" +
+		".a line starting with a dot\n" +
+		"Règle 8 – " + strings.Repeat("a long line that must be wrapped ", 5)
+	err := mailer.Send(context.Background(), Message{
+		To:      "admin@example.com",
+		Subject: "Feedback on règle 8",
+		Body:    body,
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %s", err)
+	}
+
+	var session smtpSession
+	select {
+	case session = <-sessions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not receive the email")
+	}
+	auth, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(session.auth, "PLAIN "))
+	if err != nil || string(auth) != "\x00trainer\x00s3cret" {
+		t.Errorf("AUTH = %q, want PLAIN with the username and password", session.auth)
+	}
+	if session.from != "FROM:<trainer@example.com>" {
+		t.Errorf("MAIL %s, want FROM:<trainer@example.com>", session.from)
+	}
+	if len(session.to) != 1 || session.to[0] != "TO:<admin@example.com>" {
+		t.Errorf("RCPT %q, want TO:<admin@example.com>", session.to)
+	}
+
+	message, err := netmail.ReadMessage(bytes.NewReader(session.data))
+	if err != nil {
+		t.Fatalf("reading the email: %s", err)
+	}
+	headers := map[string]string{
+		"From":                      "trainer@example.com",
+		"To":                        "admin@example.com",
+		"MIME-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=utf-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, want := range headers {
+		if got := message.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != "Feedback on règle 8" {
+		t.Errorf("Subject = %q decoded as %q, want Feedback on règle 8", message.Header.Get("Subject"), subject)
+	}
+	if _, err := message.Header.Date(); err != nil {
+		t.Errorf("Date = %q: %s", message.Header.Get("Date"), err)
+	}
+
+	raw, err := io.ReadAll(message.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server reads the lines of the data without their CRLF, the last one ends the data
+	raw = bytes.TrimSuffix(raw, []byte("\n"))
+	for _, line := range strings.Split(string(raw), "\n") {
+		if len(line) > 76 {
+			t.Errorf("body line of %d characters is longer than quoted-printable allows", len(line))
+		}
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(decoded); got != body {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
+
+func TestSMTPMailerRejectsHeaderInjection(t *testing.T) {
+	mailer := NewSMTPMailer("127.0.0.1", 1, "", "", "trainer@example.com")
+	messages := []Message{
+		{To: "admin@example.com\r\nBcc: everyone@example.com", Subject: "Feedback"},
+		{To: "admin@example.com", Subject: "Feedback\nBcc: everyone@example.com"},
+	}
+	for _, message := range messages {
+		err := mailer.Send(context.Background(), message)
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("Send(%q) error = %v, want ErrInvalidHeader", message, err)
+		}
+	}
+}
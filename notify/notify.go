@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/mail"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+	"github.com/aattwwss/ihf-referee-rules/webhook"
+)
+
+const (
+	EventFeedbackSubmitted = "feedback.submitted"
+	EventFeedbackCompleted = "feedback.completed"
+
+	// notifyTimeout bounds the emails and the webhook retries of one notification
+	notifyTimeout = 2 * time.Minute
+)
+
+// FeedbackNotifier emails the admins about new feedback and the submitter once it is completed,
+// and posts both events to the webhooks. It works in the background so that the request is not held up.
+type FeedbackNotifier struct {
+	mailer   mail.Mailer
+	webhooks *webhook.Sender
+	// recipients are the addresses of the admins told about new feedback
+	recipients []string
+	baseURL    string
+}
+
+func NewFeedbackNotifier(mailer mail.Mailer, webhooks *webhook.Sender, recipients []string, baseURL string) *FeedbackNotifier {
+	return &FeedbackNotifier{
+		mailer:     mailer,
+		webhooks:   webhooks,
+		recipients: recipients,
+		baseURL:    baseURL,
+	}
+}
+
+// feedbackPayload is the feedback in the webhook events
+type feedbackPayload struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Topic      string    `json:"topic"`
+	Text       string    `json:"text"`
+	Status     string    `json:"status"`
+	QuestionID *int      `json:"question_id,omitempty"`
+	Category   string    `json:"category,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	URL        string    `json:"url"`
+}
+
+func (n *FeedbackNotifier) FeedbackSubmitted(feedback trainer.Feedback) {
+	go n.notify(EventFeedbackSubmitted, feedback, func(ctx context.Context) {
+		subject := fmt.Sprintf("New feedback on %s from %s", feedback.Topic, feedback.Name)
+		if feedback.QuestionID != nil {
+			subject = fmt.Sprintf("New report on question %d: %s", *feedback.QuestionID, feedback.Category.Label())
+		}
+		body := fmt.Sprintf("%s <%s> wrote:\n\n%s\n\nReview it at %s",
+			feedback.Name, feedback.Email, feedback.Text, n.adminURL(feedback))
+		for _, recipient := range n.recipients {
+			err := n.mailer.Send(ctx, mail.Message{To: recipient, Subject: subject, Body: body})
+			if err != nil {
+				log.Printf("Error emailing feedback %d to %s: %s", feedback.ID, recipient, err)
+			}
+		}
+	})
+}
+
+func (n *FeedbackNotifier) FeedbackCompleted(feedback trainer.Feedback) {
+	go n.notify(EventFeedbackCompleted, feedback, func(ctx context.Context) {
+		err := n.mailer.Send(ctx, mail.Message{
+			To:      feedback.Email,
+			Subject: "Your feedback to the IHF Referee Trainer has been addressed",
+			Body: fmt.Sprintf("Hi %s,\n\nThank you for your feedback of %s, it has been looked into and addressed.\n\n%s\n\n%s",
+				feedback.Name, feedback.CreatedAt.Format("2 January 2006"), quote(feedback.Text), n.baseURL),
+		})
+		if err != nil {
+			log.Printf("Error emailing acknowledgement of feedback %d: %s", feedback.ID, err)
+		}
+	})
+}
+
+// notify sends the emails and the webhook event of the feedback concurrently
+func (n *FeedbackNotifier) notify(event string, feedback trainer.Feedback, sendEmails func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendEmails(ctx)
+	}()
+	err := n.webhooks.Send(ctx, event, n.payload(feedback))
+	if err != nil {
+		log.Printf("Error sending %s webhook of feedback %d: %s", event, feedback.ID, err)
+	}
+	<-done
+}
+
+func (n *FeedbackNotifier) payload(feedback trainer.Feedback) feedbackPayload {
+	return feedbackPayload{
+		ID:         feedback.ID,
+		Name:       feedback.Name,
+		Email:      feedback.Email,
+		Topic:      feedback.Topic,
+		Text:       feedback.Text,
+		Status:     string(feedback.Status()),
+		QuestionID: feedback.QuestionID,
+		Category:   string(feedback.Category),
+		CreatedAt:  feedback.CreatedAt,
+		URL:        n.adminURL(feedback),
+	}
+}
+
+// adminURL links to the feedback in the admin console, reports to every report on their question
+func (n *FeedbackNotifier) adminURL(feedback trainer.Feedback) string {
+	if feedback.QuestionID != nil {
+		return fmt.Sprintf("%s/admin/feedback?question=%d", n.baseURL, *feedback.QuestionID)
+	}
+	return fmt.Sprintf("%s/admin/feedback?topic=%s", n.baseURL, feedback.Topic)
+}
+
+func quote(text string) string {
+	return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/mail"
+	"github.com/aattwwss/ihf-referee-rules/trainer"
+	"github.com/aattwwss/ihf-referee-rules/webhook"
+)
+
+// fakeMailer passes the emails sent on to the test
+type fakeMailer struct {
+	sent chan mail.Message
+}
+
+func (m *fakeMailer) Send(_ context.Context, message mail.Message) error {
+	m.sent <- message
+	return nil
+}
+
+// receive returns the emails sent in the background, failing the test when they do not arrive
+func (m *fakeMailer) receive(t *testing.T, count int) []mail.Message {
+	t.Helper()
+	var messages []mail.Message
+	for range count {
+		select {
+		case message := <-m.sent:
+			messages = append(messages, message)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d emails, want %d", len(messages), count)
+		}
+	}
+	return messages
+}
+
+func newTestNotifier(recipients ...string) (*FeedbackNotifier, *fakeMailer) {
+	mailer := &fakeMailer{sent: make(chan mail.Message, 10)}
+	return NewFeedbackNotifier(mailer, webhook.NewSender(nil, ""), recipients, "https://trainer.example"), mailer
+}
+
+func TestFeedbackSubmitted(t *testing.T) {
+	questionID := 42
+	tests := []struct {
+		name        string
+		feedback    trainer.Feedback
+		wantSubject string
+		wantURL     string
+	}{
+		{
+			"feedback",
+			trainer.Feedback{ID: 1, Name: "Anna", Email: "anna@example.com", Topic: "test", Text: "The test is too short."},
+			"New feedback on test from Anna",
+			"https://trainer.example/admin/feedback?topic=test",
+		},
+		{
+			"report on a question",
+			trainer.Feedback{ID: 2, Name: "Anna", Email: "anna@example.com", Topic: "questions", Text: "The answer is b.", QuestionID: &questionID, Category: trainer.ReportWrongAnswer},
+			"New report on question 42: " + trainer.ReportWrongAnswer.Label(),
+			"https://trainer.example/admin/feedback?question=42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier, mailer := newTestNotifier("admin@example.com", "referees@example.com")
+			notifier.FeedbackSubmitted(tt.feedback)
+			messages := mailer.receive(t, 2)
+			recipients := map[string]bool{}
+			for _, message := range messages {
+				recipients[message.To] = true
+				if message.Subject != tt.wantSubject {
+					t.Errorf("subject = %q, want %q", message.Subject, tt.wantSubject)
+				}
+				if !strings.Contains(message.Body, "Review it at "+tt.wantURL) || !strings.Contains(message.Body, tt.feedback.Text) {
+					t.Errorf("body = %q, want the feedback and a link to %s", message.Body, tt.wantURL)
+				}
+			}
+			if !recipients["admin@example.com"] || !recipients["referees@example.com"] {
+				t.Errorf("emailed %v, want every admin", recipients)
+			}
+			if url := notifier.payload(tt.feedback).URL; url != tt.wantURL {
+				t.Errorf("webhook url = %q, want %q", url, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestFeedbackCompleted(t *testing.T) {
+	notifier, mailer := newTestNotifier("admin@example.com")
+	notifier.FeedbackCompleted(trainer.Feedback{
+		ID:          1,
+		Name:        "Anna",
+		Email:       "anna@example.com",
+		Topic:       "test",
+		Text:        "The test is too short.\nAdd more questions.",
+		IsCompleted: true,
+		CreatedAt:   time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	})
+	message := mailer.receive(t, 1)[0]
+	select {
+	case other := <-mailer.sent:
+		t.Errorf("also emailed %s, want only the submitter", other.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if message.To != "anna@example.com" {
+		t.Errorf("emailed %s, want the submitter", message.To)
+	}
+	if message.Subject != "Your feedback to the IHF Referee Trainer has been addressed" {
+		t.Errorf("subject = %q", message.Subject)
+	}
+	for _, want := range []string{"Hi Anna,", "feedback of 1 March 2024", "> The test is too short.\n> Add more questions.", "https://trainer.example"} {
+		if !strings.Contains(message.Body, want) {
+			t.Errorf("body = %q, want it to contain %q", message.Body, want)
+		}
+	}
+}
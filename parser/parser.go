@@ -174,14 +174,26 @@ type AnswersAndReferences struct {
 
 // ParseAnswer returns the list of answers and references for a given rule and question number
 func ParseAnswer(file io.Reader) map[string]map[int]AnswersAndReferences {
-	ansMap := map[string]map[int]AnswersAndReferences{}
 	s, _ := pdf.PdfToText(file)
-	for _, s := range strings.Split(s, "\n") {
+	ansMap, _ := parseAnswerText(s)
+	return ansMap
+}
+
+// parseAnswerText returns the answers and references by rule and question number,
+// with the problems of the lines that could not be read
+func parseAnswerText(text string) (map[string]map[int]AnswersAndReferences, []string) {
+	ansMap := map[string]map[int]AnswersAndReferences{}
+	var problems []string
+	for i, s := range strings.Split(text, "\n") {
 		s = strings.TrimSpace(s)
 		if !hasAnswers(s) {
 			continue
 		}
-		rule, questionNum, answers, references := splitAnswer(s)
+		rule, questionNum, answers, references, ok := splitAnswer(s)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("answers line %d is not a question number followed by its answers: %q", i+1, s))
+			continue
+		}
 		ruleMap, ok := ansMap[rule]
 		if ok {
 			ruleMap[questionNum] = AnswersAndReferences{
@@ -197,7 +209,7 @@ func ParseAnswer(file io.Reader) map[string]map[int]AnswersAndReferences {
 			}
 		}
 	}
-	return ansMap
+	return ansMap, problems
 }
 
 func hasAnswers(s string) bool {
@@ -206,14 +218,24 @@ func hasAnswers(s string) bool {
 	return regex.MatchString(s)
 }
 
-// Split the answer into the rule number, question number, the list of answers and the references
-func splitAnswer(s string) (string, int, []string, []string) {
+// Split the answer into the rule number, question number, the list of answers and the references,
+// false when the line has no answers. The references are optional.
+func splitAnswer(s string) (string, int, []string, []string, bool) {
 	// split into chunks of ruleNumber.QuestionNumber, answers, and references
 	fields := regexp.MustCompile(` {2,}`).Split(s, -1)
+	if len(fields) < 2 {
+		return "", 0, nil, nil, false
+	}
 	rule, questionNumber := getRuleQuestionNum(fields[0])
+	if rule == "" || questionNumber == 0 {
+		return "", 0, nil, nil, false
+	}
 	correctAnswers := strings.Split(fields[1], ", ")
-	references := strings.Split(fields[2], ", ")
-	return rule, questionNumber, correctAnswers, references
+	var references []string
+	if len(fields) > 2 {
+		references = strings.Split(fields[2], ", ")
+	}
+	return rule, questionNumber, correctAnswers, references, true
 }
 
 // soome rule question number uses comma, while others uses colon
@@ -235,9 +257,105 @@ func getRuleQuestionNum(s string) (string, int) {
 		questionNumberString = strings.TrimLeft(s, "SAR")
 	} else {
 		arr := strings.FieldsFunc(s, ruleQuestionNumSeparator)
+		if len(arr) != 2 {
+			return "", 0
+		}
 		rule = arr[0]
 		questionNumberString = arr[1]
 	}
 	questionNumber, _ := strconv.Atoi(questionNumberString)
 	return rule, questionNumber
 }
+
+// Edition is a parsed edition of the questions and answers, with the problems that make it unfit to publish
+type Edition struct {
+	Questions []Question
+	Problems  []string
+}
+
+// ParseEdition parses the text of the questions and the answers documents. Unlike ParseQuestion it does not stop
+// at the first problem, every problem found is returned so that the documents can be fixed in one go.
+func ParseEdition(questionText string, answerText string) Edition {
+	answerMap, problems := parseAnswerText(answerText)
+	tokenizer := token.NewTokenizer()
+	var tokens []token.Token
+	for _, line := range strings.Split(questionText, "\n") {
+		t, err := tokenizer.Tokenize(line)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		tokens = append(tokens, *t)
+	}
+	var edition Edition
+	seen := map[string]bool{}
+	for _, group := range groupByQuestions(tokens) {
+		if len(group) == 0 {
+			continue
+		}
+		q, err := toQuestion(len(edition.Questions)+1, group, answerMap)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		number := questionNumber(q.Rule.ID, q.QuestionNumber)
+		if seen[number] {
+			problems = append(problems, fmt.Sprintf("question %s appears more than once", number))
+			continue
+		}
+		seen[number] = true
+		problems = append(problems, checkQuestion(*q, answerMap)...)
+		edition.Questions = append(edition.Questions, *q)
+	}
+	if len(edition.Questions) == 0 {
+		problems = append(problems, "no questions were found in the questions document")
+	}
+	// the answers are in a map, sorted so that the problems are listed in the same order every time
+	var unmatched []string
+	for rule, ruleMap := range answerMap {
+		for n := range ruleMap {
+			if number := questionNumber(rule, n); !seen[number] {
+				unmatched = append(unmatched, fmt.Sprintf("the answers of question %s have no question", number))
+			}
+		}
+	}
+	slices.Sort(unmatched)
+	edition.Problems = append(problems, unmatched...)
+	return edition
+}
+
+// checkQuestion returns the problems of a parsed question: no choices, repeated options or answers that are missing
+// or not among its choices
+func checkQuestion(q Question, answerMap map[string]map[int]AnswersAndReferences) []string {
+	var problems []string
+	number := questionNumber(q.Rule.ID, q.QuestionNumber)
+	if len(q.Choices) == 0 {
+		problems = append(problems, fmt.Sprintf("question %s has no choices", number))
+	}
+	var options []string
+	for _, c := range q.Choices {
+		if slices.Contains(options, c.Option) {
+			problems = append(problems, fmt.Sprintf("question %s has choice %s more than once", number, c.Option))
+		}
+		options = append(options, c.Option)
+	}
+	answers, ok := answerMap[q.Rule.ID][q.QuestionNumber]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("question %s has no answers in the answers document", number))
+		return problems
+	}
+	for _, answer := range answers.Answers {
+		if !slices.Contains(options, answer) {
+			problems = append(problems, fmt.Sprintf("question %s has answer %q that is not one of its choices", number, answer))
+		}
+	}
+	return problems
+}
+
+// questionNumber returns the number of the question as printed in the documents, e.g. 18.7 or SAR1
+func questionNumber(rule string, n int) string {
+	if rule == "SAR" {
+		return fmt.Sprintf("%s%d", rule, n)
+	}
+	return fmt.Sprintf("%s.%d", rule, n)
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"os"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// parseFixture parses the questions and answers documents saved as text in testdata
+func parseFixture(t *testing.T, questionFile string, answerFile string) Edition {
+	t.Helper()
+	questionText, err := os.ReadFile("testdata/" + questionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answerText, err := os.ReadFile("testdata/" + answerFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseEdition(string(questionText), string(answerText))
+}
+
+func TestParseEdition(t *testing.T) {
+	edition := parseFixture(t, "questions.txt", "answers.txt")
+	if len(edition.Problems) > 0 {
+		t.Fatalf("ParseEdition() problems = %q, want none", edition.Problems)
+	}
+	tests := []struct {
+		number     string
+		text       string
+		options    []string
+		answers    []string
+		references []string
+	}{
+		{"8.1", "A player pushes an opponent in the back. Correct decision?", []string{"a", "b", "c"}, []string{"a", "b"}, []string{"8:3", "16:3"}},
+		{"8.2", "The goalkeeper leaves the goal area with the ball. Correct decision?", []string{"a", "b"}, []string{"a"}, nil},
+		{"SAR1", "A substitute enters too early. Correct decision?", []string{"a", "b"}, []string{"a"}, []string{"SAR 4"}},
+	}
+	if len(edition.Questions) != len(tests) {
+		t.Fatalf("ParseEdition() found %d questions, want %d", len(edition.Questions), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			q := edition.Questions[i]
+			if number := questionNumber(q.Rule.ID, q.QuestionNumber); number != tt.number {
+				t.Fatalf("question %d is %s, want %s", i, number, tt.number)
+			}
+			if q.Text != tt.text {
+				t.Errorf("text = %q, want %q", q.Text, tt.text)
+			}
+			var options, answers, references []string
+			for _, c := range q.Choices {
+				options = append(options, c.Option)
+				if c.IsAnswer {
+					answers = append(answers, c.Option)
+				}
+			}
+			for _, r := range q.References {
+				references = append(references, r.Text)
+			}
+			if !slices.Equal(options, tt.options) || !slices.Equal(answers, tt.answers) {
+				t.Errorf("options %q with answers %q, want %q with %q", options, answers, tt.options, tt.answers)
+			}
+			if !slices.Equal(references, tt.references) {
+				t.Errorf("references = %q, want %q", references, tt.references)
+			}
+		})
+	}
+}
+
+func TestParseEditionProblems(t *testing.T) {
+	edition := parseFixture(t, "problems_questions.txt", "problems_answers.txt")
+	want := []string{
+		`answers line 6 is not a question number followed by its answers: "8.7)"`,
+		"question 8.1 appears more than once",
+		"question 8.3 has no choices",
+		`question 8.3 has answer "a" that is not one of its choices`,
+		"question 8.4 has choice a more than once",
+		`question 8.5 has answer "c" that is not one of its choices`,
+		"question 8.6 has no answers in the answers document",
+		"the answers of question 9.1 have no question",
+	}
+	if !slices.Equal(edition.Problems, want) {
+		t.Errorf("ParseEdition() problems =\n%q\nwant\n%q", edition.Problems, want)
+	}
+	if len(edition.Questions) != 5 {
+		t.Errorf("ParseEdition() kept %d questions, want 5 without the duplicate", len(edition.Questions))
+	}
+}
+
+func TestCheckQuestion(t *testing.T) {
+	answerMap := map[string]map[int]AnswersAndReferences{"8": {1: {Answers: []string{"a"}}, 2: {Answers: []string{"a", "d"}}}}
+	choices := []Choice{{Option: "a"}, {Option: "b"}}
+	tests := []struct {
+		name     string
+		question Question
+		want     []string
+	}{
+		{"valid", Question{Rule: Rule{ID: "8"}, QuestionNumber: 1, Choices: choices}, nil},
+		{"no choices", Question{Rule: Rule{ID: "8"}, QuestionNumber: 1}, []string{
+			"question 8.1 has no choices",
+			`question 8.1 has answer "a" that is not one of its choices`,
+		}},
+		{"choice twice", Question{Rule: Rule{ID: "8"}, QuestionNumber: 1, Choices: append(slices.Clone(choices), Choice{Option: "b"})}, []string{
+			"question 8.1 has choice b more than once",
+		}},
+		{"answer not a choice", Question{Rule: Rule{ID: "8"}, QuestionNumber: 2, Choices: choices}, []string{
+			`question 8.2 has answer "d" that is not one of its choices`,
+		}},
+		{"no answers", Question{Rule: Rule{ID: "SAR"}, QuestionNumber: 1, Choices: choices}, []string{
+			"question SAR1 has no answers in the answers document",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkQuestion(tt.question, answerMap); !slices.Equal(got, tt.want) {
+				t.Errorf("checkQuestion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,6 +11,9 @@ var (
 
 	//go:embed html
 	html embed.FS
+
+	//go:embed api/openapi.json
+	openAPI []byte
 )
 
 func HTML() (fs.FS, error) {
@@ -20,3 +23,8 @@ func HTML() (fs.FS, error) {
 func Static() (fs.FS, error) {
 	return fs.Sub(static, "static")
 }
+
+// OpenAPI returns the OpenAPI document describing the JSON api
+func OpenAPI() []byte {
+	return openAPI
+}
@@ -40,9 +40,27 @@ document.getElementById('toggle-button').addEventListener('click', function () {
 const CHOICE_CHECK_MAP_KEY = 'choiceCheckMap';
 const READ_CHECK_MAP_KEY = 'readCheckMap';
 const SHOW_ANSWERS_KEY = 'showAnswers';
+const SAVE_ANSWER_DELAY_MS = 1500;
+
+// Logged in users have their choices and read marks rendered by and saved to the server,
+// anonymous users keep them in localStorage
+const questionsContainer = document.querySelector('.questions-container');
+const isLoggedIn = questionsContainer.dataset.loggedIn === 'true';
+const saveAnswerTimers = {};
 
 // Load state from localStorage
 function loadState() {
+    const isShowingAnswers = localStorage.getItem(SHOW_ANSWERS_KEY) === 'true';
+    if (isShowingAnswers) {
+        document.getElementById('toggle-button').click();
+    }
+    if (isLoggedIn) {
+        if (questionsContainer.dataset.importProgress === 'true') {
+            importProgress();
+        }
+        return;
+    }
+
     const choiceCheckboxes = document.querySelectorAll('.choice input[type="checkbox"]');
     const choiceCheckMap = JSON.parse(localStorage.getItem(CHOICE_CHECK_MAP_KEY)) || {};
     choiceCheckboxes.forEach(checkbox => {
@@ -61,15 +79,54 @@ function loadState() {
             checkbox.closest('.question-card').classList.add('read');
         }
     });
+}
 
-    const isShowingAnswers = localStorage.getItem(SHOW_ANSWERS_KEY) === 'true';
-    if (isShowingAnswers) {
-        document.getElementById('toggle-button').click();
-    }
+// Send the progress saved in localStorage before logging in to the server, which only imports it once
+function importProgress() {
+    const choiceCheckMap = JSON.parse(localStorage.getItem(CHOICE_CHECK_MAP_KEY)) || {};
+    const readCheckMap = JSON.parse(localStorage.getItem(READ_CHECK_MAP_KEY)) || {};
+    fetch('/progress/import', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({choiceCheckMap, readCheckMap}),
+    }).then(response => {
+        if (!response.ok) {
+            return;
+        }
+        localStorage.removeItem(CHOICE_CHECK_MAP_KEY);
+        localStorage.removeItem(READ_CHECK_MAP_KEY);
+        if (Object.values(choiceCheckMap).includes(true) || Object.values(readCheckMap).includes(true)) {
+            window.location.reload();
+        }
+    });
+}
+
+// Save the selected choices of a question once the user stops changing them
+function saveAnswer(card) {
+    const questionId = card.dataset.questionId;
+    clearTimeout(saveAnswerTimers[questionId]);
+    saveAnswerTimers[questionId] = setTimeout(() => {
+        const body = new URLSearchParams();
+        card.querySelectorAll('.choice input[type="checkbox"]:checked').forEach(choice => {
+            body.append('choices', choice.value);
+        });
+        fetch(`/progress/answers/${questionId}`, {method: 'POST', body});
+    }, SAVE_ANSWER_DELAY_MS);
+}
+
+function saveRead(card, isRead) {
+    const body = new URLSearchParams({read: `${isRead}`});
+    fetch(`/progress/read/${card.dataset.questionId}`, {method: 'POST', body});
 }
 
 // Save state to localStorage
 function saveState() {
+    const isShowingAnswers = document.getElementById('toggle-button').className.includes('hide');
+    localStorage.setItem(SHOW_ANSWERS_KEY, `${isShowingAnswers}`);
+    if (isLoggedIn) {
+        return;
+    }
+
     const choiceCheckboxes = document.querySelectorAll('.choice input[type="checkbox"]');
     const choiceCheckMap = {};
     choiceCheckboxes.forEach(checkbox => {
@@ -83,15 +140,17 @@ function saveState() {
         readCheckMap[checkbox.closest('.question-card').id] = checkbox.checked;
     });
     localStorage.setItem(READ_CHECK_MAP_KEY, JSON.stringify(readCheckMap));
-
-    const isShowingAnswers = document.getElementById('toggle-button').className.includes('hide');
-    localStorage.setItem(SHOW_ANSWERS_KEY, `${isShowingAnswers}`);
 }
 
 document.addEventListener('DOMContentLoaded', loadState);
 
 document.querySelectorAll('.choice input[type="checkbox"]').forEach(checkbox => {
-    checkbox.addEventListener('change', saveState);
+    checkbox.addEventListener('change', function () {
+        if (isLoggedIn) {
+            saveAnswer(this.closest('.question-card'));
+        }
+        saveState();
+    });
 });
 
 document.querySelectorAll('.read-checkbox').forEach(checkbox => {
@@ -102,6 +161,9 @@ document.querySelectorAll('.read-checkbox').forEach(checkbox => {
         } else {
             card.classList.remove('read');
         }
+        if (isLoggedIn) {
+            saveRead(card, this.checked);
+        }
         saveState();
     });
 });
@@ -127,7 +189,12 @@ document.getElementById('clear-button').addEventListener('click', function () {
     choiceCheckboxes.forEach(checkbox => {
         checkbox.checked = false;
     });
-    localStorage.setItem(CHOICE_CHECK_MAP_KEY, JSON.stringify({}));
+    if (isLoggedIn) {
+        Object.values(saveAnswerTimers).forEach(clearTimeout);
+        fetch('/progress/clear', {method: 'POST'});
+    } else {
+        localStorage.setItem(CHOICE_CHECK_MAP_KEY, JSON.stringify({}));
+    }
 
     // Optionally, clear the answer highlights if they are visible
     const isShowingAnswers = localStorage.getItem(SHOW_ANSWERS_KEY) === 'true';
@@ -0,0 +1,177 @@
+// Live quiz page of the host and of the participants. The server streams the state of the session as
+// Server-Sent Events and the page is rendered from the latest state, the browser reconnects on its own.
+const live = document.getElementById('live');
+const isHost = live.dataset.host === 'true';
+const roundElement = document.getElementById('live-round');
+const errorElement = document.getElementById('live-error');
+const leaderboardElement = document.getElementById('live-leaderboard');
+
+let state = null;
+// roundKey is what the question form was last rendered for, so that a participant does not lose the options
+// being ticked when the answer count changes
+let roundKey = '';
+
+function element(tag, className, text) {
+    const e = document.createElement(tag);
+    if (className) {
+        e.className = className;
+    }
+    if (text !== undefined) {
+        e.textContent = text;
+    }
+    return e;
+}
+
+function showError(message) {
+    errorElement.textContent = message;
+    errorElement.hidden = !message;
+}
+
+function remainingSeconds() {
+    return Math.max(0, Math.ceil((state.round.deadlineUnixMilli - Date.now()) / 1000));
+}
+
+function renderLeaderboard() {
+    leaderboardElement.replaceChildren(...state.leaderboard.map(row => {
+        const li = element('li', '', `${row.name} – ${row.score}`);
+        li.value = row.rank;
+        return li;
+    }));
+}
+
+function renderStatus() {
+    if (isHost) {
+        document.getElementById('live-participants').textContent = state.participantCount;
+        return;
+    }
+    const status = document.getElementById('live-status');
+    const you = document.getElementById('live-you');
+    if (state.you) {
+        you.textContent = `${state.you.name}: ${state.you.score} points, rank ${state.you.rank} of ${state.participantCount}`;
+    }
+    switch (state.status) {
+        case 'lobby':
+            status.textContent = 'Waiting for the host to push the first question.';
+            break;
+        case 'question':
+            status.textContent = state.you && state.you.selected ? 'Answer saved, waiting for the others.' : 'Answer before the time is up.';
+            break;
+        case 'reveal':
+            status.textContent = state.you && state.you.points !== undefined
+                ? `You earned ${state.you.points} points on this question.`
+                : 'You did not answer this question.';
+            break;
+        case 'ended':
+            status.textContent = 'The session has ended.';
+            break;
+    }
+}
+
+function renderRound() {
+    const round = state.round;
+    if (!round) {
+        roundElement.hidden = true;
+        roundKey = '';
+        return;
+    }
+    const selected = (state.you && state.you.selected) || [];
+    const key = isHost ? '' : `${state.status}:${round.number}:${selected.join(',')}`;
+    if (!isHost && key === roundKey) {
+        return;
+    }
+    roundKey = key;
+
+    const isOpen = state.status === 'question';
+    const isAnswered = !isHost && state.you && state.you.selected;
+    const correct = round.correctOptions || [];
+    const total = Object.values(round.distribution || {}).reduce((sum, count) => sum + count, 0);
+
+    const children = [];
+    const header = element('div', 'question-header');
+    header.append(element('div', 'question-number', `Question ${round.number}`));
+    if (isOpen) {
+        header.append(element('div', 'live-countdown', `${remainingSeconds()}s`));
+    }
+    children.push(header);
+    children.push(element('div', 'question-text', `${round.ruleQuestionNumber}) ${round.text}`));
+
+    const choices = element('div', 'choices');
+    for (const choice of round.choices) {
+        const label = element('label', 'choice');
+        if (correct.includes(choice.option)) {
+            label.classList.add('live-correct');
+        }
+        if (!isHost) {
+            const input = element('input');
+            input.type = 'checkbox';
+            input.name = 'choices';
+            input.value = choice.option;
+            input.checked = selected.includes(choice.option);
+            input.disabled = !isOpen || isAnswered;
+            label.append(input, ' ');
+        }
+        label.append(choice.text);
+        if (round.distribution) {
+            const count = round.distribution[choice.option] || 0;
+            const bar = element('div', 'live-bar');
+            bar.style.width = `${total ? count / total * 100 : 0}%`;
+            label.append(element('span', 'live-count', ` ${count}`), bar);
+        }
+        choices.append(label);
+    }
+    children.push(choices);
+
+    if (isHost) {
+        children.push(element('p', 'review-status', `${round.answerCount} of ${state.participantCount} answered`));
+    } else if (isOpen && !isAnswered) {
+        // the round is posted with the answer, so that an answer to a question replaced meanwhile is refused
+        const number = element('input');
+        number.type = 'hidden';
+        number.name = 'round';
+        number.value = round.number;
+        children.push(number, element('button', '', 'Submit Answer'));
+    }
+    roundElement.replaceChildren(...children);
+    roundElement.hidden = false;
+}
+
+function render() {
+    renderStatus();
+    renderRound();
+    renderLeaderboard();
+}
+
+function updateCountdown() {
+    const countdown = roundElement.querySelector('.live-countdown');
+    if (countdown && state && state.round) {
+        countdown.textContent = `${remainingSeconds()}s`;
+    }
+}
+
+// Host actions and answers are posted in the background, the page is updated by the next state
+document.addEventListener('submit', async event => {
+    const form = event.target;
+    if (!form.classList.contains('live-action')) {
+        return;
+    }
+    event.preventDefault();
+    if (form.dataset.confirm && !confirm(form.dataset.confirm)) {
+        return;
+    }
+    const response = await fetch(form.action, {
+        method: 'POST',
+        body: new URLSearchParams(new FormData(form)),
+    });
+    showError(response.ok ? '' : await response.text());
+});
+
+const events = new EventSource(live.dataset.events);
+events.addEventListener('state', event => {
+    state = JSON.parse(event.data);
+    render();
+    if (state.status === 'ended') {
+        events.close();
+    }
+});
+
+setInterval(updateCountdown, 500);
@@ -0,0 +1,21 @@
+// Countdown to the deadline of the exam, submitting the exam when the time is up.
+// The server refuses answers after the deadline, this only keeps the user informed.
+const timer = document.getElementById('exam-timer');
+const deadline = Number(timer.dataset.deadline);
+
+function updateTimer() {
+    const remaining = Math.max(0, deadline - Date.now());
+    const minutes = Math.floor(remaining / 60000);
+    const seconds = Math.floor((remaining % 60000) / 1000);
+    timer.innerText = `${minutes}:${String(seconds).padStart(2, '0')}`;
+    timer.classList.toggle('ending', remaining < 60000);
+    if (remaining === 0) {
+        clearInterval(timerInterval);
+        const form = document.getElementById('submit-exam-form');
+        form.dataset.timeUp = 'true';
+        form.requestSubmit();
+    }
+}
+
+const timerInterval = setInterval(updateTimer, 1000);
+updateTimer();
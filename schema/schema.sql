@@ -46,9 +46,314 @@ create table
     topic    text not null,
     text text not null,
     is_acknowledged  boolean default false,
-    is_completed     boolean default false
+    is_completed     boolean default false,
+    created_at       timestamptz not null default now(),
+    -- question_id and category are only set on the reports of a problem with a question
+    question_id      bigint references question (id) on delete set null,
+    category         text check (category in ('wrong_answer', 'typo', 'ambiguous', 'outdated_reference'))
 );
+CREATE INDEX idx_feedback_question_id ON feedback (question_id);
 
 UPDATE question
 SET tsv = setweight(to_tsvector(text), 'A');
-CREATE INDEX idx_question_tsv ON question USING GIN (tsv);
\ No newline at end of file
+CREATE INDEX idx_question_tsv ON question USING GIN (tsv);
+
+-- fuzzy search
+create extension if not exists pg_trgm;
+
+create table
+    search_word
+(
+    word text primary key not null
+);
+
+INSERT INTO search_word
+SELECT word
+FROM ts_stat('SELECT to_tsvector(''simple'', text) FROM question');
+CREATE INDEX idx_search_word_trgm ON search_word USING GIN (word gin_trgm_ops);
+CREATE INDEX idx_question_text_trgm ON question USING GIN (text gin_trgm_ops);
+
+-- user accounts
+create table
+    "user"
+(
+    id            bigint primary key generated by default as identity,
+    email         text        not null unique,
+    name          text        not null,
+    password_hash text        not null,
+    created_at    timestamptz not null default now(),
+    -- admins manage content and feedback, instructors manage classes and sets, referees practise
+    role          text        not null default 'referee'
+        check (role in ('admin', 'instructor', 'referee'))
+);
+
+create table
+    session
+(
+    token_hash text primary key not null,
+    user_id    bigint           not null references "user" (id) on delete cascade,
+    created_at timestamptz      not null default now(),
+    expires_at timestamptz      not null
+);
+CREATE INDEX idx_session_user_id ON session (user_id);
+
+create table
+    password_reset
+(
+    token_hash text primary key not null,
+    user_id    bigint           not null references "user" (id) on delete cascade,
+    expires_at timestamptz      not null,
+    used_at    timestamptz
+);
+
+-- progress of logged in users
+create table
+    answer
+(
+    id               bigint primary key generated by default as identity,
+    user_id          bigint      not null references "user" (id) on delete cascade,
+    question_id      bigint      not null references question (id),
+    selected_options text[]      not null,
+    is_correct       boolean     not null,
+    -- where the answer was given: home, exam, practice or review
+    source           text        not null default 'home',
+    created_at       timestamptz not null default now()
+);
+CREATE INDEX idx_answer_user_id_question_id ON answer (user_id, question_id, created_at);
+
+create table
+    question_read
+(
+    user_id     bigint      not null references "user" (id) on delete cascade,
+    question_id bigint      not null references question (id),
+    created_at  timestamptz not null default now(),
+    primary key (user_id, question_id)
+);
+
+create table
+    progress
+(
+    user_id     bigint primary key not null references "user" (id) on delete cascade,
+    -- when the progress saved in the browser before the user logged in was imported
+    imported_at timestamptz,
+    -- answers given before the selections were cleared are no longer shown as selected
+    cleared_at  timestamptz
+);
+
+-- spaced repetition schedule of every question answered by a user, following SM-2
+create table
+    review
+(
+    user_id       bigint           not null references "user" (id) on delete cascade,
+    question_id   bigint           not null references question (id),
+    ease          double precision not null,
+    interval_days integer          not null,
+    -- number of correct answers in a row
+    repetitions   integer          not null,
+    due_at        timestamptz      not null,
+    reviewed_at   timestamptz      not null,
+    primary key (user_id, question_id)
+);
+CREATE INDEX idx_review_user_id_due_at ON review (user_id, due_at);
+
+-- timed mock exams
+create table
+    exam_template
+(
+    id               bigint primary key generated by default as identity,
+    name             text    not null,
+    description      text    not null default '',
+    duration_minutes integer not null,
+    -- percentage of the maximum score needed to pass
+    pass_mark        integer not null,
+    -- how answers are scored: exact, partial or negative
+    scoring          text    not null default 'exact' check (scoring in ('exact', 'partial', 'negative')),
+    -- private templates are only taken through an assignment
+    is_public        boolean not null default true
+);
+
+create table
+    exam_template_rule
+(
+    exam_template_id bigint  not null references exam_template (id) on delete cascade,
+    rule_id          text    not null references rule (id),
+    question_count   integer not null,
+    primary key (exam_template_id, rule_id)
+);
+
+-- the questions of a template that does not draw them from its rules
+create table
+    exam_template_question
+(
+    exam_template_id bigint  not null references exam_template (id) on delete cascade,
+    position         integer not null,
+    question_id      bigint  not null references question (id),
+    primary key (exam_template_id, position),
+    unique (exam_template_id, question_id)
+);
+
+create table
+    exam
+(
+    id               bigint primary key generated by default as identity,
+    exam_template_id bigint      not null references exam_template (id),
+    user_id          bigint      not null references "user" (id) on delete cascade,
+    started_at       timestamptz not null default now(),
+    deadline         timestamptz not null,
+    submitted_at     timestamptz,
+    correct_count    integer,
+    score            double precision,
+    is_passed        boolean
+);
+CREATE INDEX idx_exam_user_id ON exam (user_id);
+
+create table
+    exam_question
+(
+    exam_id          bigint  not null references exam (id) on delete cascade,
+    position         integer not null,
+    question_id      bigint  not null references question (id),
+    selected_options text[]  not null default '{}',
+    is_correct       boolean,
+    score            double precision,
+    -- the choices as they were graded when the exam was submitted, later edits of the question do not change them
+    choices          jsonb,
+    primary key (exam_id, position),
+    unique (exam_id, question_id)
+);
+
+-- a written test of 2 questions from every rule, to be adjusted to the national test
+INSERT INTO exam_template (name, description, duration_minutes, pass_mark)
+VALUES ('Rules of the Game Test', 'Two questions from every rule', 45, 80);
+INSERT INTO exam_template_rule (exam_template_id, rule_id, question_count)
+SELECT t.id, r.id, 2
+FROM exam_template t CROSS JOIN rule r
+WHERE t.name = 'Rules of the Game Test';
+
+-- instructor classes, referees enrol with the invite code
+create table
+    class
+(
+    id            bigint primary key generated by default as identity,
+    name          text        not null,
+    instructor_id bigint      not null references "user" (id) on delete cascade,
+    invite_code   text        not null unique,
+    created_at    timestamptz not null default now()
+);
+CREATE INDEX idx_class_instructor_id ON class (instructor_id);
+
+create table
+    class_member
+(
+    class_id  bigint      not null references class (id) on delete cascade,
+    user_id   bigint      not null references "user" (id) on delete cascade,
+    joined_at timestamptz not null default now(),
+    primary key (class_id, user_id)
+);
+CREATE INDEX idx_class_member_user_id ON class_member (user_id);
+
+-- an assignment is taken as exams of its private exam template
+create table
+    assignment
+(
+    id               bigint primary key generated by default as identity,
+    class_id         bigint      not null references class (id) on delete cascade,
+    title            text        not null,
+    exam_template_id bigint      not null references exam_template (id),
+    due_at           timestamptz not null,
+    created_at       timestamptz not null default now()
+);
+CREATE INDEX idx_assignment_class_id ON assignment (class_id);
+CREATE INDEX idx_exam_exam_template_id ON exam (exam_template_id);
+
+-- the tickets of the exams of the exam page that were submitted, so that a ticket is graded only once
+create table
+    used_exam_ticket
+(
+    id      text primary key,
+    used_at timestamptz not null default now()
+);
+
+-- a question set is a curated list of questions, shared with a link to its share code
+create table
+    question_set
+(
+    id          bigint primary key generated by default as identity,
+    name        text        not null,
+    description text        not null default '',
+    owner_id    bigint      not null references "user" (id) on delete cascade,
+    share_code  text        not null unique,
+    created_at  timestamptz not null default now(),
+    updated_at  timestamptz not null default now()
+);
+CREATE INDEX idx_question_set_owner_id ON question_set (owner_id);
+
+create table
+    question_set_question
+(
+    question_set_id bigint not null references question_set (id) on delete cascade,
+    question_id     bigint not null references question (id) on delete cascade,
+    position        int    not null,
+    primary key (question_set_id, question_id)
+);
+
+-- every change of the status of a feedback by an admin
+create table
+    feedback_audit
+(
+    id          bigint primary key generated by default as identity,
+    feedback_id bigint      not null references feedback (id) on delete cascade,
+    user_id     bigint      not null references "user" (id),
+    field       text        not null,
+    old_value   boolean     not null,
+    new_value   boolean     not null,
+    created_at  timestamptz not null default now()
+);
+CREATE INDEX idx_feedback_audit_feedback_id ON feedback_audit (feedback_id);
+
+-- every edit of a question by an admin, with the text, choices and references before and after it
+create table
+    question_revision
+(
+    id                   bigint primary key generated by default as identity,
+    question_id          bigint      not null references question (id),
+    user_id              bigint      not null references "user" (id),
+    before               jsonb       not null,
+    after                jsonb       not null,
+    -- whether the edit changed which choices are correct, users are told the answer was corrected
+    is_answer_changed    boolean     not null,
+    -- the revision undone by this one, null for an edit
+    reverted_revision_id bigint references question_revision (id),
+    created_at           timestamptz not null default now()
+);
+CREATE INDEX idx_question_revision_question_id ON question_revision (question_id, id);
+
+-- revisions are the history of the questions, they are never changed
+create function reject_revision_change() returns trigger as
+$$
+begin
+    raise exception 'question revisions cannot be changed';
+end;
+$$ language plpgsql;
+create trigger question_revision_immutable
+    before update or delete
+    on question_revision
+    for each row
+execute function reject_revision_change();
+
+-- uploads of the questions and answers PDFs of an edition, previewed by an admin before they are published
+create table
+    edition_import
+(
+    id            bigint primary key generated by default as identity,
+    user_id       bigint      not null references "user" (id),
+    question_file text        not null,
+    answer_file   text        not null,
+    -- the parsed questions with their choices and references
+    questions     jsonb       not null,
+    -- what was wrong in the documents, an import with problems cannot be published
+    problems      jsonb       not null,
+    created_at    timestamptz not null default now(),
+    published_by  bigint references "user" (id),
+    published_at  timestamptz
+);
@@ -0,0 +1,105 @@
+package trainer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// wrongQuestionWeight favours the questions the user got wrong the last time they answered them
+	wrongQuestionWeight = 2.0
+	// recencyDays is how long it takes for a question answered correctly to be as likely as a new question again
+	recencyDays = 30.0
+	// minQuestionWeight keeps the questions answered correctly a moment ago in the draw
+	minQuestionWeight = 0.1
+)
+
+// practiceCandidate is a question that can be chosen, with the answer history of its question and its rule
+type practiceCandidate struct {
+	stat        PracticeStatEntity
+	ruleAttempt int
+	ruleWrong   int
+	weight      float64
+}
+
+// chooseAdaptive draws one of the questions at random, weighted by the error rate of the user on its rule and by
+// how the user did on the question the last time. Questions in excludeIDs are left out, unless every question is.
+// random returns a number in [0, 1) to draw with. It returns false when there is no question to choose from.
+func chooseAdaptive(stats []PracticeStatEntity, excludeIDs []int, now time.Time, random func() float64) (practiceCandidate, bool) {
+	ruleAttempts := make(map[string]int)
+	ruleWrongs := make(map[string]int)
+	for _, stat := range stats {
+		ruleAttempts[stat.RuleID] += stat.AttemptCount
+		ruleWrongs[stat.RuleID] += stat.WrongCount
+	}
+
+	remaining := slices.DeleteFunc(slices.Clone(stats), func(stat PracticeStatEntity) bool {
+		return slices.Contains(excludeIDs, stat.QuestionID)
+	})
+	if len(remaining) == 0 {
+		remaining = stats
+	}
+	if len(remaining) == 0 {
+		return practiceCandidate{}, false
+	}
+
+	candidates := make([]practiceCandidate, 0, len(remaining))
+	totalWeight := 0.0
+	for _, stat := range remaining {
+		candidate := practiceCandidate{
+			stat:        stat,
+			ruleAttempt: ruleAttempts[stat.RuleID],
+			ruleWrong:   ruleWrongs[stat.RuleID],
+		}
+		candidate.weight = ruleWeight(candidate.ruleAttempt, candidate.ruleWrong) * questionWeight(stat, now)
+		totalWeight += candidate.weight
+		candidates = append(candidates, candidate)
+	}
+
+	pick := random() * totalWeight
+	for _, candidate := range candidates {
+		pick -= candidate.weight
+		if pick < 0 {
+			return candidate, true
+		}
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// ruleWeight is the smoothed error rate of the user on a rule, a rule never answered counts as half wrong
+func ruleWeight(attempts int, wrongs int) float64 {
+	return float64(wrongs+1) / float64(attempts+2)
+}
+
+// questionWeight favours new questions and questions answered wrongly, questions answered correctly
+// come back gradually over recencyDays
+func questionWeight(stat PracticeStatEntity, now time.Time) float64 {
+	if stat.LastAnsweredAt == nil || stat.IsLastCorrect == nil {
+		return 1
+	}
+	if !*stat.IsLastCorrect {
+		return wrongQuestionWeight
+	}
+	days := now.Sub(*stat.LastAnsweredAt).Hours() / 24
+	return math.Max(minQuestionWeight, math.Min(1, days/recencyDays))
+}
+
+// reason explains to the user why the question was chosen
+func (c practiceCandidate) reason(rule Rule, now time.Time) string {
+	switch {
+	case c.stat.IsLastCorrect != nil && !*c.stat.IsLastCorrect:
+		return "You got this question wrong the last time you answered it"
+	case c.ruleWrong > 0:
+		return fmt.Sprintf("You got %d of %d %s questions wrong", c.ruleWrong, c.ruleAttempt, rule.Name)
+	case c.stat.LastAnsweredAt == nil:
+		return "You have not answered this question yet"
+	default:
+		days := int(now.Sub(*c.stat.LastAnsweredAt).Hours() / 24)
+		if days == 0 {
+			return "You answered this question today"
+		}
+		return fmt.Sprintf("You last answered this question %d days ago", days)
+	}
+}
@@ -0,0 +1,134 @@
+package trainer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRuleWeight(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		wrongs   int
+		want     float64
+	}{
+		{"never answered", 0, 0, 0.5},
+		{"always wrong", 2, 2, 0.75},
+		{"always right", 8, 0, 0.1},
+		{"half wrong", 10, 5, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleWeight(tt.attempts, tt.wrongs); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ruleWeight(%d, %d) = %f, want %f", tt.attempts, tt.wrongs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuestionWeight(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	correct, wrong := true, false
+	tests := []struct {
+		name string
+		stat PracticeStatEntity
+		want float64
+	}{
+		{"never answered", PracticeStatEntity{}, 1},
+		{"answered without a grade", PracticeStatEntity{LastAnsweredAt: daysAgo(3)}, 1},
+		{"wrong", PracticeStatEntity{LastAnsweredAt: daysAgo(0), IsLastCorrect: &wrong}, wrongQuestionWeight},
+		{"correct a moment ago", PracticeStatEntity{LastAnsweredAt: daysAgo(0), IsLastCorrect: &correct}, minQuestionWeight},
+		{"correct half of recencyDays ago", PracticeStatEntity{LastAnsweredAt: daysAgo(15), IsLastCorrect: &correct}, 0.5},
+		{"correct long ago", PracticeStatEntity{LastAnsweredAt: daysAgo(90), IsLastCorrect: &correct}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := questionWeight(tt.stat, now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("questionWeight() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChooseAdaptive(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	wrong := false
+	// question 1 weighs 0.5 and question 2, answered wrongly on a rule always got wrong, weighs 4/3
+	stats := []PracticeStatEntity{
+		{QuestionID: 1, RuleID: "1"},
+		{QuestionID: 2, RuleID: "2", AttemptCount: 1, WrongCount: 1, LastAnsweredAt: &now, IsLastCorrect: &wrong},
+	}
+	tests := []struct {
+		name       string
+		stats      []PracticeStatEntity
+		excludeIDs []int
+		random     float64
+		wantID     int
+		wantOK     bool
+	}{
+		{"lowest draw", stats, nil, 0, 1, true},
+		{"draw past the first weight", stats, nil, 0.5, 2, true},
+		{"highest draw", stats, nil, 0.999, 2, true},
+		{"excluded question left out", stats, []int{2}, 0.999, 1, true},
+		{"every question excluded", stats, []int{1, 2}, 0, 1, true},
+		{"no question", nil, nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate, ok := chooseAdaptive(tt.stats, tt.excludeIDs, now, func() float64 { return tt.random })
+			if ok != tt.wantOK || candidate.stat.QuestionID != tt.wantID {
+				t.Errorf("chooseAdaptive() = question %d, %t, want %d, %t", candidate.stat.QuestionID, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPracticeCandidateReason(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	today, weekAgo := now.Add(-time.Hour), now.AddDate(0, 0, -7)
+	correct, wrong := true, false
+	rule := Rule{ID: "8", Name: "Rule 8"}
+	tests := []struct {
+		name      string
+		candidate practiceCandidate
+		want      string
+	}{
+		{
+			"wrong last time",
+			practiceCandidate{stat: PracticeStatEntity{LastAnsweredAt: &today, IsLastCorrect: &wrong}, ruleAttempt: 4, ruleWrong: 1},
+			"You got this question wrong the last time you answered it",
+		},
+		{
+			"wrong on the rule",
+			practiceCandidate{stat: PracticeStatEntity{}, ruleAttempt: 4, ruleWrong: 1},
+			"You got 1 of 4 Rule 8 questions wrong",
+		},
+		{
+			"new question",
+			practiceCandidate{stat: PracticeStatEntity{}},
+			"You have not answered this question yet",
+		},
+		{
+			"answered today",
+			practiceCandidate{stat: PracticeStatEntity{LastAnsweredAt: &today, IsLastCorrect: &correct}, ruleAttempt: 1},
+			"You answered this question today",
+		},
+		{
+			"answered days ago",
+			practiceCandidate{stat: PracticeStatEntity{LastAnsweredAt: &weekAgo, IsLastCorrect: &correct}, ruleAttempt: 1},
+			"You last answered this question 7 days ago",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.candidate.reason(rule, now); got != tt.want {
+				t.Errorf("reason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package trainer
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIController serves the versioned JSON api under /api/v1
+type APIController struct {
+	service     Service
+	openAPISpec []byte
+}
+
+func NewAPIController(service Service, openAPISpec []byte) *APIController {
+	return &APIController{
+		service:     service,
+		openAPISpec: openAPISpec,
+	}
+}
+
+// Register adds the routes of the api to the mux
+func (c *APIController) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/openapi.json", c.OpenAPI)
+	mux.HandleFunc("GET /api/v1/questions", c.ListQuestions)
+	mux.HandleFunc("GET /api/v1/questions/random", c.RandomQuestion)
+	mux.HandleFunc("GET /api/v1/questions/{id}", c.GetQuestion)
+	mux.HandleFunc("POST /api/v1/questions/{id}/check", c.CheckAnswer)
+	mux.HandleFunc("GET /api/v1/rules", c.ListRules)
+	mux.HandleFunc("GET /api/", c.NotFound)
+}
+
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type RuleResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SortOrder int    `json:"sortOrder"`
+}
+
+type ChoiceResponse struct {
+	Option string `json:"option"`
+	Text   string `json:"text"`
+}
+
+// QuestionResponse is a question without its answers, which are only revealed by checking an answer
+type QuestionResponse struct {
+	ID                 int              `json:"id"`
+	RuleQuestionNumber string           `json:"ruleQuestionNumber"`
+	QuestionNumber     int              `json:"questionNumber"`
+	Rule               RuleResponse     `json:"rule"`
+	Text               string           `json:"text"`
+	Choices            []ChoiceResponse `json:"choices"`
+}
+
+type QuestionListResponse struct {
+	Questions  []QuestionResponse `json:"questions"`
+	Search     string             `json:"search,omitempty"`
+	Suggestion string             `json:"suggestion,omitempty"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+type RuleListResponse struct {
+	Rules []RuleResponse `json:"rules"`
+}
+
+type CheckAnswerRequest struct {
+	Selected []string `json:"selected"`
+}
+
+type ChoiceResultResponse struct {
+	Option   string  `json:"option"`
+	Text     string  `json:"text"`
+	IsAnswer bool    `json:"isAnswer"`
+	Selected bool    `json:"selected"`
+	Verdict  Verdict `json:"verdict"`
+}
+
+type CheckAnswerResponse struct {
+	QuestionID     int                    `json:"questionId"`
+	IsCorrect      bool                   `json:"isCorrect"`
+	CorrectOptions []string               `json:"correctOptions"`
+	Choices        []ChoiceResultResponse `json:"choices"`
+}
+
+// ListQuestions handles GET /api/v1/questions?search=&rules=&cursor=&limit=
+func (c *APIController) ListQuestions(w http.ResponseWriter, r *http.Request) {
+	rules, err := getQueryStrings(r, "rules")
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid query string")
+		return
+	}
+	limit := defaultListLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			writeAPIError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and "+strconv.Itoa(maxListLimit))
+			return
+		}
+	}
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+	result, err := c.service.ListQuestions(r.Context(), rules, search, r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_cursor", "cursor is not valid")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	questions := make([]QuestionResponse, 0, len(result.Questions))
+	for _, question := range result.Questions {
+		questions = append(questions, toQuestionResponse(question))
+	}
+	writeJSON(w, http.StatusOK, QuestionListResponse{
+		Questions:  questions,
+		Search:     result.Search,
+		Suggestion: result.Suggestion,
+		NextCursor: result.NextCursor,
+	})
+}
+
+// GetQuestion handles GET /api/v1/questions/{id}
+func (c *APIController) GetQuestion(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	question, err := c.service.GetQuestionByID(r.Context(), id)
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toQuestionResponse(*question))
+}
+
+// RandomQuestion handles GET /api/v1/questions/random?rules=
+func (c *APIController) RandomQuestion(w http.ResponseWriter, r *http.Request) {
+	rules, err := getQueryStrings(r, "rules")
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request", "Invalid query string")
+		return
+	}
+	question, err := c.service.GetRandomQuestion(r.Context(), rules, nil)
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toQuestionResponse(*question))
+}
+
+// ListRules handles GET /api/v1/rules
+func (c *APIController) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := c.service.GetAllRules(r.Context())
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	response := RuleListResponse{Rules: make([]RuleResponse, 0, len(rules))}
+	for _, rule := range rules {
+		response.Rules = append(response.Rules, toRuleResponse(rule))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// CheckAnswer handles POST /api/v1/questions/{id}/check
+func (c *APIController) CheckAnswer(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var request CheckAnswerRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_body", "Request body must be a JSON object with a selected array")
+		return
+	}
+	result, err := c.service.CheckAnswer(r.Context(), id, request.Selected)
+	if errors.Is(err, ErrInvalidOption) {
+		writeAPIError(w, http.StatusBadRequest, "invalid_option", "Selected options must be options of the question")
+		return
+	}
+	if err != nil {
+		writeQuestionError(w, err)
+		return
+	}
+
+	response := CheckAnswerResponse{
+		QuestionID:     result.QuestionID,
+		IsCorrect:      result.IsCorrect,
+		CorrectOptions: result.CorrectOptions(),
+		Choices:        make([]ChoiceResultResponse, 0, len(result.Choices)),
+	}
+	for _, choice := range result.Choices {
+		response.Choices = append(response.Choices, ChoiceResultResponse{
+			Option:   choice.Choice.Option,
+			Text:     choice.Choice.Text,
+			IsAnswer: choice.Choice.IsAnswer,
+			Selected: choice.Selected,
+			Verdict:  choice.Verdict,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// OpenAPI handles GET /api/openapi.json
+func (c *APIController) OpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(c.openAPISpec)
+	if err != nil {
+		log.Printf("Error writing openapi spec: %s", err)
+	}
+}
+
+// NotFound handles every other path under /api/
+func (c *APIController) NotFound(w http.ResponseWriter, _ *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "not_found", "Resource not found")
+}
+
+func toRuleResponse(rule Rule) RuleResponse {
+	return RuleResponse{
+		ID:        rule.ID,
+		Name:      rule.Name,
+		SortOrder: rule.SortOrder,
+	}
+}
+
+func toQuestionResponse(question Question) QuestionResponse {
+	choices := make([]ChoiceResponse, 0, len(question.Choices))
+	for _, choice := range question.Choices {
+		choices = append(choices, ChoiceResponse{
+			Option: choice.Option,
+			Text:   choice.Text,
+		})
+	}
+	return QuestionResponse{
+		ID:                 question.ID,
+		RuleQuestionNumber: question.RuleQuestionNumber,
+		QuestionNumber:     question.QuestionNumber,
+		Rule:               toRuleResponse(question.Rule),
+		Text:               question.Text,
+		Choices:            choices,
+	}
+}
+
+// pathID parses the {id} path value, writing a bad request response if it is not a positive integer
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid_id", "id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func writeQuestionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrQuestionNotFound) {
+		writeAPIError(w, http.StatusNotFound, "question_not_found", "Question not found")
+		return
+	}
+	writeInternalError(w, err)
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	log.Printf("Error handling api request: %s", err)
+	writeAPIError(w, http.StatusInternalServerError, "internal_error", "Something went wrong")
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, APIErrorResponse{Error: APIError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Printf("Error encoding json: %s", err)
+	}
+}
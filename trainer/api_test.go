@@ -0,0 +1,297 @@
+package trainer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/public"
+)
+
+// fakeAPIService serves the questions and rules it holds, or fails every call with err
+type fakeAPIService struct {
+	Service
+	questions []Question
+	rules     []Rule
+	err       error
+}
+
+func (s *fakeAPIService) GetQuestionByID(_ context.Context, id int) (*Question, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, question := range s.questions {
+		if question.ID == id {
+			return &question, nil
+		}
+	}
+	return nil, ErrQuestionNotFound
+}
+
+func (s *fakeAPIService) GetRandomQuestion(_ context.Context, rules []string, _ []int) (*Question, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	for _, question := range s.questions {
+		if len(rules) == 0 || slices.Contains(rules, question.Rule.ID) {
+			return &question, nil
+		}
+	}
+	return nil, ErrQuestionNotFound
+}
+
+func (s *fakeAPIService) GetAllRules(_ context.Context) ([]Rule, error) {
+	return s.rules, s.err
+}
+
+func (s *fakeAPIService) ListQuestions(_ context.Context, _ []string, search string, cursor string, limit int) (*QuestionSearchResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	offset := 0
+	if cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	result := &QuestionSearchResult{Search: search, Suggestion: search}
+	end := min(offset+limit, len(s.questions))
+	result.Questions = s.questions[offset:end]
+	if end < len(s.questions) {
+		result.NextCursor = strconv.Itoa(end)
+	}
+	return result, nil
+}
+
+func (s *fakeAPIService) CheckAnswer(ctx context.Context, questionID int, selected []string) (*AnswerResult, error) {
+	question, err := s.GetQuestionByID(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	return GradeAnswer(question.ID, question.Choices, selected)
+}
+
+func newFakeAPIService() *fakeAPIService {
+	rule := Rule{ID: "8", Name: "Rule 8", SortOrder: 8}
+	return &fakeAPIService{
+		rules: []Rule{rule},
+		questions: []Question{
+			{ID: 1, Text: "First", Rule: rule, QuestionNumber: 1, RuleQuestionNumber: "8.1", Choices: []Choice{
+				{Option: "a", Text: "Free-throw", IsAnswer: true},
+				{Option: "b", Text: "7-metre throw"},
+			}},
+			{ID: 2, Text: "Second", Rule: rule, QuestionNumber: 2, RuleQuestionNumber: "8.2", Choices: []Choice{
+				{Option: "a", Text: "Warning"},
+				{Option: "b", Text: "Disqualification", IsAnswer: true},
+			}},
+		},
+	}
+}
+
+// TestAPIMatchesOpenAPI runs every documented operation and response of the OpenAPI document
+// against the api and checks the responses against the document
+func TestAPIMatchesOpenAPI(t *testing.T) {
+	var spec openAPIDocument
+	err := json.Unmarshal(public.OpenAPI(), &spec)
+	if err != nil {
+		t.Fatalf("parsing openapi.json: %s", err)
+	}
+
+	tests := []struct {
+		path   string
+		method string
+		target string
+		body   string
+		fail   bool
+		status int
+	}{
+		{"/questions", "get", "/questions?search=throw&rules=8&limit=1", "", false, http.StatusOK},
+		{"/questions", "get", "/questions?limit=0", "", false, http.StatusBadRequest},
+		{"/questions", "get", "/questions?cursor=nope", "", false, http.StatusBadRequest},
+		{"/questions", "get", "/questions", "", true, http.StatusInternalServerError},
+		{"/questions/random", "get", "/questions/random?rules=8", "", false, http.StatusOK},
+		{"/questions/random", "get", "/questions/random?rules=%zz", "", false, http.StatusBadRequest},
+		{"/questions/random", "get", "/questions/random?rules=1", "", false, http.StatusNotFound},
+		{"/questions/random", "get", "/questions/random", "", true, http.StatusInternalServerError},
+		{"/questions/{id}", "get", "/questions/2", "", false, http.StatusOK},
+		{"/questions/{id}", "get", "/questions/abc", "", false, http.StatusBadRequest},
+		{"/questions/{id}", "get", "/questions/99", "", false, http.StatusNotFound},
+		{"/questions/{id}", "get", "/questions/1", "", true, http.StatusInternalServerError},
+		{"/questions/{id}/check", "post", "/questions/1/check", `{"selected":["a"]}`, false, http.StatusOK},
+		{"/questions/{id}/check", "post", "/questions/1/check", `{"selected":["z"]}`, false, http.StatusBadRequest},
+		{"/questions/{id}/check", "post", "/questions/1/check", `not json`, false, http.StatusBadRequest},
+		{"/questions/{id}/check", "post", "/questions/99/check", `{"selected":["a"]}`, false, http.StatusNotFound},
+		{"/questions/{id}/check", "post", "/questions/1/check", `{"selected":["a"]}`, true, http.StatusInternalServerError},
+		{"/rules", "get", "/rules", "", false, http.StatusOK},
+		{"/rules", "get", "/rules", "", true, http.StatusInternalServerError},
+	}
+
+	tested := map[string]bool{}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s %s %d", strings.ToUpper(tt.method), tt.target, tt.status)
+		t.Run(name, func(t *testing.T) {
+			operation, ok := spec.Paths[tt.path][tt.method]
+			if !ok {
+				t.Fatalf("%s %s is not documented", tt.method, tt.path)
+			}
+			response, ok := operation.Responses[strconv.Itoa(tt.status)]
+			if !ok {
+				t.Fatalf("status %d of %s %s is not documented", tt.status, tt.method, tt.path)
+			}
+			tested[fmt.Sprintf("%s %s %d", tt.method, tt.path, tt.status)] = true
+
+			service := newFakeAPIService()
+			if tt.fail {
+				service.err = errors.New("database is down")
+			}
+			mux := http.NewServeMux()
+			NewAPIController(service, public.OpenAPI()).Register(mux)
+			req := httptest.NewRequest(strings.ToUpper(tt.method), spec.Servers[0].URL+tt.target, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+			var body any
+			err := json.Unmarshal(rec.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatalf("response is not json: %s", err)
+			}
+			schema := spec.response(response).Content["application/json"].Schema
+			for _, problem := range spec.validate(schema, body, "$") {
+				t.Error(problem)
+			}
+		})
+	}
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			for status := range operation.Responses {
+				if !tested[fmt.Sprintf("%s %s %s", method, path, status)] {
+					t.Errorf("status %s of %s %s is not tested", status, method, path)
+				}
+			}
+		}
+	}
+}
+
+func TestAPIServesOpenAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	NewAPIController(newFakeAPIService(), public.OpenAPI()).Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != string(public.OpenAPI()) {
+		t.Errorf("GET /api/openapi.json = %d, want the document", rec.Code)
+	}
+}
+
+// openAPIDocument is the part of an OpenAPI 3 document needed to check the responses of the api
+type openAPIDocument struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Responses map[string]openAPIResponse `json:"responses"`
+		Schemas   map[string]*openAPISchema  `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *openAPISchema `json:"schema"`
+	} `json:"content"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Required   []string                  `json:"required"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	Items      *openAPISchema            `json:"items"`
+	Enum       []string                  `json:"enum"`
+}
+
+func (d *openAPIDocument) response(response openAPIResponse) openAPIResponse {
+	if response.Ref != "" {
+		return d.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+	}
+	return response
+}
+
+// validate returns where the value does not match the schema, including properties that are not documented
+func (d *openAPIDocument) validate(schema *openAPISchema, value any, at string) []string {
+	if schema == nil {
+		return []string{at + ": no schema"}
+	}
+	if schema.Ref != "" {
+		return d.validate(d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")], value, at)
+	}
+	var problems []string
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an object", at, value)}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %s", at, name))
+			}
+		}
+		for name, property := range object {
+			propertySchema, ok := schema.Properties[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is not documented", at, name))
+				continue
+			}
+			problems = append(problems, d.validate(propertySchema, property, at+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an array", at, value)}
+		}
+		for i, item := range array {
+			problems = append(problems, d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not a string", at, value)}
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not one of %q", at, s, schema.Enum))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: %v is not an integer", at, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %v is not a boolean", at, value)}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("%s: unsupported schema type %q", at, schema.Type))
+	}
+	return problems
+}
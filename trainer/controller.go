@@ -2,59 +2,149 @@ package trainer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aattwwss/ihf-referee-rules/account"
+	"github.com/aattwwss/ihf-referee-rules/internal"
 	"html/template"
 	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Service interface {
 	GetAllQuestions(ctx context.Context) ([]Question, error)
+	GetQuestionsByRules(ctx context.Context, rules []string) ([]Question, error)
 	GetQuestionByID(ctx context.Context, id int) (*Question, error)
-	GetRandomQuestion(ctx context.Context, rules []string) (*Question, error)
+	GetRandomQuestion(ctx context.Context, rules []string, excludeIDs []int) (*Question, error)
 	GetChoicesByQuestionID(ctx context.Context, questionID int) ([]Choice, error)
-	ListQuestions(ctx context.Context, rules []string, search string, lastRuleSortOrder int, lastQuestionNumber int, limit int) ([]Question, error)
+	GetAllRules(ctx context.Context) ([]Rule, error)
+	ListQuestions(ctx context.Context, rules []string, search string, cursor string, limit int) (*QuestionSearchResult, error)
 	SubmitFeedback(ctx context.Context, feedback Feedback) error
+	ReportQuestion(ctx context.Context, questionID int, report Feedback) error
+	ListFeedback(ctx context.Context, filter FeedbackFilter) ([]Feedback, error)
+	SetFeedbackStatus(ctx context.Context, userID int, feedbackID int, status FeedbackStatus) error
+	CheckAnswer(ctx context.Context, questionID int, selected []string) (*AnswerResult, error)
+	RecordAnswer(ctx context.Context, userID int, questionID int, selected []string, source AnswerSource) (*Answer, error)
+	GetProgress(ctx context.Context, userID int) (*Progress, error)
+	SetQuestionRead(ctx context.Context, userID int, questionID int, read bool) error
+	ClearSelections(ctx context.Context, userID int) error
+	ImportLocalProgress(ctx context.Context, userID int, local LocalProgress) error
+	GradeExam(ctx context.Context, userID int, ticketID string, questionIDs []int, selections map[int][]string) (*ExamResult, error)
+	GetNextReview(ctx context.Context, userID int) (*ReviewQuestion, error)
+	GetAdaptiveQuestion(ctx context.Context, userID int, rules []string, excludeIDs []int) (*PracticeQuestion, error)
+	GetDashboard(ctx context.Context, userID int) (*Dashboard, error)
+	ListQuestionSets(ctx context.Context, userID int) ([]QuestionSet, error)
+	CreateQuestionSet(ctx context.Context, userID int, name string, description string) (string, error)
+	GetQuestionSet(ctx context.Context, shareCode string) (*QuestionSet, []Question, error)
+	UpdateQuestionSet(ctx context.Context, userID int, shareCode string, name string, description string) error
+	AddToQuestionSet(ctx context.Context, userID int, shareCode string, questionIDs []int) error
+	RemoveFromQuestionSet(ctx context.Context, userID int, shareCode string, questionID int) error
+	MoveInQuestionSet(ctx context.Context, userID int, shareCode string, questionID int, offset int) error
+	DeleteQuestionSet(ctx context.Context, userID int, shareCode string) error
+	GetQuestionEditor(ctx context.Context, questionID int) (*QuestionEditor, error)
+	EditQuestion(ctx context.Context, userID int, questionID int, content QuestionContent, latestRevisionID int) error
+	RevertQuestionRevision(ctx context.Context, userID int, questionID int, revisionID int, latestRevisionID int) error
 }
 
+const (
+	defaultListLimit = 10
+	maxListLimit     = 50
+	// practiceSeenCookie keeps the questions practised in the browser session so that they are not repeated
+	practiceSeenCookie = "practice_seen"
+	maxPracticeSeen    = 200
+	// honeypotField is hidden from people on the feedback forms, only bots fill it in
+	honeypotField = "website"
+	// maxProgressBytes bounds the progress imported from the browser local storage
+	maxProgressBytes = 1 << 20
+)
+
 type Controller struct {
 	service Service
 	html    fs.FS
+	csrf    *internal.CSRF
+	// feedbackLimiter limits the feedback and reports submitted from an IP
+	feedbackLimiter *internal.RateLimiter
+	trustProxy      bool
+	// signer signs the questions handed out for an exam
+	signer *internal.Signer
 }
 
-func NewController(service Service, html fs.FS) *Controller {
+func NewController(service Service, html fs.FS, csrf *internal.CSRF, feedbackLimiter *internal.RateLimiter, trustProxy bool, signer *internal.Signer) *Controller {
 	return &Controller{
-		service: service,
-		html:    html,
+		service:         service,
+		html:            html,
+		csrf:            csrf,
+		feedbackLimiter: feedbackLimiter,
+		trustProxy:      trustProxy,
+		signer:          signer,
 	}
 }
 
+type HomePageData struct {
+	Questions []QuestionDataV2
+	// Set is the question set the page is scoped to, nil for all questions
+	Set *QuestionSet
+	// IsLoggedIn saves the progress on the server instead of the browser
+	IsLoggedIn bool
+	// ImportProgress asks the browser to send the progress it saved before the user logged in
+	ImportProgress bool
+}
+
 type QuestionDataV2 struct {
 	ID                 int
+	QuestionID         int
 	CorrectChoices     string
 	RuleQuestionNumber string
 	Text               string
 	Choices            []ChoiceDateV2
 	QuestionNumber     int
 	RuleName           string
+	IsRead             bool
+	AnswerCorrectedAt  *time.Time
 }
 
 type ChoiceDateV2 struct {
-	ID     int
-	Option string
-	Text   string
+	ID         int
+	Option     string
+	Text       string
+	IsSelected bool
 }
 
+// Home renders every question with its answers, or only the questions of the set in the query
 func (c *Controller) Home(w http.ResponseWriter, r *http.Request) {
+	set, allQuestions, err := c.scopedQuestions(r)
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		c.renderSetNotFound(w)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting questions: %s", err)
+	}
 	tmpl, err := template.ParseFS(c.html, "base.tmpl", "home.tmpl")
 	if err != nil {
 		log.Printf("Error parsing template: %s", err)
 	}
-	allQuestions, err := c.service.GetAllQuestions(r.Context())
-	var QuestionDataList []QuestionDataV2
+	progress := &Progress{}
+	user := account.UserFromContext(r.Context())
+	if user != nil {
+		progress, err = c.service.GetProgress(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("Error getting progress: %s", err)
+			progress = &Progress{IsImported: true}
+		}
+	}
+	data := HomePageData{
+		Set:            set,
+		IsLoggedIn:     user != nil,
+		ImportProgress: user != nil && !progress.IsImported,
+	}
 	for i, question := range allQuestions {
 		var choices []ChoiceDateV2
 		var correctChoices []string
@@ -63,238 +153,1560 @@ func (c *Controller) Home(w http.ResponseWriter, r *http.Request) {
 				correctChoices = append(correctChoices, choice.Option)
 			}
 			choices = append(choices, ChoiceDateV2{
-				ID:     choice.ID,
-				Option: choice.Option,
-				Text:   choice.Text,
+				ID:         choice.ID,
+				Option:     choice.Option,
+				Text:       choice.Text,
+				IsSelected: slices.Contains(progress.Selections[question.ID], choice.Option),
 			})
 		}
-		QuestionDataList = append(QuestionDataList, QuestionDataV2{
+		data.Questions = append(data.Questions, QuestionDataV2{
 			ID:                 i + 1,
+			QuestionID:         question.ID,
 			CorrectChoices:     strings.Join(correctChoices, ","),
 			RuleQuestionNumber: question.RuleQuestionNumber,
 			Text:               question.Text,
 			Choices:            choices,
 			QuestionNumber:     question.QuestionNumber,
 			RuleName:           question.Rule.Name,
+			IsRead:             progress.Read[question.ID],
+			AnswerCorrectedAt:  question.AnswerCorrectedAt,
 		})
 	}
-	err = tmpl.Execute(w, QuestionDataList)
+	err = tmpl.Execute(w, data)
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) Feedback(w http.ResponseWriter, _ *http.Request) {
-	tmpl, err := template.ParseFS(c.html, "base.tmpl", "feedback/feedback.tmpl")
+// SaveAnswer records the options currently selected for a question on the home page
+func (c *Controller) SaveAnswer(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		log.Printf("Error parsing template: %s", err)
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
 	}
-	err = tmpl.Execute(w, nil)
+	err = r.ParseForm()
 	if err != nil {
-		log.Printf("Error executing template: %s", err)
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	_, err = c.service.RecordAnswer(r.Context(), user.ID, questionID, r.Form["choices"], AnswerSourceHome)
+	if errors.Is(err, ErrInvalidOption) {
+		http.Error(w, "Invalid option", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
 	}
+	if err != nil {
+		log.Printf("Error recording answer: %s", err)
+		http.Error(w, "Error saving answer", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (c *Controller) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFS(c.html, "feedback/submitFeedback.tmpl")
+// SaveRead marks a question as read or unread
+func (c *Controller) SaveRead(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		log.Printf("Error parsing template: %s", err)
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
 	}
 	err = r.ParseForm()
 	if err != nil {
-		log.Printf("Error parsing form: %s", err)
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
 	}
-	err = c.service.SubmitFeedback(r.Context(), Feedback{
-		Name:  r.Form.Get("name"),
-		Email: r.Form.Get("email"),
-		Topic: r.Form.Get("topic"),
-		Text:  r.Form.Get("feedback"),
-	})
+	err = c.service.SetQuestionRead(r.Context(), user.ID, questionID, r.Form.Get("read") == "true")
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving read mark: %s", err)
+		http.Error(w, "Error saving read mark", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClearProgress clears the selected options of every question
+func (c *Controller) ClearProgress(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := c.service.ClearSelections(r.Context(), user.ID)
 	if err != nil {
-		log.Printf("Error submitting feedback: %s", err)
+		log.Printf("Error clearing selections: %s", err)
+		http.Error(w, "Error clearing selections", http.StatusInternalServerError)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	err = tmpl.Execute(w, nil)
+// ImportProgress imports the progress saved in the browser local storage, once per user
+func (c *Controller) ImportProgress(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	var local LocalProgress
+	r.Body = http.MaxBytesReader(w, r.Body, maxProgressBytes)
+	err := json.NewDecoder(r.Body).Decode(&local)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, "Progress is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
-		log.Printf("Error executing template: %s", err)
+		http.Error(w, "Invalid progress", http.StatusBadRequest)
+		return
+	}
+	err = c.service.ImportLocalProgress(r.Context(), user.ID, local)
+	if err != nil {
+		log.Printf("Error importing progress: %s", err)
+		http.Error(w, "Error importing progress", http.StatusInternalServerError)
+		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
+type RuleOption struct {
+	ID       string
+	Name     string
+	Selected bool
 }
 
-type QuestionListPageData struct {
-	Questions     []QuestionData
-	LoadMoreParam LoadMoreParam
+// ExamPageData renders the questions without any answer, they are only known to the server
+type ExamPageData struct {
+	Rules     []RuleOption
+	Questions []ExamQuestionData
+	// Set is the question set the exam is taken on instead of the rules, nil if none
+	Set *QuestionSet
+	// Ticket is the signed list of the questions, posted back with the answers
+	Ticket string
 }
 
-type QuestionData struct {
-	ID                 int
-	RuleID             string
-	RuleName           string
+type ExamQuestionData struct {
+	Number             int
+	QuestionID         int
 	RuleQuestionNumber string
 	Text               string
+	Choices            []ExamChoiceData
 }
 
-type LoadMoreParam struct {
-	Search             string
-	LastRuleSortOrder  int
-	LastQuestionNumber int
-	LastIndex          int
-	Limit              int
+type ExamChoiceData struct {
+	Option string
+	Text   string
 }
 
-func (c *Controller) QuestionByID(w http.ResponseWriter, r *http.Request) {
-	id := queryParamInt(r, "id", 0)
-	tmpl, err := template.ParseFS(c.html, "base.tmpl", "questionByID.tmpl")
+type ExamResultPageData struct {
+	CorrectCount int
+	Total        int
+	Percentage   int
+	Questions    []ExamQuestionResultData
+}
+
+type ExamQuestionResultData struct {
+	Number             int
+	RuleQuestionNumber string
+	Text               string
+	IsCorrect          bool
+	Choices            []ChoiceVerdict
+}
+
+// Exam renders the questions of the selected rules, or of the set in the query, without their answers,
+// to be graded by the server on submission
+func (c *Controller) Exam(w http.ResponseWriter, r *http.Request) {
+	selectedRules, err := getQueryStrings(r, "rules")
+	if err != nil {
+		http.Error(w, "Invalid query string", http.StatusBadRequest)
+		return
+	}
+	var set *QuestionSet
+	var questions []Question
+	if r.URL.Query().Has("set") {
+		set, questions, err = c.scopedQuestions(r)
+	} else {
+		questions, err = c.service.GetQuestionsByRules(r.Context(), selectedRules)
+	}
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		c.renderSetNotFound(w)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting questions: %s", err)
+	}
+	rules, err := c.service.GetAllRules(r.Context())
+	if err != nil {
+		log.Printf("Error getting rules: %s", err)
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "exam/exam.tmpl")
 	if err != nil {
 		log.Printf("Error parsing template: %s", err)
 	}
-	err = tmpl.Execute(w, id)
+
+	data := ExamPageData{Rules: toRuleOptions(rules, selectedRules), Set: set}
+	questionIDs := make([]int, 0, len(questions))
+	for i, question := range questions {
+		questionIDs = append(questionIDs, question.ID)
+		var choices []ExamChoiceData
+		for _, choice := range question.Choices {
+			choices = append(choices, ExamChoiceData{
+				Option: choice.Option,
+				Text:   choice.Text,
+			})
+		}
+		data.Questions = append(data.Questions, ExamQuestionData{
+			Number:             i + 1,
+			QuestionID:         question.ID,
+			RuleQuestionNumber: question.RuleQuestionNumber,
+			Text:               question.Text,
+			Choices:            choices,
+		})
+	}
+	data.Ticket, err = issueExamTicket(c.signer, questionIDs, time.Now())
+	if err != nil {
+		log.Printf("Error issuing exam ticket: %s", err)
+	}
+	err = tmpl.Execute(w, data)
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) QuestionList(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFS(c.html, "questionList.tmpl")
+// SubmitExam grades the submitted exam and reveals the answers
+func (c *Controller) SubmitExam(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
-		log.Printf("Error parsing template: %s", err)
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
 	}
-	search := strings.TrimSpace(queryParamString(r, "search", ""))
-	lastRuleSortOrder := queryParamInt(r, "lastRuleSortOrder", 0)
-	lastQuestionNumber := queryParamInt(r, "lastQuestionNumber", 0)
-	limit := 10
-	questions, err := c.service.ListQuestions(r.Context(), nil, search, lastRuleSortOrder, lastQuestionNumber, limit)
+	ticket, err := verifyExamTicket(c.signer, r.Form.Get("ticket"), time.Now())
 	if err != nil {
-		log.Printf("Error getting questions: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selections := make(map[int][]string)
+	for _, questionID := range ticket.QuestionIDs {
+		selections[questionID] = r.Form["q"+strconv.Itoa(questionID)]
+	}
+	userID := 0
+	if user := account.UserFromContext(r.Context()); user != nil {
+		userID = user.ID
+	}
+	result, err := c.service.GradeExam(r.Context(), userID, ticket.ID, ticket.QuestionIDs, selections)
+	if errors.Is(err, ErrInvalidExam) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) || errors.Is(err, ErrInvalidOption) || errors.Is(err, ErrDuplicateExamQuestion) {
+		http.Error(w, "Invalid exam submission", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error grading exam: %s", err)
+		http.Error(w, "Error grading exam", http.StatusInternalServerError)
+		return
 	}
 
-	if len(questions) > 0 {
-		lastRuleSortOrder = questions[len(questions)-1].Rule.SortOrder
-		lastQuestionNumber = questions[len(questions)-1].QuestionNumber
+	data := ExamResultPageData{
+		CorrectCount: result.CorrectCount,
+		Total:        len(result.Questions),
 	}
-	var questionsData []QuestionData
-	for _, question := range questions {
-		questionsData = append(questionsData, QuestionData{
-			ID:                 question.ID,
-			RuleID:             question.Rule.ID,
-			RuleName:           question.Rule.Name,
-			RuleQuestionNumber: question.RuleQuestionNumber,
-			Text:               question.Text,
+	if data.Total > 0 {
+		data.Percentage = data.CorrectCount * 100 / data.Total
+	}
+	for i, question := range result.Questions {
+		data.Questions = append(data.Questions, ExamQuestionResultData{
+			Number:             i + 1,
+			RuleQuestionNumber: question.Question.RuleQuestionNumber,
+			Text:               question.Question.Text,
+			IsCorrect:          question.Result.IsCorrect,
+			Choices:            question.Result.Choices,
 		})
 	}
-	data := QuestionListPageData{
-		Questions: questionsData,
-		LoadMoreParam: LoadMoreParam{
-			LastRuleSortOrder:  lastRuleSortOrder,
-			LastQuestionNumber: lastQuestionNumber,
-			Limit:              10,
-		},
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "exam/examResult.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
 	}
-
 	err = tmpl.Execute(w, data)
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) RandomQuestion(w http.ResponseWriter, _ *http.Request) {
-	tmpl, err := template.ParseFS(c.html, "base.tmpl", "questionByID.tmpl")
+func toRuleProgressData(progress RuleProgress, name string) RuleProgressData {
+	data := RuleProgressData{
+		Name:           name,
+		QuestionCount:  progress.QuestionCount,
+		ReadCount:      progress.ReadCount,
+		AttemptedCount: progress.AttemptedCount,
+		CorrectCount:   progress.CorrectCount,
+	}
+	if progress.AttemptedCount > 0 {
+		data.Accuracy = progress.CorrectCount * 100 / progress.AttemptedCount
+	}
+	for _, week := range progress.Trend {
+		data.Trend = append(data.Trend, WeeklyAccuracyData{
+			Week:        week.WeekStart.Format("2 Jan"),
+			AnswerCount: week.AnswerCount,
+			Accuracy:    week.Accuracy(),
+		})
+	}
+	return data
+}
+
+func toRuleOptions(rules []Rule, selected []string) []RuleOption {
+	options := make([]RuleOption, 0, len(rules))
+	for _, rule := range rules {
+		options = append(options, RuleOption{
+			ID:       rule.ID,
+			Name:     rule.Name,
+			Selected: slices.Contains(selected, rule.ID),
+		})
+	}
+	return options
+}
+
+// FeedbackFormData is the feedback form, rendered again with the errors of its fields when it is invalid
+type FeedbackFormData struct {
+	Name      string
+	Email     string
+	Topic     string
+	Text      string
+	CSRFToken string
+	Errors    FeedbackErrors
+	// Error is shown above the form when it could not be submitted at all
+	Error string
+}
+
+func (c *Controller) Feedback(w http.ResponseWriter, r *http.Request) {
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error loading feedback form", http.StatusInternalServerError)
+		return
+	}
+	data := FeedbackFormData{CSRFToken: token}
+	if user := account.UserFromContext(r.Context()); user != nil {
+		data.Name, data.Email = user.Name, user.Email
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "feedback/feedback.tmpl")
 	if err != nil {
 		log.Printf("Error parsing template: %s", err)
 	}
-	err = tmpl.Execute(w, nil)
+	err = tmpl.Execute(w, data)
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) NewQuestion(w http.ResponseWriter, r *http.Request) {
-	id := queryParamInt(r, "id", 0)
-	var question *Question
-	var err error
-	if id == 0 {
-		rules, err := getQueryStrings(r, "rules")
-		if err != nil {
-			log.Printf("Error getting query strings: %s", err)
-		}
-		question, err = c.service.GetRandomQuestion(r.Context(), rules)
+// SubmitFeedback saves the feedback and renders the confirmation, or the form again with what went wrong
+func (c *Controller) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	data := FeedbackFormData{
+		Name:      r.Form.Get("name"),
+		Email:     r.Form.Get("email"),
+		Topic:     r.Form.Get("topic"),
+		Text:      r.Form.Get("feedback"),
+		CSRFToken: r.Form.Get(internal.CSRFFieldName),
+	}
+	err = c.screenFeedback(r, func() error {
+		return c.service.SubmitFeedback(r.Context(), Feedback{
+			Name:  data.Name,
+			Email: data.Email,
+			Topic: data.Topic,
+			Text:  data.Text,
+		})
+	})
+	status, message := feedbackFailure(err)
+	if status != http.StatusOK {
+		errors.As(err, &data.Errors)
+		data.Error = message
+		w.WriteHeader(status)
+		tmpl, err := template.ParseFS(c.html, "feedback/feedback.tmpl")
 		if err != nil {
-			log.Printf("Error getting random question: %s", err)
+			log.Printf("Error parsing template: %s", err)
 		}
-	} else {
-		question, err = c.service.GetQuestionByID(r.Context(), id)
+		err = tmpl.ExecuteTemplate(w, "feedback-form", data)
 		if err != nil {
-			log.Printf("Error getting question: %s", err)
+			log.Printf("Error executing template: %s", err)
 		}
+		return
 	}
-	tmpl, err := template.ParseFS(c.html, "question.tmpl")
+
+	tmpl, err := template.ParseFS(c.html, "feedback/submitFeedback.tmpl")
 	if err != nil {
 		log.Printf("Error parsing template: %s", err)
 	}
-	err = tmpl.Execute(w, question)
+	err = tmpl.Execute(w, nil)
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) Result(w http.ResponseWriter, r *http.Request) {
-	questionID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/submit/"))
+// ReportPageData is the form to report a problem with a question
+type ReportPageData struct {
+	Question    *Question
+	Categories  []ReportCategory
+	Category    ReportCategory
+	Name        string
+	Email       string
+	Text        string
+	CSRFToken   string
+	Errors      FeedbackErrors
+	Error       string
+	IsSubmitted bool
+}
+
+// ReportQuestionPage renders the form to report a problem with the question, filled in with the logged in user
+func (c *Controller) ReportQuestionPage(w http.ResponseWriter, r *http.Request) {
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error loading report form", http.StatusInternalServerError)
+		return
+	}
+	data := ReportPageData{CSRFToken: token}
+	if user := account.UserFromContext(r.Context()); user != nil {
+		data.Name, data.Email = user.Name, user.Email
+	}
+	c.renderReport(w, r, http.StatusOK, data)
+}
+
+// ReportQuestion saves the report of a problem with the question
+func (c *Controller) ReportQuestion(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		log.Printf("Error parsing question ID: %s", err)
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
 	}
 	err = r.ParseForm()
 	if err != nil {
-		log.Printf("Error parsing form: %s", err)
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
 	}
-	selected := r.Form["choices"]
-	choices, err := c.service.GetChoicesByQuestionID(r.Context(), questionID)
-	for i, choice := range choices {
-		if slices.Contains(selected, choice.Option) {
-			choices[i].IsSelected = true
-		}
+	data := ReportPageData{
+		Category:  ReportCategory(r.Form.Get("category")),
+		Name:      r.Form.Get("name"),
+		Email:     r.Form.Get("email"),
+		Text:      r.Form.Get("text"),
+		CSRFToken: r.Form.Get(internal.CSRFFieldName),
+	}
+	err = c.screenFeedback(r, func() error {
+		return c.service.ReportQuestion(r.Context(), questionID, Feedback{
+			Name:     data.Name,
+			Email:    data.Email,
+			Text:     data.Text,
+			Category: data.Category,
+		})
+	})
+	if errors.Is(err, ErrQuestionNotFound) {
+		c.renderReport(w, r, http.StatusNotFound, data)
+		return
 	}
+	status, message := feedbackFailure(err)
+	errors.As(err, &data.Errors)
+	data.Error = message
+	data.IsSubmitted = status == http.StatusOK
+	c.renderReport(w, r, status, data)
+}
+
+// renderReport renders the report form of the question in the path, or the question not found page
+func (c *Controller) renderReport(w http.ResponseWriter, r *http.Request, status int, data ReportPageData) {
+	questionID, _ := strconv.Atoi(r.PathValue("id"))
+	question, err := c.service.GetQuestionByID(r.Context(), questionID)
+	page := "feedback/report.tmpl"
+	if errors.Is(err, ErrQuestionNotFound) {
+		status = http.StatusNotFound
+		page = "notFound.tmpl"
+	} else if err != nil {
+		log.Printf("Error getting question: %s", err)
+		http.Error(w, "Error getting question", http.StatusInternalServerError)
+		return
+	}
+	data.Question = question
+	data.Categories = ReportCategories
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	w.WriteHeader(status)
+	err = tmpl.Execute(w, data)
 	if err != nil {
-		log.Printf("Error getting choices: %s", err)
+		log.Printf("Error executing template: %s", err)
 	}
+}
 
-	tmpl, err := template.ParseFS(c.html, "result.tmpl")
+// screenFeedback rejects feedback posted without the CSRF token of the browser or too often from the same IP,
+// then submits it. Feedback with the honeypot field filled in, which only bots see, is dropped while the bot is told
+// it was submitted. Only accepted feedback counts towards the limit, so that a form with mistakes can be sent again.
+func (c *Controller) screenFeedback(r *http.Request, submit func() error) error {
+	err := c.csrf.Verify(r)
+	if err != nil {
+		return err
+	}
+	ip := internal.ClientIP(r, c.trustProxy)
+	if c.feedbackLimiter.Limited(ip, time.Now()) {
+		return internal.ErrRateLimited
+	}
+	if r.Form.Get(honeypotField) == "" {
+		err = submit()
+		if err != nil {
+			return err
+		}
+	}
+	c.feedbackLimiter.Record(ip, time.Now())
+	return nil
+}
+
+// feedbackFailure returns the status and the message to show for the error of a feedback submission,
+// http.StatusOK if it was submitted
+func feedbackFailure(err error) (int, string) {
+	var feedbackErrors FeedbackErrors
+	switch {
+	case err == nil:
+		return http.StatusOK, ""
+	case errors.As(err, &feedbackErrors):
+		return http.StatusUnprocessableEntity, ""
+	case errors.Is(err, internal.ErrInvalidCSRFToken):
+		return http.StatusForbidden, "The form has expired, please reload the page and submit it again."
+	case errors.Is(err, internal.ErrRateLimited):
+		return http.StatusTooManyRequests, "You have sent a lot of feedback recently, please try again later."
+	}
+	log.Printf("Error submitting feedback: %s", err)
+	return http.StatusInternalServerError, "Your feedback could not be saved, please try again."
+}
+
+// AdminFeedbackPageData is the feedback listed to admins with the filter it is listed by
+type AdminFeedbackPageData struct {
+	Feedback   []Feedback
+	Topics     []string
+	Statuses   []FeedbackStatus
+	Categories []ReportCategory
+	Filter     FeedbackFilter
+	CSRFToken  string
+}
+
+// AdminFeedback lists the feedback to admins, filtered by topic and status
+func (c *Controller) AdminFeedback(w http.ResponseWriter, r *http.Request) {
+	status, err := ParseFeedbackStatus(r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := FeedbackFilter{
+		Topic:      r.URL.Query().Get("topic"),
+		Status:     status,
+		Category:   ReportCategory(r.URL.Query().Get("category")),
+		QuestionID: queryParamInt(r, "question", 0),
+	}
+	feedback, err := c.service.ListFeedback(r.Context(), filter)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing feedback: %s", err)
+		http.Error(w, "Error listing feedback", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error listing feedback", http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "admin/feedback.tmpl")
 	if err != nil {
 		log.Printf("Error parsing template: %s", err)
 	}
-	err = tmpl.Execute(w, choices)
+	err = tmpl.Execute(w, AdminFeedbackPageData{
+		Feedback:   feedback,
+		Topics:     FeedbackTopics,
+		Statuses:   []FeedbackStatus{FeedbackStatusNew, FeedbackStatusAcknowledged, FeedbackStatusCompleted},
+		Categories: ReportCategories,
+		Filter:     filter,
+		CSRFToken:  token,
+	})
 	if err != nil {
 		log.Printf("Error executing template: %s", err)
 	}
 }
 
-func (c *Controller) Health(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
+// SetFeedbackStatus moves the feedback to the status of the form and returns to the list with its filter
+func (c *Controller) SetFeedbackStatus(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	feedbackID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid feedback id", http.StatusBadRequest)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	err = c.service.SetFeedbackStatus(r.Context(), user.ID, feedbackID, FeedbackStatus(r.Form.Get("status")))
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrInvalidFeedbackStatus) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrFeedbackNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error setting feedback status: %s", err)
+		http.Error(w, "Error setting feedback status", http.StatusInternalServerError)
+		return
+	}
+	query := url.Values{}
+	if topic := r.Form.Get("filter_topic"); topic != "" {
+		query.Set("topic", topic)
+	}
+	if status := r.Form.Get("filter_status"); status != "" {
+		query.Set("status", status)
+	}
+	if category := r.Form.Get("filter_category"); category != "" {
+		query.Set("category", category)
+	}
+	if questionID := r.Form.Get("filter_question"); questionID != "" && questionID != "0" {
+		query.Set("question", questionID)
+	}
+	http.Redirect(w, r, "/admin/feedback?"+query.Encode(), http.StatusSeeOther)
 }
 
-// getQueryStrings parses a list of query strings from the request.
-func getQueryStrings(r *http.Request, query string) ([]string, error) {
-	err := r.ParseForm()
+// QuestionEditPageData is the form for admins to edit a question with its revisions
+type QuestionEditPageData struct {
+	Editor *QuestionEditor
+	// Content is filled in the form, the submitted content when it was invalid
+	Content   QuestionContent
+	Error     string
+	CSRFToken string
+}
+
+// EditQuestionPage renders the form to edit the question and its revision history
+func (c *Controller) EditQuestionPage(w http.ResponseWriter, r *http.Request) {
+	c.renderQuestionEditor(w, r, http.StatusOK, nil, "")
+}
+
+// EditQuestion saves the edit of the question as a new revision
+func (c *Controller) EditQuestion(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		return nil, err
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
 	}
-	var ss []string
-	for _, s := range r.Form[query] {
-		ss = append(ss, strings.Split(s, ",")...)
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
 	}
-	return ss, nil
+	latestRevisionID, _ := strconv.Atoi(r.Form.Get("latest_revision"))
+	content := QuestionContent{
+		Text:       r.Form.Get("text"),
+		References: strings.Split(r.Form.Get("references"), "\n"),
+	}
+	for _, option := range r.Form["option"] {
+		content.Choices = append(content.Choices, ChoiceContent{
+			Option:   option,
+			Text:     r.Form.Get("choice_" + option),
+			IsAnswer: slices.Contains(r.Form["answer"], option),
+		})
+	}
+	err = c.service.EditQuestion(r.Context(), user.ID, questionID, content, latestRevisionID)
+	if errors.Is(err, ErrEmptyQuestionText) || errors.Is(err, ErrInvalidChoices) || errors.Is(err, ErrNoAnswer) {
+		c.renderQuestionEditor(w, r, http.StatusUnprocessableEntity, &content, err.Error())
+		return
+	}
+	c.redirectToQuestionEditor(w, r, err)
 }
 
-func queryParamString(r *http.Request, query string, defaultValue string) string {
-	if s := r.URL.Query().Get(query); s != "" {
-		return s
+// RevertQuestionRevision restores the question to its content from before the revision
+func (c *Controller) RevertQuestionRevision(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
 	}
-	return defaultValue
+	revisionID, err := strconv.Atoi(r.PathValue("revisionID"))
+	if err != nil {
+		http.Error(w, "Invalid revision id", http.StatusBadRequest)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	latestRevisionID, _ := strconv.Atoi(r.Form.Get("latest_revision"))
+	err = c.service.RevertQuestionRevision(r.Context(), user.ID, questionID, revisionID, latestRevisionID)
+	c.redirectToQuestionEditor(w, r, err)
 }
 
-func queryParamInt(r *http.Request, query string, defaultValue int) int {
-	s := r.URL.Query().Get(query)
-	if s == "" {
-		return 0
+// redirectToQuestionEditor redirects back to the editor after the question was changed, or writes the error of the change
+func (c *Controller) redirectToQuestionEditor(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) || errors.Is(err, ErrRevisionNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrQuestionEditConflict) {
+		c.renderQuestionEditor(w, r, http.StatusConflict, nil, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error editing question: %s", err)
+		http.Error(w, "Error editing question", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/questions/"+r.PathValue("id"), http.StatusSeeOther)
+}
+
+// renderQuestionEditor renders the editor of the question in the path, filled in with the content or the current one
+func (c *Controller) renderQuestionEditor(w http.ResponseWriter, r *http.Request, status int, content *QuestionContent, errorMessage string) {
+	questionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
+	}
+	editor, err := c.service.GetQuestionEditor(r.Context(), questionID)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting question: %s", err)
+		http.Error(w, "Error getting question", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting question", http.StatusInternalServerError)
+		return
+	}
+	data := QuestionEditPageData{Editor: editor, Content: editor.Content, Error: errorMessage, CSRFToken: token}
+	if content != nil {
+		data.Content = *content
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "admin/question.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	w.WriteHeader(status)
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+type QuestionListPageData struct {
+	Questions     []QuestionData
+	Search        string
+	Suggestion    string
+	LoadMoreParam LoadMoreParam
+}
+
+type QuestionData struct {
+	ID                 int
+	RuleID             string
+	RuleName           string
+	RuleQuestionNumber string
+	Text               string
+	Highlight          template.HTML
+}
+
+type LoadMoreParam struct {
+	Search string
+	Cursor string
+	Limit  int
+}
+
+// QuestionCardData is a question to answer, posted to SubmitURL
+type QuestionCardData struct {
+	*Question
+	SubmitURL string
+	// Status is shown above the question, such as how many questions are due for review
+	Status string
+}
+
+// ResultCardData is an answered question, NextURL loads the next question
+type ResultCardData struct {
+	*AnswerResult
+	NextURL string
+}
+
+// PracticePageData is the practice page with the rules to filter the questions by
+type PracticePageData struct {
+	Rules []RuleOption
+}
+
+// PracticeMessageData is shown in place of a question when none can be served
+type PracticeMessageData struct {
+	Title   string
+	Message string
+}
+
+// QuestionByID renders the page of a single question, a not found page if there is no question of the id
+func (c *Controller) QuestionByID(w http.ResponseWriter, r *http.Request) {
+	id := queryParamInt(r, "id", 0)
+	_, err := c.service.GetQuestionByID(r.Context(), id)
+	page := "questionByID.tmpl"
+	if errors.Is(err, ErrQuestionNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		page = "notFound.tmpl"
+	} else if err != nil {
+		log.Printf("Error getting question: %s", err)
+		http.Error(w, "Error getting question", http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", page)
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, id)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) Questions(w http.ResponseWriter, _ *http.Request) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "questions.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, nil)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) QuestionList(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFS(c.html, "questionList.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	search := strings.TrimSpace(queryParamString(r, "search", ""))
+	cursor := queryParamString(r, "cursor", "")
+	limit := min(max(queryParamInt(r, "limit", defaultListLimit), 1), maxListLimit)
+	result, err := c.service.ListQuestions(r.Context(), nil, search, cursor, limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting questions: %s", err)
+		result = &QuestionSearchResult{}
+	}
+
+	var questionsData []QuestionData
+	for _, question := range result.Questions {
+		questionsData = append(questionsData, QuestionData{
+			ID:                 question.ID,
+			RuleID:             question.Rule.ID,
+			RuleName:           question.Rule.Name,
+			RuleQuestionNumber: question.RuleQuestionNumber,
+			Text:               question.Text,
+			Highlight:          highlightHTML(question.Highlight),
+		})
+	}
+	data := QuestionListPageData{
+		Questions:  questionsData,
+		Search:     result.Search,
+		Suggestion: result.Suggestion,
+		LoadMoreParam: LoadMoreParam{
+			Search: result.Search,
+			Cursor: result.NextCursor,
+			Limit:  limit,
+		},
+	}
+
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// RandomQuestion renders the practice page, questions of the rules selected in the filter are served one at a time
+func (c *Controller) RandomQuestion(w http.ResponseWriter, r *http.Request) {
+	selectedRules, err := getQueryStrings(r, "rules")
+	if err != nil {
+		log.Printf("Error getting query strings: %s", err)
+	}
+	rules, err := c.service.GetAllRules(r.Context())
+	if err != nil {
+		log.Printf("Error getting rules: %s", err)
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "randomQuestion.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, PracticePageData{Rules: toRuleOptions(rules, selectedRules)})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// NewQuestion renders the question of the id, or the next question to practise within the selected rules.
+// Logged in users get a question chosen for their weak rules, others a random question.
+// Questions already practised in the session are not repeated until every question of the rules has been.
+func (c *Controller) NewQuestion(w http.ResponseWriter, r *http.Request) {
+	id := queryParamInt(r, "id", 0)
+	var question *Question
+	var status string
+	var err error
+	if id != 0 {
+		question, err = c.service.GetQuestionByID(r.Context(), id)
+	} else {
+		question, status, err = c.nextPracticeQuestion(w, r)
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		c.renderPracticeMessage(w, "No question found", "No question matches the selected rules, select other rules to practise.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting question: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		c.renderPracticeMessage(w, "Something went wrong", "The question could not be loaded, please try again.")
+		return
+	}
+
+	tmpl, err := template.ParseFS(c.html, "question.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, QuestionCardData{
+		Question:  question,
+		SubmitURL: fmt.Sprintf("/submit/%d", question.ID),
+		Status:    status,
+	})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// nextPracticeQuestion chooses the next question of the rules in the request and remembers it as practised
+func (c *Controller) nextPracticeQuestion(w http.ResponseWriter, r *http.Request) (*Question, string, error) {
+	rules, err := getQueryStrings(r, "rules")
+	if err != nil {
+		return nil, "", err
+	}
+	seen := practiceSeen(r)
+	var question *Question
+	var status string
+	if user := account.UserFromContext(r.Context()); user != nil {
+		practiceQuestion, err := c.service.GetAdaptiveQuestion(r.Context(), user.ID, rules, seen)
+		if err != nil {
+			return nil, "", err
+		}
+		question, status = &practiceQuestion.Question, practiceQuestion.Reason
+	} else {
+		question, err = c.service.GetRandomQuestion(r.Context(), rules, seen)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	// a question already practised is only chosen once every question of the rules has been,
+	// the practice starts over from it so that the questions are not repeated in the order they came
+	if slices.Contains(seen, question.ID) {
+		seen = nil
+	}
+	setPracticeSeen(w, append(seen, question.ID))
+	return question, status, nil
+}
+
+func (c *Controller) renderPracticeMessage(w http.ResponseWriter, title string, message string) {
+	tmpl, err := template.ParseFS(c.html, "practiceMessage.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, PracticeMessageData{Title: title, Message: message})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) Result(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	result, err := c.service.CheckAnswer(r.Context(), questionID, r.Form["choices"])
+	if user := account.UserFromContext(r.Context()); err == nil && user != nil {
+		_, err = c.service.RecordAnswer(r.Context(), user.ID, questionID, r.Form["choices"], AnswerSourcePractice)
+	}
+	if errors.Is(err, ErrInvalidOption) {
+		http.Error(w, "Invalid option", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking answer: %s", err)
+		http.Error(w, "Error checking answer", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.ParseFS(c.html, "result.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, ResultCardData{
+		AnswerResult: result,
+		NextURL:      "/new-question",
+	})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// Review renders the page to review the questions due under the spaced repetition schedule of the user
+func (c *Controller) Review(w http.ResponseWriter, _ *http.Request) {
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "review.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, nil)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// NextReview renders the next question to review, or that nothing is due
+func (c *Controller) NextReview(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	review, err := c.service.GetNextReview(r.Context(), user.ID)
+	if errors.Is(err, ErrNoReviewDue) {
+		tmpl, err := template.ParseFS(c.html, "reviewDone.tmpl")
+		if err != nil {
+			log.Printf("Error parsing template: %s", err)
+		}
+		err = tmpl.Execute(w, nil)
+		if err != nil {
+			log.Printf("Error executing template: %s", err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting next review: %s", err)
+		http.Error(w, "Error getting next review", http.StatusInternalServerError)
+		return
+	}
+
+	status := fmt.Sprintf("%d due for review", review.DueCount)
+	if review.IsNew {
+		status = "New question, nothing is due for review"
+	}
+	tmpl, err := template.ParseFS(c.html, "question.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, QuestionCardData{
+		Question:  &review.Question,
+		SubmitURL: fmt.Sprintf("/review/%d", review.Question.ID),
+		Status:    status,
+	})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// SubmitReview records the answer to a reviewed question, which reschedules its next review
+func (c *Controller) SubmitReview(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	result, err := c.service.CheckAnswer(r.Context(), questionID, r.Form["choices"])
+	if err == nil {
+		_, err = c.service.RecordAnswer(r.Context(), user.ID, questionID, r.Form["choices"], AnswerSourceReview)
+	}
+	if errors.Is(err, ErrInvalidOption) {
+		http.Error(w, "Invalid option", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.Error(w, "Question not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording review: %s", err)
+		http.Error(w, "Error recording review", http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.ParseFS(c.html, "result.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, ResultCardData{
+		AnswerResult: result,
+		NextURL:      "/review/next",
+	})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+type DashboardPageData struct {
+	Rules []RuleProgressData
+	Total RuleProgressData
+}
+
+type RuleProgressData struct {
+	Name           string
+	QuestionCount  int
+	ReadCount      int
+	AttemptedCount int
+	CorrectCount   int
+	// Accuracy is the percentage of the attempted questions whose latest answer is correct
+	Accuracy int
+	Trend    []WeeklyAccuracyData
+}
+
+type WeeklyAccuracyData struct {
+	Week        string
+	AnswerCount int
+	Accuracy    int
+}
+
+// Dashboard renders the progress of the user on every rule
+func (c *Controller) Dashboard(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	dashboard, err := c.service.GetDashboard(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error getting dashboard: %s", err)
+		http.Error(w, "Error getting dashboard", http.StatusInternalServerError)
+		return
+	}
+	data := DashboardPageData{Total: toRuleProgressData(dashboard.Total, "All rules")}
+	for _, progress := range dashboard.Rules {
+		data.Rules = append(data.Rules, toRuleProgressData(progress, progress.Rule.Name))
+	}
+
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "dashboard.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+type QuestionSetsPageData struct {
+	Sets        []QuestionSet
+	Name        string
+	Description string
+	Error       string
+	CSRFToken   string
+}
+
+type QuestionSetPageData struct {
+	Set       QuestionSet
+	Questions []Question
+	// IsOwner shows the controls to edit the set
+	IsOwner   bool
+	Error     string
+	CSRFToken string
+}
+
+// QuestionSetSearchData are the search results questions are added to a set from
+type QuestionSetSearchData struct {
+	ShareCode     string
+	Questions     []QuestionData
+	InSet         map[int]bool
+	LoadMoreParam LoadMoreParam
+}
+
+// QuestionSets renders the question sets of the user and the form to create one
+func (c *Controller) QuestionSets(w http.ResponseWriter, r *http.Request) {
+	c.renderQuestionSets(w, r, QuestionSetsPageData{})
+}
+
+func (c *Controller) CreateQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	name, description := r.Form.Get("name"), r.Form.Get("description")
+	shareCode, err := c.service.CreateQuestionSet(r.Context(), user.ID, name, description)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrInvalidSetName) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderQuestionSets(w, r, QuestionSetsPageData{Name: name, Description: description, Error: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating question set: %s", err)
+		http.Error(w, "Error creating question set", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/sets/"+shareCode, http.StatusSeeOther)
+}
+
+// QuestionSet renders the questions of the set to anyone with its share code, with the controls to edit it for its owner
+func (c *Controller) QuestionSet(w http.ResponseWriter, r *http.Request) {
+	c.renderQuestionSet(w, r, "")
+}
+
+func (c *Controller) UpdateQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	err = c.service.UpdateQuestionSet(r.Context(), user.ID, r.PathValue("code"), r.Form.Get("name"), r.Form.Get("description"))
+	if errors.Is(err, ErrInvalidSetName) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		c.renderQuestionSet(w, r, err.Error())
+		return
+	}
+	c.redirectToQuestionSet(w, r, err)
+}
+
+// SearchQuestionSet renders the questions matching the search with a checkbox to add each to the set
+func (c *Controller) SearchQuestionSet(w http.ResponseWriter, r *http.Request) {
+	search := strings.TrimSpace(queryParamString(r, "search", ""))
+	cursor := queryParamString(r, "cursor", "")
+	limit := min(max(queryParamInt(r, "limit", defaultListLimit), 1), maxListLimit)
+	result, err := c.service.ListQuestions(r.Context(), nil, search, cursor, limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting questions: %s", err)
+		result = &QuestionSearchResult{}
+	}
+	set, _, err := c.service.GetQuestionSet(r.Context(), r.PathValue("code"))
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting question set: %s", err)
+		http.Error(w, "Error getting question set", http.StatusInternalServerError)
+		return
+	}
+
+	data := QuestionSetSearchData{
+		ShareCode: set.ShareCode,
+		InSet:     make(map[int]bool),
+		LoadMoreParam: LoadMoreParam{
+			Search: result.Search,
+			Cursor: result.NextCursor,
+			Limit:  limit,
+		},
+	}
+	for _, questionID := range set.QuestionIDs {
+		data.InSet[questionID] = true
+	}
+	for _, question := range result.Questions {
+		data.Questions = append(data.Questions, QuestionData{
+			ID:                 question.ID,
+			RuleID:             question.Rule.ID,
+			RuleName:           question.Rule.Name,
+			RuleQuestionNumber: question.RuleQuestionNumber,
+			Text:               question.Text,
+			Highlight:          highlightHTML(question.Highlight),
+		})
+	}
+	tmpl, err := template.ParseFS(c.html, "sets/search.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// AddToQuestionSet adds the questions ticked in the search results to the end of the set
+func (c *Controller) AddToQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	var questionIDs []int
+	for _, s := range r.Form["question"] {
+		questionID, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "Invalid question id", http.StatusBadRequest)
+			return
+		}
+		questionIDs = append(questionIDs, questionID)
+	}
+	err = c.service.AddToQuestionSet(r.Context(), user.ID, r.PathValue("code"), questionIDs)
+	c.redirectToQuestionSet(w, r, err)
+}
+
+func (c *Controller) RemoveFromQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("questionID"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
+	}
+	err = c.service.RemoveFromQuestionSet(r.Context(), user.ID, r.PathValue("code"), questionID)
+	c.redirectToQuestionSet(w, r, err)
+}
+
+// MoveInQuestionSet moves the question one place up or down the set
+func (c *Controller) MoveInQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	questionID, err := strconv.Atoi(r.PathValue("questionID"))
+	if err != nil {
+		http.Error(w, "Invalid question id", http.StatusBadRequest)
+		return
+	}
+	offset := 1
+	if r.FormValue("direction") == "up" {
+		offset = -1
+	}
+	err = c.service.MoveInQuestionSet(r.Context(), user.ID, r.PathValue("code"), questionID, offset)
+	c.redirectToQuestionSet(w, r, err)
+}
+
+func (c *Controller) DeleteQuestionSet(w http.ResponseWriter, r *http.Request) {
+	user := account.UserFromContext(r.Context())
+	err := c.service.DeleteQuestionSet(r.Context(), user.ID, r.PathValue("code"))
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting question set: %s", err)
+		http.Error(w, "Error deleting question set", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/sets", http.StatusSeeOther)
+}
+
+func (c *Controller) renderQuestionSets(w http.ResponseWriter, r *http.Request, data QuestionSetsPageData) {
+	user := account.UserFromContext(r.Context())
+	sets, err := c.service.ListQuestionSets(r.Context(), user.ID)
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting question sets: %s", err)
+	}
+	data.Sets = sets
+	data.CSRFToken, err = c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting question sets", http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "sets/sets.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+func (c *Controller) renderQuestionSet(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	set, questions, err := c.service.GetQuestionSet(r.Context(), r.PathValue("code"))
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		c.renderSetNotFound(w)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting question set: %s", err)
+		http.Error(w, "Error getting question set", http.StatusInternalServerError)
+		return
+	}
+	token, err := c.csrf.Token(w, r)
+	if err != nil {
+		log.Printf("Error creating CSRF token: %s", err)
+		http.Error(w, "Error getting question set", http.StatusInternalServerError)
+		return
+	}
+	user := account.UserFromContext(r.Context())
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "sets/set.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, QuestionSetPageData{
+		Set:       *set,
+		Questions: questions,
+		IsOwner:   user.Can(account.PermissionManageSets) && set.IsOwner(user.ID),
+		Error:     errorMessage,
+		CSRFToken: token,
+	})
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// redirectToQuestionSet redirects back to the set after it was edited, or writes the error of the edit
+func (c *Controller) redirectToQuestionSet(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, account.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrQuestionSetNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if errors.Is(err, ErrQuestionNotFound) {
+		http.Error(w, "Question not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error editing question set: %s", err)
+		http.Error(w, "Error editing question set", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/sets/"+r.PathValue("code"), http.StatusSeeOther)
+}
+
+func (c *Controller) renderSetNotFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	tmpl, err := template.ParseFS(c.html, "base.tmpl", "sets/notFound.tmpl")
+	if err != nil {
+		log.Printf("Error parsing template: %s", err)
+	}
+	err = tmpl.Execute(w, nil)
+	if err != nil {
+		log.Printf("Error executing template: %s", err)
+	}
+}
+
+// scopedQuestions returns the questions of the set in the query and the set, or every question when there is no set
+func (c *Controller) scopedQuestions(r *http.Request) (*QuestionSet, []Question, error) {
+	shareCode := r.URL.Query().Get("set")
+	if shareCode == "" {
+		questions, err := c.service.GetAllQuestions(r.Context())
+		return nil, questions, err
+	}
+	return c.service.GetQuestionSet(r.Context(), shareCode)
+}
+
+func (c *Controller) Health(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// getQueryStrings parses a list of query strings from the request.
+func getQueryStrings(r *http.Request, query string) ([]string, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	var ss []string
+	for _, s := range r.Form[query] {
+		ss = append(ss, strings.Split(s, ",")...)
+	}
+	return ss, nil
+}
+
+// practiceSeen returns the ids of the questions practised in this browser session
+func practiceSeen(r *http.Request) []int {
+	cookie, err := r.Cookie(practiceSeenCookie)
+	if err != nil {
+		return nil
+	}
+	var ids []int
+	for _, s := range strings.Split(cookie.Value, ".") {
+		id, err := strconv.Atoi(s)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// setPracticeSeen saves the questions practised in a session cookie, keeping the latest maxPracticeSeen
+func setPracticeSeen(w http.ResponseWriter, ids []int) {
+	if len(ids) > maxPracticeSeen {
+		ids = ids[len(ids)-maxPracticeSeen:]
+	}
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, strconv.Itoa(id))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     practiceSeenCookie,
+		Value:    strings.Join(values, "."),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// highlightHTML escapes the highlighted snippet of a question and marks the matched search terms.
+func highlightHTML(highlight string) template.HTML {
+	if highlight == "" {
+		return ""
+	}
+	escaped := template.HTMLEscapeString(highlight)
+	escaped = strings.ReplaceAll(escaped, template.HTMLEscapeString(HighlightStart), "<mark>")
+	escaped = strings.ReplaceAll(escaped, template.HTMLEscapeString(HighlightStop), "</mark>")
+	return template.HTML(escaped)
+}
+
+func queryParamString(r *http.Request, query string, defaultValue string) string {
+	if s := r.URL.Query().Get(query); s != "" {
+		return s
+	}
+	return defaultValue
+}
+
+func queryParamInt(r *http.Request, query string, defaultValue int) int {
+	s := r.URL.Query().Get(query)
+	if s == "" {
+		return defaultValue
 	}
 	i, err := strconv.Atoi(s)
 	if err != nil {
@@ -0,0 +1,62 @@
+package trainer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+)
+
+// fakePracticeService has three questions and chooses the first one not excluded,
+// falling back to the first question once every question is
+type fakePracticeService struct {
+	Service
+}
+
+func (s *fakePracticeService) choose(excludeIDs []int) *Question {
+	for id := 1; id <= 3; id++ {
+		if !slices.Contains(excludeIDs, id) {
+			return &Question{ID: id}
+		}
+	}
+	return &Question{ID: 1}
+}
+
+func (s *fakePracticeService) GetRandomQuestion(_ context.Context, _ []string, excludeIDs []int) (*Question, error) {
+	return s.choose(excludeIDs), nil
+}
+
+func (s *fakePracticeService) GetAdaptiveQuestion(_ context.Context, _ int, _ []string, excludeIDs []int) (*PracticeQuestion, error) {
+	return &PracticeQuestion{Question: *s.choose(excludeIDs)}, nil
+}
+
+func TestNextPracticeQuestionStartsOverWhenEverySeen(t *testing.T) {
+	for name, user := range map[string]*account.User{"anonymous": nil, "logged in": {ID: 1, Role: account.RoleReferee}} {
+		t.Run(name, func(t *testing.T) {
+			c := &Controller{service: &fakePracticeService{}}
+			var cookie *http.Cookie
+			var seen []string
+			for range 5 {
+				r := httptest.NewRequest(http.MethodGet, "/random-question", nil)
+				r = r.WithContext(account.WithUser(r.Context(), user))
+				if cookie != nil {
+					r.AddCookie(cookie)
+				}
+				w := httptest.NewRecorder()
+				_, _, err := c.nextPracticeQuestion(w, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cookie = w.Result().Cookies()[0]
+				seen = append(seen, cookie.Value)
+			}
+			want := []string{"1", "1.2", "1.2.3", "1", "1.2"}
+			if !slices.Equal(seen, want) {
+				t.Errorf("seen questions %q, want %q", seen, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package trainer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// QuestionCursor is the position of the last question of a page in the list ordering,
+// which is by search rank, rule sort order, question number and finally question id
+type QuestionCursor struct {
+	Rank           float32
+	SortOrder      int
+	QuestionNumber int
+	ID             int
+}
+
+// cursorFromQuestion returns the cursor pointing right after the given question
+func cursorFromQuestion(question Question) QuestionCursor {
+	return QuestionCursor{
+		Rank:           question.Rank,
+		SortOrder:      question.Rule.SortOrder,
+		QuestionNumber: question.QuestionNumber,
+		ID:             question.ID,
+	}
+}
+
+// Encode returns the cursor as an opaque url safe string
+func (c QuestionCursor) Encode() string {
+	rank := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32)
+	raw := fmt.Sprintf("%s:%d:%d:%d", rank, c.SortOrder, c.QuestionNumber, c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeQuestionCursor parses a cursor returned by QuestionCursor.Encode
+func DecodeQuestionCursor(s string) (*QuestionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 4 {
+		return nil, ErrInvalidCursor
+	}
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var ints [3]int
+	for i, part := range parts[1:] {
+		ints[i], err = strconv.Atoi(part)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &QuestionCursor{
+		Rank:           float32(rank),
+		SortOrder:      ints[0],
+		QuestionNumber: ints[1],
+		ID:             ints[2],
+	}, nil
+}
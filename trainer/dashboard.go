@@ -0,0 +1,27 @@
+package trainer
+
+import "time"
+
+// trendWeeks is how many weeks of accuracy the dashboard shows, including the current week
+const trendWeeks = 8
+
+// lastWeeks returns the start of the current week and the weeks before it, oldest first.
+// Weeks start on Monday at midnight UTC, like the weeks of GetWeeklyAccuracy.
+func lastWeeks(now time.Time, count int) []time.Time {
+	now = now.UTC()
+	daysSinceMonday := (int(now.Weekday()) + 6) % 7
+	current := time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	weeks := make([]time.Time, count)
+	for i := range weeks {
+		weeks[i] = current.AddDate(0, 0, -7*(count-1-i))
+	}
+	return weeks
+}
+
+func emptyTrend(weeks []time.Time) []WeeklyAccuracy {
+	trend := make([]WeeklyAccuracy, len(weeks))
+	for i, week := range weeks {
+		trend[i].WeekStart = week
+	}
+	return trend
+}
@@ -1,5 +1,31 @@
 package trainer
 
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrQuestionNotFound = errors.New("question not found")
+	ErrInvalidOption    = errors.New("invalid option")
+	ErrReviewNotFound   = errors.New("review not found")
+	// ErrNoReviewDue is returned when every question has been reviewed and none is due yet
+	ErrNoReviewDue = errors.New("no review due")
+	// ErrQuestionSetNotFound is also returned when a user who does not own the set tries to edit it
+	ErrQuestionSetNotFound   = errors.New("question set not found")
+	ErrInvalidSetName        = errors.New("name must be between 1 and 100 characters")
+	ErrFeedbackNotFound      = errors.New("feedback not found")
+	ErrInvalidFeedbackStatus = errors.New("invalid feedback status")
+	ErrRevisionNotFound      = errors.New("revision not found")
+	ErrEmptyQuestionText     = errors.New("the question needs a text")
+	ErrInvalidChoices        = errors.New("every choice needs an option of its own and a text")
+	ErrNoAnswer              = errors.New("at least one choice must be correct")
+	// ErrQuestionEditConflict is returned when the question was changed since the admin loaded it
+	ErrQuestionEditConflict = errors.New("the question was changed by someone else in the meantime, reload it to see the changes")
+)
+
 type QuestionEntity struct {
 	ID             int
 	Text           string
@@ -7,6 +33,13 @@ type QuestionEntity struct {
 	QuestionNumber int
 }
 
+// QuestionSearchEntity is a question row returned by a full text search, with its rank and highlighted snippet
+type QuestionSearchEntity struct {
+	QuestionEntity
+	Rank     float32
+	Headline string
+}
+
 type ChoiceEntity struct {
 	ID         int
 	QuestionId int
@@ -35,6 +68,99 @@ type FeedbackEntity struct {
 	Text           string
 	IsAcknowledged bool
 	IsCompleted    bool
+	CreatedAt      time.Time
+	QuestionID     *int
+	Category       *string
+}
+
+// FeedbackAuditEntity is a change of a status field of a feedback by an admin, with the name of the admin
+type FeedbackAuditEntity struct {
+	ID         int
+	FeedbackID int
+	UserID     int
+	UserName   string
+	Field      string
+	OldValue   bool
+	NewValue   bool
+	CreatedAt  time.Time
+}
+
+// Change describes the change for the audit trail, such as "acknowledged" or "reopened"
+func (a FeedbackAuditEntity) Change() string {
+	switch {
+	case a.Field == "is_acknowledged" && a.NewValue:
+		return "acknowledged"
+	case a.Field == "is_acknowledged":
+		return "unacknowledged"
+	case a.Field == "is_completed" && a.NewValue:
+		return "completed"
+	default:
+		return "reopened"
+	}
+}
+
+type AnswerEntity struct {
+	ID              int
+	UserID          int
+	QuestionID      int
+	SelectedOptions []string
+	IsCorrect       bool
+	Source          string
+	CreatedAt       time.Time
+}
+
+type ReviewEntity struct {
+	UserID       int
+	QuestionID   int
+	Ease         float64
+	IntervalDays int
+	Repetitions  int
+	DueAt        time.Time
+	ReviewedAt   time.Time
+}
+
+// PracticeStatEntity is the answer history of a user for a question
+type PracticeStatEntity struct {
+	QuestionID     int
+	RuleID         string
+	AttemptCount   int
+	WrongCount     int
+	LastAnsweredAt *time.Time
+	IsLastCorrect  *bool
+}
+
+type RuleProgressEntity struct {
+	RuleID         string
+	RuleName       string
+	SortOrder      int
+	QuestionCount  int
+	ReadCount      int
+	AttemptedCount int
+	CorrectCount   int
+}
+
+type AccuracyEntity struct {
+	RuleID       string
+	WeekStart    time.Time
+	AnswerCount  int
+	CorrectCount int
+}
+
+// QuestionSet is a list of questions curated by its owner, in the order chosen by the owner.
+// Anyone with its share code can study the questions or take them as an exam.
+type QuestionSet struct {
+	ID          int
+	Name        string
+	Description string
+	OwnerID     int
+	ShareCode   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	QuestionIDs []int
+}
+
+func (s QuestionSet) IsOwner(userID int) bool {
+	return s.OwnerID == userID
 }
 
 type Question struct {
@@ -45,6 +171,24 @@ type Question struct {
 	RuleQuestionNumber string
 	Choices            []Choice
 	References         []Reference
+	// Highlight is a snippet of the text with the matched search terms wrapped in HighlightStart and HighlightStop.
+	// It is empty when the question was not returned by a search.
+	Highlight string
+	// Rank is the relevance of the question to the search, zero when the question was not returned by a search.
+	Rank float32
+	// AnswerCorrectedAt is when an admin last changed which choices are correct, nil when they never were
+	AnswerCorrectedAt *time.Time
+}
+
+// QuestionSearchResult is a page of questions matching a search
+type QuestionSearchResult struct {
+	Questions []Question
+	// Search is the search term the questions were found with, after synonyms were replaced
+	Search string
+	// Suggestion is the search term with its misspelt words corrected, empty when every word is known
+	Suggestion string
+	// NextCursor is the opaque cursor to fetch the next page, empty when there are no more questions
+	NextCursor string
 }
 
 type Choice struct {
@@ -66,6 +210,128 @@ type Reference struct {
 	Text string
 }
 
+// QuestionContent is what admins edit of a question, every revision keeps the content before and after the edit
+type QuestionContent struct {
+	Text       string          `json:"text"`
+	Choices    []ChoiceContent `json:"choices"`
+	References []string        `json:"references"`
+}
+
+type ChoiceContent struct {
+	Option   string `json:"option"`
+	Text     string `json:"text"`
+	IsAnswer bool   `json:"is_answer"`
+}
+
+// Answers returns the options of the correct choices
+func (c QuestionContent) Answers() []string {
+	var answers []string
+	for _, choice := range c.Choices {
+		if choice.IsAnswer {
+			answers = append(answers, choice.Option)
+		}
+	}
+	return answers
+}
+
+// Choice returns the choice of the option, false when the question has no such choice
+func (c QuestionContent) Choice(option string) (ChoiceContent, bool) {
+	for _, choice := range c.Choices {
+		if choice.Option == option {
+			return choice, true
+		}
+	}
+	return ChoiceContent{}, false
+}
+
+// IsAnswerChanged is true when the edit from before to after changed which choices are correct
+func IsAnswerChanged(before QuestionContent, after QuestionContent) bool {
+	return !slices.Equal(before.Answers(), after.Answers())
+}
+
+func (c QuestionContent) Equal(other QuestionContent) bool {
+	return c.Text == other.Text && slices.Equal(c.Choices, other.Choices) && slices.Equal(c.References, other.References)
+}
+
+// QuestionRevision is an edit of a question by an admin with the content before and after it, it is never changed
+// once saved
+type QuestionRevision struct {
+	ID         int
+	QuestionID int
+	UserID     int
+	UserName   string
+	Before     QuestionContent
+	After      QuestionContent
+	// IsAnswerChanged is true when the edit changed which choices are correct, users are told the answer was corrected
+	IsAnswerChanged bool
+	// RevertedRevisionID is the revision this one reverted, nil for an edit
+	RevertedRevisionID *int
+	CreatedAt          time.Time
+}
+
+// RevisionChange is a field of a question changed by a revision, Old is empty for an added field
+// and New for a removed one
+type RevisionChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Changes returns the fields the revision changed, the text first, then the choices by option and the references
+func (r QuestionRevision) Changes() []RevisionChange {
+	var changes []RevisionChange
+	if r.Before.Text != r.After.Text {
+		changes = append(changes, RevisionChange{Field: "Text", Old: r.Before.Text, New: r.After.Text})
+	}
+	var options []string
+	for _, choice := range slices.Concat(r.Before.Choices, r.After.Choices) {
+		if !slices.Contains(options, choice.Option) {
+			options = append(options, choice.Option)
+		}
+	}
+	slices.Sort(options)
+	for _, option := range options {
+		before, wasChoice := r.Before.Choice(option)
+		after, isChoice := r.After.Choice(option)
+		if before.Text != after.Text {
+			changes = append(changes, RevisionChange{Field: "Choice " + option, Old: before.Text, New: after.Text})
+		}
+		if wasChoice && isChoice && before.IsAnswer != after.IsAnswer {
+			changes = append(changes, RevisionChange{Field: "Choice " + option + " correct", Old: yesNo(before.IsAnswer), New: yesNo(after.IsAnswer)})
+		}
+	}
+	if !slices.Equal(r.Before.References, r.After.References) {
+		changes = append(changes, RevisionChange{
+			Field: "References",
+			Old:   strings.Join(r.Before.References, "\n"),
+			New:   strings.Join(r.After.References, "\n"),
+		})
+	}
+	return changes
+}
+
+// QuestionEditor is a question with its content for admins to edit and its revisions, the latest first
+type QuestionEditor struct {
+	Question  Question
+	Content   QuestionContent
+	Revisions []QuestionRevision
+}
+
+// LatestRevisionID is sent back with an edit to detect changes made in the meantime, zero when never edited
+func (e QuestionEditor) LatestRevisionID() int {
+	if len(e.Revisions) == 0 {
+		return 0
+	}
+	return e.Revisions[0].ID
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 type Feedback struct {
 	ID             int
 	Name           string
@@ -74,4 +340,209 @@ type Feedback struct {
 	Text           string
 	IsAcknowledged bool
 	IsCompleted    bool
+	CreatedAt      time.Time
+	// QuestionID is the question a problem is reported on, nil for general feedback
+	QuestionID *int
+	Category   ReportCategory
+	// Audits are the changes of the status by admins, the oldest first
+	Audits []FeedbackAuditEntity
+	// Question is the question reported on, only loaded for admins
+	Question *Question
+	// OpenReportCount is the number of reports on the question that are not completed
+	OpenReportCount int
+}
+
+// ReportCategory is the kind of problem reported on a question
+type ReportCategory string
+
+const (
+	ReportWrongAnswer       ReportCategory = "wrong_answer"
+	ReportTypo              ReportCategory = "typo"
+	ReportAmbiguous         ReportCategory = "ambiguous"
+	ReportOutdatedReference ReportCategory = "outdated_reference"
+)
+
+var ReportCategories = []ReportCategory{ReportWrongAnswer, ReportTypo, ReportAmbiguous, ReportOutdatedReference}
+
+func (c ReportCategory) Label() string {
+	switch c {
+	case ReportWrongAnswer:
+		return "Wrong answer"
+	case ReportTypo:
+		return "Typo"
+	case ReportAmbiguous:
+		return "Ambiguous"
+	case ReportOutdatedReference:
+		return "Outdated reference"
+	}
+	return string(c)
+}
+
+// FeedbackErrors are the reasons the fields of a feedback are invalid, by form field
+type FeedbackErrors map[string]string
+
+func (e FeedbackErrors) Error() string {
+	return "invalid feedback"
+}
+
+// FeedbackTopics are the topics offered on the feedback form
+var FeedbackTopics = []string{"questions", "test", "others"}
+
+// FeedbackStatus is where a feedback is in its handling by the admins
+type FeedbackStatus string
+
+const (
+	FeedbackStatusNew          FeedbackStatus = "new"
+	FeedbackStatusAcknowledged FeedbackStatus = "acknowledged"
+	FeedbackStatusCompleted    FeedbackStatus = "completed"
+)
+
+// ParseFeedbackStatus returns the status of the name, the empty status matches every feedback
+func ParseFeedbackStatus(name string) (FeedbackStatus, error) {
+	switch status := FeedbackStatus(name); status {
+	case "", FeedbackStatusNew, FeedbackStatusAcknowledged, FeedbackStatusCompleted:
+		return status, nil
+	}
+	return "", ErrInvalidFeedbackStatus
+}
+
+func (f Feedback) Status() FeedbackStatus {
+	switch {
+	case f.IsCompleted:
+		return FeedbackStatusCompleted
+	case f.IsAcknowledged:
+		return FeedbackStatusAcknowledged
+	default:
+		return FeedbackStatusNew
+	}
+}
+
+// FeedbackFilter selects the feedback listed to admins, empty fields match every feedback
+type FeedbackFilter struct {
+	Topic      string
+	Status     FeedbackStatus
+	Category   ReportCategory
+	QuestionID int
+}
+
+// AnswerSource is where an answer was given
+type AnswerSource string
+
+const (
+	// AnswerSourceHome answers are the selections on the home page, which are shown again when the user comes back
+	AnswerSourceHome     AnswerSource = "home"
+	AnswerSourceExam     AnswerSource = "exam"
+	AnswerSourcePractice AnswerSource = "practice"
+	AnswerSourceReview   AnswerSource = "review"
+)
+
+// Reschedules tells if answers from the source reschedule the spaced repetition review of their question
+func (s AnswerSource) Reschedules() bool {
+	switch s {
+	case AnswerSourceReview, AnswerSourcePractice, AnswerSourceExam:
+		return true
+	default:
+		return false
+	}
+}
+
+type Answer struct {
+	ID              int
+	UserID          int
+	QuestionID      int
+	SelectedOptions []string
+	IsCorrect       bool
+	Source          AnswerSource
+	CreatedAt       time.Time
+}
+
+// Progress is the state of the questions page of a user
+type Progress struct {
+	// Selections maps question ids to the options currently selected
+	Selections map[int][]string
+	// Read is the set of question ids marked as read
+	Read map[int]bool
+	// IsImported is true once the progress saved in the browser has been imported
+	IsImported bool
+}
+
+// LocalProgress is the progress saved in the browser local storage before the user logged in
+type LocalProgress struct {
+	// ChoiceCheckMap maps "choice<choice id>-<option>" to whether the choice is checked
+	ChoiceCheckMap map[string]bool `json:"choiceCheckMap"`
+	// ReadCheckMap maps "question-<rule question number>" to whether the question is marked as read
+	ReadCheckMap map[string]bool `json:"readCheckMap"`
+}
+
+// ExamResult is the grading of a submitted exam
+type ExamResult struct {
+	Questions    []ExamQuestionResult
+	CorrectCount int
+}
+
+type ExamQuestionResult struct {
+	Question Question
+	Result   AnswerResult
+}
+
+// Review is the spaced repetition schedule of a question for a user
+type Review struct {
+	UserID     int
+	QuestionID int
+	// Ease is how fast the interval grows after every correct answer
+	Ease         float64
+	IntervalDays int
+	// Repetitions is the number of correct answers in a row
+	Repetitions int
+	DueAt       time.Time
+	ReviewedAt  time.Time
+}
+
+// ReviewQuestion is the next question to review
+type ReviewQuestion struct {
+	Question Question
+	// IsNew is true when the question has never been answered, it is only served when no review is due
+	IsNew bool
+	// DueCount is the number of questions due for review, including this one
+	DueCount int
+}
+
+// PracticeQuestion is a question chosen for a user to practise, with the reason it was chosen
+type PracticeQuestion struct {
+	Question Question
+	Reason   string
+}
+
+// RuleProgress is how far a user got through the questions of a rule
+type RuleProgress struct {
+	Rule          Rule
+	QuestionCount int
+	ReadCount     int
+	// AttemptedCount is the number of questions answered at least once
+	AttemptedCount int
+	// CorrectCount is the number of questions whose latest answer is correct
+	CorrectCount int
+	// Trend is the accuracy of the answers of every week, oldest first
+	Trend []WeeklyAccuracy
+}
+
+type WeeklyAccuracy struct {
+	WeekStart    time.Time
+	AnswerCount  int
+	CorrectCount int
+}
+
+// Accuracy returns the percentage of correct answers of the week, 0 if there were none
+func (w WeeklyAccuracy) Accuracy() int {
+	if w.AnswerCount == 0 {
+		return 0
+	}
+	return w.CorrectCount * 100 / w.AnswerCount
+}
+
+// Dashboard is the progress of a user by rule
+type Dashboard struct {
+	Rules []RuleProgress
+	// Total sums up the progress of every rule
+	Total RuleProgress
 }
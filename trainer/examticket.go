@@ -0,0 +1,65 @@
+package trainer
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+const (
+	// examTicketTTL is how long an exam can be submitted after it was handed out
+	examTicketTTL = 24 * time.Hour
+	// examTicketIDBytes is the length of the random id that lets a ticket be used only once
+	examTicketIDBytes = 16
+)
+
+var (
+	// ErrInvalidExam is returned for a ticket that was tampered with, has expired or was already submitted
+	ErrInvalidExam = errors.New("the exam is not valid, has expired or was already submitted, please start a new one")
+	// ErrDuplicateExamQuestion is returned when a question is graded more than once in an exam
+	ErrDuplicateExamQuestion = errors.New("a question appears more than once in the exam")
+)
+
+// examTicket is the list of questions handed out for an exam, signed so that exactly these questions are graded.
+// Its id is saved when the exam is submitted, so that a ticket is graded only once.
+type examTicket struct {
+	ID          string `json:"id"`
+	QuestionIDs []int  `json:"q"`
+	IssuedAt    int64  `json:"t"`
+}
+
+// issueExamTicket returns the signed ticket of an exam of the questions
+func issueExamTicket(signer *internal.Signer, questionIDs []int, now time.Time) (string, error) {
+	b := make([]byte, examTicketIDBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(examTicket{ID: base64.RawURLEncoding.EncodeToString(b), QuestionIDs: questionIDs, IssuedAt: now.Unix()})
+	if err != nil {
+		return "", err
+	}
+	return signer.Sign(payload), nil
+}
+
+// verifyExamTicket returns the ticket made by issueExamTicket, ErrInvalidExam if it was tampered with or has expired.
+// Whether it was already used is checked when the exam is graded.
+func verifyExamTicket(signer *internal.Signer, token string, now time.Time) (*examTicket, error) {
+	payload, err := signer.Verify(token)
+	if err != nil {
+		return nil, ErrInvalidExam
+	}
+	var ticket examTicket
+	err = json.Unmarshal(payload, &ticket)
+	if err != nil || ticket.ID == "" {
+		return nil, ErrInvalidExam
+	}
+	if now.Sub(time.Unix(ticket.IssuedAt, 0)) > examTicketTTL {
+		return nil, ErrInvalidExam
+	}
+	return &ticket, nil
+}
@@ -0,0 +1,138 @@
+package trainer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+func TestExamTicket(t *testing.T) {
+	signer, err := internal.NewSigner("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	ticket, err := issueExamTicket(signer, []int{3, 1, 2}, issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := verifyExamTicket(signer, ticket, issuedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("verifyExamTicket() error = %s", err)
+	}
+	if !slices.Equal(verified.QuestionIDs, []int{3, 1, 2}) {
+		t.Errorf("verifyExamTicket() = %v, want [3 1 2]", verified.QuestionIDs)
+	}
+	other, err := issueExamTicket(signer, []int{3, 1, 2}, issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherVerified, err := verifyExamTicket(signer, other, issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.ID == "" || otherVerified.ID == verified.ID {
+		t.Errorf("ticket ids %q and %q, want a new id for every ticket", verified.ID, otherVerified.ID)
+	}
+
+	forged, err := issueExamTicket(signer, []int{3, 3, 3}, issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(ticket, ".")
+	otherSigner, err := internal.NewSigner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer *internal.Signer
+		ticket string
+		now    time.Time
+	}{
+		{"expired", signer, ticket, issuedAt.Add(examTicketTTL + time.Second)},
+		{"other questions with the signature", signer, payload + "." + signature, issuedAt},
+		{"signed with another key", otherSigner, ticket, issuedAt},
+		{"empty", signer, "", issuedAt},
+		{"not a ticket", signer, "1,2,3", issuedAt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifyExamTicket(tt.signer, tt.ticket, tt.now)
+			if !errors.Is(err, ErrInvalidExam) {
+				t.Errorf("verifyExamTicket() error = %v, want ErrInvalidExam", err)
+			}
+		})
+	}
+}
+
+// fakeTicketRepository grades the exams of its questions and remembers the used tickets
+type fakeTicketRepository struct {
+	Repository
+	questions []Question
+	used      map[string]bool
+	answers   int
+}
+
+func (r *fakeTicketRepository) FindQuestionsByIDs(_ context.Context, ids []int) ([]Question, error) {
+	var questions []Question
+	for _, question := range r.questions {
+		if slices.Contains(ids, question.ID) {
+			questions = append(questions, question)
+		}
+	}
+	return questions, nil
+}
+
+func (r *fakeTicketRepository) UseExamTicket(_ context.Context, ticketID string, _ time.Time) (bool, error) {
+	if r.used[ticketID] {
+		return false, nil
+	}
+	r.used[ticketID] = true
+	return true, nil
+}
+
+func (r *fakeTicketRepository) SaveAnswer(_ context.Context, _ Answer, _ func(Review) Review) error {
+	r.answers++
+	return nil
+}
+
+func TestGradeExamOncePerTicket(t *testing.T) {
+	repository := &fakeTicketRepository{
+		questions: []Question{{ID: 1, Choices: []Choice{{Option: "a", IsAnswer: true}, {Option: "b"}}}},
+		used:      make(map[string]bool),
+	}
+	service := NewService(repository, nil)
+	selections := map[int][]string{1: {"b"}}
+
+	_, err := service.GradeExam(context.Background(), 7, "ticket", []int{1}, map[int][]string{1: {"c"}})
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("GradeExam() with an unknown option error = %v, want ErrInvalidOption", err)
+	}
+	if repository.used["ticket"] {
+		t.Fatal("a submission that is refused used up the ticket")
+	}
+	result, err := service.GradeExam(context.Background(), 7, "ticket", []int{1}, selections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CorrectCount != 0 || repository.answers != 1 {
+		t.Fatalf("correct %d with %d answers saved, want 0 and 1", result.CorrectCount, repository.answers)
+	}
+	// the answers are revealed after the first submission, the ticket is refused with them
+	_, err = service.GradeExam(context.Background(), 7, "ticket", []int{1}, map[int][]string{1: {"a"}})
+	if !errors.Is(err, ErrInvalidExam) {
+		t.Errorf("GradeExam() replaying the ticket error = %v, want ErrInvalidExam", err)
+	}
+	if repository.answers != 1 {
+		t.Errorf("saved %d answers, want the replay not to be saved", repository.answers)
+	}
+}
@@ -0,0 +1,99 @@
+package trainer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aattwwss/ihf-referee-rules/internal"
+)
+
+func TestValidateFeedback(t *testing.T) {
+	questionID := 7
+	valid := Feedback{Name: " Anna ", Email: " anna@example.com ", Topic: "questions", Text: " The answer of 8.1 is wrong. "}
+	tests := []struct {
+		name       string
+		change     func(f *Feedback)
+		wantFields []string
+	}{
+		{"valid", func(f *Feedback) {}, nil},
+		{"no name", func(f *Feedback) { f.Name = "   " }, []string{"name"}},
+		{"name too long", func(f *Feedback) { f.Name = strings.Repeat("é", maxFeedbackNameLength+1) }, []string{"name"}},
+		{"longest name", func(f *Feedback) { f.Name = strings.Repeat("é", maxFeedbackNameLength) }, nil},
+		{"name with a line break", func(f *Feedback) { f.Name = "Anna\r\nBcc: spam@example.com" }, []string{"name"}},
+		{"name with a tab", func(f *Feedback) { f.Name = "Anna\tSmith" }, []string{"name"}},
+		{"name with accents", func(f *Feedback) { f.Name = "Zoë Ångström" }, nil},
+		{"invalid email", func(f *Feedback) { f.Email = "anna" }, []string{"email"}},
+		{"email with a display name", func(f *Feedback) { f.Email = "Anna <anna@example.com>" }, []string{"email"}},
+		{"unknown topic", func(f *Feedback) { f.Topic = "rules" }, []string{"topic"}},
+		{"report without a category", func(f *Feedback) { f.QuestionID = &questionID }, []string{"category"}},
+		{"report with a category", func(f *Feedback) { f.QuestionID, f.Category = &questionID, ReportTypo }, nil},
+		{"text too short", func(f *Feedback) { f.Text = " too short " }, []string{"text"}},
+		{"text too long", func(f *Feedback) { f.Text = strings.Repeat("a", maxFeedbackTextLength+1) }, []string{"text"}},
+		{"everything wrong", func(f *Feedback) { *f = Feedback{} }, []string{"email", "name", "text", "topic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feedback := valid
+			tt.change(&feedback)
+			got, err := validateFeedback(feedback)
+			var errs FeedbackErrors
+			errors.As(err, &errs)
+			var fields []string
+			for field := range errs {
+				fields = append(fields, field)
+			}
+			slices.Sort(fields)
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Fatalf("validateFeedback() errors on %q, want %q", fields, tt.wantFields)
+			}
+			if err == nil && (got.Name != strings.TrimSpace(feedback.Name) || got.Email != "anna@example.com" || got.Text != strings.TrimSpace(feedback.Text)) {
+				t.Errorf("validateFeedback() = %+v, want the fields trimmed", got)
+			}
+		})
+	}
+}
+
+// feedbackRequest posts the form with the CSRF token of the browser from the IP
+func feedbackRequest(form url.Values, ip string) *http.Request {
+	form.Set(internal.CSRFFieldName, "token")
+	r := httptest.NewRequest(http.MethodPost, "/feedback", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+	r.RemoteAddr = ip + ":1234"
+	_ = r.ParseForm()
+	return r
+}
+
+func TestScreenFeedbackCountsAcceptedFeedback(t *testing.T) {
+	c := &Controller{csrf: internal.NewCSRF(false), feedbackLimiter: internal.NewRateLimiter(2, time.Hour)}
+	invalid := func() error { return FeedbackErrors{"text": "Write more."} }
+	accepted := func() error { return nil }
+	submissions := []struct {
+		name       string
+		form       url.Values
+		submit     func() error
+		wantStatus int
+	}{
+		{"invalid", url.Values{}, invalid, http.StatusUnprocessableEntity},
+		{"invalid again", url.Values{}, invalid, http.StatusUnprocessableEntity},
+		{"invalid a third time", url.Values{}, invalid, http.StatusUnprocessableEntity},
+		{"accepted", url.Values{}, accepted, http.StatusOK},
+		{"spam told it was accepted", url.Values{honeypotField: {"http://spam.example"}}, invalid, http.StatusOK},
+		{"over the limit", url.Values{}, accepted, http.StatusTooManyRequests},
+	}
+	for _, submission := range submissions {
+		err := c.screenFeedback(feedbackRequest(submission.form, "192.0.2.1"), submission.submit)
+		if status, _ := feedbackFailure(err); status != submission.wantStatus {
+			t.Fatalf("%s: status %d, want %d", submission.name, status, submission.wantStatus)
+		}
+	}
+	if err := c.screenFeedback(feedbackRequest(url.Values{}, "192.0.2.2"), accepted); err != nil {
+		t.Errorf("another IP: screenFeedback() error = %v, want nil", err)
+	}
+}
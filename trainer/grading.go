@@ -0,0 +1,81 @@
+package trainer
+
+import "slices"
+
+// Verdict is the outcome of a single choice of an answered question
+type Verdict string
+
+const (
+	// VerdictCorrect is a selected choice that is an answer
+	VerdictCorrect Verdict = "correct"
+	// VerdictWrong is a selected choice that is not an answer
+	VerdictWrong Verdict = "wrong"
+	// VerdictMissing is an answer that was not selected
+	VerdictMissing Verdict = "missing"
+	// VerdictBlank is a choice that is neither selected nor an answer
+	VerdictBlank Verdict = "blank"
+)
+
+type ChoiceVerdict struct {
+	Choice   Choice
+	Selected bool
+	Verdict  Verdict
+}
+
+// AnswerResult is the grading of the options selected for a question
+type AnswerResult struct {
+	QuestionID int
+	Choices    []ChoiceVerdict
+	// IsCorrect is true when exactly the answers were selected
+	IsCorrect bool
+}
+
+// CorrectOptions returns the options of the choices that are answers
+func (a *AnswerResult) CorrectOptions() []string {
+	options := []string{}
+	for _, choice := range a.Choices {
+		if choice.Choice.IsAnswer {
+			options = append(options, choice.Choice.Option)
+		}
+	}
+	return options
+}
+
+// GradeAnswer grades the selected options against the choices of a question,
+// ErrInvalidOption if an option is not one of the choices
+func GradeAnswer(questionID int, choices []Choice, selected []string) (*AnswerResult, error) {
+	for _, option := range selected {
+		if !slices.ContainsFunc(choices, func(choice Choice) bool { return choice.Option == option }) {
+			return nil, ErrInvalidOption
+		}
+	}
+	result := &AnswerResult{
+		QuestionID: questionID,
+		Choices:    make([]ChoiceVerdict, 0, len(choices)),
+		IsCorrect:  true,
+	}
+	for _, choice := range choices {
+		isSelected := slices.Contains(selected, choice.Option)
+		choice.IsSelected = isSelected
+		var verdict Verdict
+		switch {
+		case isSelected && choice.IsAnswer:
+			verdict = VerdictCorrect
+		case isSelected:
+			verdict = VerdictWrong
+		case choice.IsAnswer:
+			verdict = VerdictMissing
+		default:
+			verdict = VerdictBlank
+		}
+		if verdict == VerdictWrong || verdict == VerdictMissing {
+			result.IsCorrect = false
+		}
+		result.Choices = append(result.Choices, ChoiceVerdict{
+			Choice:   choice,
+			Selected: isSelected,
+			Verdict:  verdict,
+		})
+	}
+	return result, nil
+}
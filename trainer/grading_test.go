@@ -0,0 +1,67 @@
+package trainer
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestGradeAnswer(t *testing.T) {
+	choices := []Choice{
+		{Option: "a", Text: "Free-throw", IsAnswer: true},
+		{Option: "b", Text: "7-metre throw"},
+		{Option: "c", Text: "Warning", IsAnswer: true},
+		{Option: "d", Text: "Time-out"},
+	}
+	tests := []struct {
+		name      string
+		selected  []string
+		isCorrect bool
+		verdicts  []Verdict
+	}{
+		{"exactly the answers", []string{"a", "c"}, true, []Verdict{VerdictCorrect, VerdictBlank, VerdictCorrect, VerdictBlank}},
+		{"answers in any order", []string{"c", "a"}, true, []Verdict{VerdictCorrect, VerdictBlank, VerdictCorrect, VerdictBlank}},
+		{"a wrong choice", []string{"a", "b", "c"}, false, []Verdict{VerdictCorrect, VerdictWrong, VerdictCorrect, VerdictBlank}},
+		{"a missing answer", []string{"a"}, false, []Verdict{VerdictCorrect, VerdictBlank, VerdictMissing, VerdictBlank}},
+		{"wrong and missing", []string{"b", "d"}, false, []Verdict{VerdictMissing, VerdictWrong, VerdictMissing, VerdictWrong}},
+		{"nothing selected", nil, false, []Verdict{VerdictMissing, VerdictBlank, VerdictMissing, VerdictBlank}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GradeAnswer(7, choices, tt.selected)
+			if err != nil {
+				t.Fatalf("GradeAnswer() error = %s", err)
+			}
+			if result.QuestionID != 7 {
+				t.Errorf("QuestionID = %d, want 7", result.QuestionID)
+			}
+			if result.IsCorrect != tt.isCorrect {
+				t.Errorf("IsCorrect = %t, want %t", result.IsCorrect, tt.isCorrect)
+			}
+			var verdicts []Verdict
+			for _, choice := range result.Choices {
+				verdicts = append(verdicts, choice.Verdict)
+				selected := slices.Contains(tt.selected, choice.Choice.Option)
+				if choice.Selected != selected || choice.Choice.IsSelected != selected {
+					t.Errorf("choice %s selected = %t, want %t", choice.Choice.Option, choice.Selected, selected)
+				}
+			}
+			if !slices.Equal(verdicts, tt.verdicts) {
+				t.Errorf("verdicts = %q, want %q", verdicts, tt.verdicts)
+			}
+			if options := result.CorrectOptions(); !slices.Equal(options, []string{"a", "c"}) {
+				t.Errorf("CorrectOptions() = %q, want [a c]", options)
+			}
+		})
+	}
+}
+
+func TestGradeAnswerInvalidOption(t *testing.T) {
+	choices := []Choice{{Option: "a", IsAnswer: true}, {Option: "b"}}
+	for _, selected := range [][]string{{"e"}, {"a", "e"}, {""}, {"A"}} {
+		_, err := GradeAnswer(1, choices, selected)
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("GradeAnswer(%q) error = %v, want ErrInvalidOption", selected, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package trainer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+)
+
+// fakeSetRepository has one question set owned by user 1 and counts the changes of sets saved
+type fakeSetRepository struct {
+	Repository
+	set     QuestionSet
+	updates int
+}
+
+func (r *fakeSetRepository) FindQuestionSetByShareCode(_ context.Context, shareCode string) (*QuestionSet, error) {
+	if shareCode != r.set.ShareCode {
+		return nil, ErrQuestionSetNotFound
+	}
+	set := r.set
+	return &set, nil
+}
+
+func (r *fakeSetRepository) UpdateQuestionSet(ctx context.Context, shareCode string, update func(QuestionSet) (QuestionSet, error)) error {
+	set, err := r.FindQuestionSetByShareCode(ctx, shareCode)
+	if err != nil {
+		return err
+	}
+	set.QuestionIDs = slices.Clone(set.QuestionIDs)
+	updated, err := update(*set)
+	if err != nil {
+		return err
+	}
+	r.set = updated
+	r.updates++
+	return nil
+}
+
+func (r *fakeSetRepository) FindQuestionsByIDs(_ context.Context, ids []int) ([]Question, error) {
+	var questions []Question
+	for _, id := range ids {
+		if id < 10 {
+			questions = append(questions, Question{ID: id})
+		}
+	}
+	return questions, nil
+}
+
+func (r *fakeSetRepository) DeleteQuestionSet(_ context.Context, _ int) error {
+	r.updates++
+	return nil
+}
+
+func TestQuestionSetsChangedByOwnerOnly(t *testing.T) {
+	changes := map[string]func(s *QuestionService, ctx context.Context, userID int) error{
+		"update": func(s *QuestionService, ctx context.Context, userID int) error {
+			return s.UpdateQuestionSet(ctx, userID, "abc", "Rule 8 and 9", "")
+		},
+		"remove": func(s *QuestionService, ctx context.Context, userID int) error {
+			return s.RemoveFromQuestionSet(ctx, userID, "abc", 4)
+		},
+		"move": func(s *QuestionService, ctx context.Context, userID int) error {
+			return s.MoveInQuestionSet(ctx, userID, "abc", 5, -1)
+		},
+		"delete": func(s *QuestionService, ctx context.Context, userID int) error {
+			return s.DeleteQuestionSet(ctx, userID, "abc")
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			for userID, wantErr := range map[int]error{1: nil, 2: ErrQuestionSetNotFound} {
+				repository := &fakeSetRepository{set: QuestionSet{ID: 1, ShareCode: "abc", Name: "Rule 8", OwnerID: 1, QuestionIDs: []int{4, 5}}}
+				ctx := account.WithUser(context.Background(), &account.User{ID: userID, Role: account.RoleInstructor})
+				err := change(NewService(repository, nil), ctx, userID)
+				if !errors.Is(err, wantErr) {
+					t.Fatalf("user %d: error = %v, want %v", userID, err, wantErr)
+				}
+				want := 0
+				if wantErr == nil {
+					want = 1
+				}
+				if repository.updates != want {
+					t.Errorf("user %d: %d updates, want %d", userID, repository.updates, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQuestionSetChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(s *QuestionService, ctx context.Context) error
+		want    []int
+		wantErr error
+	}{
+		{"add", func(s *QuestionService, ctx context.Context) error {
+			return s.AddToQuestionSet(ctx, 1, "abc", []int{6, 4})
+		}, []int{4, 5, 6}, nil},
+		{"add an unknown question", func(s *QuestionService, ctx context.Context) error {
+			return s.AddToQuestionSet(ctx, 1, "abc", []int{6, 12})
+		}, []int{4, 5}, ErrQuestionNotFound},
+		{"remove", func(s *QuestionService, ctx context.Context) error { return s.RemoveFromQuestionSet(ctx, 1, "abc", 4) }, []int{5}, nil},
+		{"remove a question not in the set", func(s *QuestionService, ctx context.Context) error { return s.RemoveFromQuestionSet(ctx, 1, "abc", 6) }, []int{4, 5}, ErrQuestionNotFound},
+		{"move up", func(s *QuestionService, ctx context.Context) error { return s.MoveInQuestionSet(ctx, 1, "abc", 5, -1) }, []int{5, 4}, nil},
+		{"move past the start", func(s *QuestionService, ctx context.Context) error { return s.MoveInQuestionSet(ctx, 1, "abc", 5, -5) }, []int{5, 4}, nil},
+		{"move past the end", func(s *QuestionService, ctx context.Context) error { return s.MoveInQuestionSet(ctx, 1, "abc", 5, 3) }, []int{4, 5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeSetRepository{set: QuestionSet{ID: 1, ShareCode: "abc", Name: "Rule 8", OwnerID: 1, QuestionIDs: []int{4, 5}}}
+			ctx := account.WithUser(context.Background(), &account.User{ID: 1, Role: account.RoleInstructor})
+			err := tt.change(NewService(repository, nil), ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(repository.set.QuestionIDs, tt.want) {
+				t.Errorf("questions %v, want %v", repository.set.QuestionIDs, tt.want)
+			}
+		})
+	}
+}
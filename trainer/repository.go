@@ -2,12 +2,24 @@ package trainer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"strings"
+	"time"
 )
 
+// uniqueViolation is the postgres error code raised when a unique constraint is violated
+const uniqueViolation = "23505"
+
+// foreignKeyViolation is the postgres error code raised when a referenced row does not exist
+const foreignKeyViolation = "23503"
+
+// errShareCodeTaken is returned when a new question set draws a share code that is already used
+var errShareCodeTaken = errors.New("share code taken")
+
 type QuestionRepository struct {
 	db *pgxpool.Pool
 }
@@ -59,6 +71,52 @@ func (r *QuestionRepository) GetAllQuestions(ctx context.Context) ([]Question, e
 	return questions, nil
 }
 
+// FindQuestionsByIDs returns the questions with the given ids, in the order of the ids
+func (r *QuestionRepository) FindQuestionsByIDs(ctx context.Context, ids []int) ([]Question, error) {
+	query := fmt.Sprintf(`
+		SELECT q.id, q.text, q.rule_id, q.question_number 
+		FROM unnest($1::bigint[]) WITH ORDINALITY AS ids(id, position) join question q on q.id = ids.id
+		ORDER BY ids.position
+	`)
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	questionEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[QuestionEntity])
+	if err != nil {
+		return nil, err
+	}
+	choiceMap, err := r.FindChoicesByQuestionIds(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	ruleIDs := make([]string, 0, len(questionEntities))
+	for _, questionEntity := range questionEntities {
+		ruleIDs = append(ruleIDs, questionEntity.RuleID)
+	}
+	rulesMap, err := r.FindRuleByIDs(ctx, ruleIDs...)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]Question, 0, len(questionEntities))
+	for _, questionEntity := range questionEntities {
+		separator := "."
+		if questionEntity.RuleID == "SAR" {
+			separator = ""
+		}
+		ruleQuestionNumber := fmt.Sprintf("%s%s%d", questionEntity.RuleID, separator, questionEntity.QuestionNumber)
+		questions = append(questions, Question{
+			ID:                 questionEntity.ID,
+			Text:               questionEntity.Text,
+			Rule:               rulesMap[questionEntity.RuleID],
+			QuestionNumber:     questionEntity.QuestionNumber,
+			RuleQuestionNumber: ruleQuestionNumber,
+			Choices:            choiceMap[questionEntity.ID],
+		})
+	}
+	return questions, nil
+}
+
 func (r *QuestionRepository) GetQuestionByID(ctx context.Context, id int) (*Question, error) {
 	query := fmt.Sprintf("SELECT id, text, rule_id, question_number FROM question WHERE id = $1")
 	rows, err := r.db.Query(ctx, query, id)
@@ -66,6 +124,9 @@ func (r *QuestionRepository) GetQuestionByID(ctx context.Context, id int) (*Ques
 		return nil, err
 	}
 	questionEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[QuestionEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQuestionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +166,7 @@ func (r *QuestionRepository) GetQuestionByID(ctx context.Context, id int) (*Ques
 	}, nil
 }
 
-func (r *QuestionRepository) GetRandomQuestion(ctx context.Context, rules []string) (*Question, error) {
+func (r *QuestionRepository) GetRandomQuestion(ctx context.Context, rules []string, excludeIDs []int) (*Question, error) {
 	if len(rules) == 0 {
 		allRules, err := r.GetAllDistinctRuleIDs(ctx)
 		if err != nil {
@@ -113,12 +174,18 @@ func (r *QuestionRepository) GetRandomQuestion(ctx context.Context, rules []stri
 		}
 		rules = allRules
 	}
-	query := fmt.Sprintf("SELECT id, text, rule_id, question_number FROM question WHERE rule_id = ANY($1) ORDER BY RANDOM() LIMIT 1")
-	rows, err := r.db.Query(ctx, query, rules)
+	if excludeIDs == nil {
+		excludeIDs = []int{}
+	}
+	query := fmt.Sprintf("SELECT id, text, rule_id, question_number FROM question WHERE rule_id = ANY($1) AND NOT id = ANY($2) ORDER BY RANDOM() LIMIT 1")
+	rows, err := r.db.Query(ctx, query, rules, excludeIDs)
 	if err != nil {
 		return nil, err
 	}
 	questionEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[QuestionEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQuestionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -214,6 +281,28 @@ func (r *QuestionRepository) FindRuleByIDs(ctx context.Context, ruleIDs ...strin
 	return rulesMap, nil
 }
 
+// GetAllRules returns all rules in their sort order
+func (r *QuestionRepository) GetAllRules(ctx context.Context) ([]Rule, error) {
+	query := fmt.Sprintf("SELECT * FROM rule ORDER BY sort_order")
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ruleEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[RuleEntity])
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	for _, ruleEntity := range ruleEntities {
+		rules = append(rules, Rule{
+			ID:        ruleEntity.ID,
+			Name:      ruleEntity.Name,
+			SortOrder: ruleEntity.SortOrder,
+		})
+	}
+	return rules, nil
+}
+
 func (r *QuestionRepository) GetAllDistinctRuleIDs(ctx context.Context) ([]string, error) {
 
 	query := fmt.Sprintf("SELECT id FROM rule")
@@ -235,9 +324,19 @@ func (r *QuestionRepository) GetAllDistinctRuleIDs(ctx context.Context) ([]strin
 	return rules, nil
 }
 
+// HighlightStart and HighlightStop delimit the matched search terms in Question.Highlight.
+// They are plain text markers so that the question text can be escaped before the markers are turned into html.
+const (
+	HighlightStart = "[[hl]]"
+	HighlightStop  = "[[/hl]]"
+)
+
 // ListQuestions returns a list of questions
-// supports pagination using the rule sort order and question number of the last question to offset
-func (r *QuestionRepository) ListQuestions(ctx context.Context, ruleIDs []string, search string, lastRuleSortOrder int, lastQuestionNumber int, limit int) ([]Question, error) {
+// supports pagination using a cursor pointing to the last question of the previous page, nil for the first page
+// when a search term is given, the questions are ordered by their relevance to the search term
+// and a highlighted snippet of the matching text is returned for each question.
+// questions whose text is a close trigram match of the search term are returned as well, to tolerate typos
+func (r *QuestionRepository) ListQuestions(ctx context.Context, ruleIDs []string, search string, after *QuestionCursor, limit int) ([]Question, error) {
 	if len(ruleIDs) == 0 {
 		allRules, err := r.GetAllDistinctRuleIDs(ctx)
 		if err != nil {
@@ -245,32 +344,43 @@ func (r *QuestionRepository) ListQuestions(ctx context.Context, ruleIDs []string
 		}
 		ruleIDs = allRules
 	}
+	cursor := QuestionCursor{}
+	if after != nil {
+		cursor = *after
+	}
+	headlineOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", HighlightStart, HighlightStop)
 	query := fmt.Sprintf(`
-		SELECT q.id, q.text, q.rule_id, q.question_number 
-		FROM question q join rule r on q.rule_id = r.id 
-		WHERE r.id = ANY($1) 
-			AND ($2 = '' OR tsv @@ websearch_to_tsquery($2))
-			AND r.sort_order >= $3
-			AND q.question_number > $4
-		ORDER BY r.sort_order, q.question_number 
-		LIMIT $5
+		WITH ranked AS (
+			SELECT q.id, q.text, q.rule_id, q.question_number, r.sort_order,
+				(CASE WHEN $2 = '' THEN 0 ELSE ts_rank(tsv, websearch_to_tsquery($2)) + word_similarity($2, q.text) END)::real AS rank
+			FROM question q join rule r on q.rule_id = r.id 
+			WHERE r.id = ANY($1) 
+				AND ($2 = '' OR tsv @@ websearch_to_tsquery($2) OR $2 <%% q.text)
+		)
+		SELECT id, text, rule_id, question_number, rank,
+			CASE WHEN $2 = '' THEN '' ELSE ts_headline(text, websearch_to_tsquery($2), $8) END AS headline
+		FROM ranked
+		WHERE NOT $3 OR (-rank, sort_order, question_number, id) > (-$4::real, $5, $6, $7)
+		ORDER BY rank DESC, sort_order, question_number, id
+		LIMIT $9
 	`)
-	rows, err := r.db.Query(ctx, query, ruleIDs, strings.TrimSpace(search), lastRuleSortOrder, lastQuestionNumber, limit)
+	rows, err := r.db.Query(ctx, query, ruleIDs, strings.TrimSpace(search), after != nil, cursor.Rank, cursor.SortOrder, cursor.QuestionNumber, cursor.ID, headlineOptions, limit)
 	if err != nil {
 		return nil, err
 	}
-	questionEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[QuestionEntity])
+	searchEntities, err := pgx.CollectRows(rows, pgx.RowToStructByPos[QuestionSearchEntity])
 	if err != nil {
 		return nil, err
 	}
-	questionIds := make([]int, 0, len(questionEntities))
-	for _, questionEntity := range questionEntities {
-		questionIds = append(questionIds, questionEntity.ID)
+	questionIds := make([]int, 0, len(searchEntities))
+	for _, searchEntity := range searchEntities {
+		questionIds = append(questionIds, searchEntity.ID)
 	}
 	choiceMap, err := r.FindChoicesByQuestionIds(ctx, questionIds...)
 	rulesMap, err := r.FindRuleByIDs(ctx, ruleIDs...)
 	var questions []Question
-	for _, questionEntity := range questionEntities {
+	for _, searchEntity := range searchEntities {
+		questionEntity := searchEntity.QuestionEntity
 		separator := "."
 		if questionEntity.RuleID == "SAR" {
 			separator = ""
@@ -283,12 +393,44 @@ func (r *QuestionRepository) ListQuestions(ctx context.Context, ruleIDs []string
 			QuestionNumber:     questionEntity.QuestionNumber,
 			RuleQuestionNumber: ruleQuestionNumber,
 			Choices:            choiceMap[questionEntity.ID],
+			Highlight:          searchEntity.Headline,
+			Rank:               searchEntity.Rank,
 		}
 		questions = append(questions, question)
 	}
 	return questions, nil
 }
 
+// FindSearchCorrections looks up each search term in the vocabulary of the question texts
+// and returns a map of the unknown terms to the most similar known word
+func (r *QuestionRepository) FindSearchCorrections(ctx context.Context, terms []string) (map[string]string, error) {
+	query := fmt.Sprintf(`
+		SELECT t.term, s.word
+		FROM unnest($1::text[]) AS t(term)
+			CROSS JOIN LATERAL (
+				SELECT word FROM search_word
+				WHERE word %% t.term
+				ORDER BY similarity(word, t.term) DESC, word
+				LIMIT 1
+			) s
+		WHERE NOT EXISTS (SELECT 1 FROM search_word WHERE word = t.term)
+	`)
+	rows, err := r.db.Query(ctx, query, terms)
+	if err != nil {
+		return nil, err
+	}
+	corrections := make(map[string]string)
+	for rows.Next() {
+		var term, word string
+		err = rows.Scan(&term, &word)
+		if err != nil {
+			return nil, err
+		}
+		corrections[term] = word
+	}
+	return corrections, rows.Err()
+}
+
 // FindChoicesByQuestionIds finds choices by question ids and returns a map of question id to choices
 func (r *QuestionRepository) FindChoicesByQuestionIds(ctx context.Context, questionIds ...int) (map[int][]Choice, error) {
 	query := fmt.Sprintf("SELECT * FROM choice WHERE question_id = ANY($1) order by option")
@@ -319,7 +461,8 @@ func (r *QuestionRepository) FindChoicesByQuestionIds(ctx context.Context, quest
 	return choiceMap, nil
 }
 
-func (r *QuestionRepository) InsertFeedback(ctx context.Context, feedback Feedback) error {
+// InsertFeedback saves the feedback, returning it with its id and creation time
+func (r *QuestionRepository) InsertFeedback(ctx context.Context, feedback Feedback) (*Feedback, error) {
 	feedbackEntity := FeedbackEntity{
 		Name:           feedback.Name,
 		Email:          feedback.Email,
@@ -327,11 +470,735 @@ func (r *QuestionRepository) InsertFeedback(ctx context.Context, feedback Feedba
 		Text:           feedback.Text,
 		IsAcknowledged: feedback.IsAcknowledged,
 		IsCompleted:    feedback.IsCompleted,
+		QuestionID:     feedback.QuestionID,
+	}
+	if feedback.Category != "" {
+		category := string(feedback.Category)
+		feedbackEntity.Category = &category
+	}
+	query := fmt.Sprintf("INSERT INTO feedback (email, name, topic, text, is_acknowledged, is_completed, question_id, category) VALUES ($1, $2, $3, $4,$5, $6, $7, $8) RETURNING id, created_at")
+	err := r.db.QueryRow(ctx, query, feedbackEntity.Email, feedbackEntity.Name, feedbackEntity.Topic, feedbackEntity.Text, feedbackEntity.IsAcknowledged, feedbackEntity.IsCompleted,
+		feedbackEntity.QuestionID, feedbackEntity.Category).Scan(&feedback.ID, &feedback.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// ListFeedback returns the feedback matching the filter, the latest first
+func (r *QuestionRepository) ListFeedback(ctx context.Context, filter FeedbackFilter) ([]FeedbackEntity, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, email, topic, text, coalesce(is_acknowledged, false), coalesce(is_completed, false), created_at,
+			question_id, category
+		FROM feedback
+		WHERE ($1 = '' OR topic = $1)
+		AND ($2 = ''
+			OR ($2 = 'new' AND NOT coalesce(is_acknowledged, false) AND NOT coalesce(is_completed, false))
+			OR ($2 = 'acknowledged' AND coalesce(is_acknowledged, false) AND NOT coalesce(is_completed, false))
+			OR ($2 = 'completed' AND coalesce(is_completed, false)))
+		AND ($3 = '' OR category = $3)
+		AND ($4 = 0 OR question_id = $4)
+		ORDER BY created_at DESC, id DESC
+	`)
+	rows, err := r.db.Query(ctx, query, filter.Topic, string(filter.Status), string(filter.Category), filter.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[FeedbackEntity])
+}
+
+// CountOpenReports returns the number of reports on each question that are not completed, by question id
+func (r *QuestionRepository) CountOpenReports(ctx context.Context, questionIDs []int) (map[int]int, error) {
+	query := fmt.Sprintf(`
+		SELECT question_id, count(*) FROM feedback
+		WHERE question_id = ANY($1) AND NOT coalesce(is_completed, false)
+		GROUP BY question_id
+	`)
+	rows, err := r.db.Query(ctx, query, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int)
+	var questionID, count int
+	_, err = pgx.ForEachRow(rows, []any{&questionID, &count}, func() error {
+		counts[questionID] = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// FindFeedbackAudits returns the status changes of the feedback, the oldest first
+func (r *QuestionRepository) FindFeedbackAudits(ctx context.Context, feedbackIDs []int) ([]FeedbackAuditEntity, error) {
+	query := fmt.Sprintf(`
+		SELECT a.id, a.feedback_id, a.user_id, u.name, a.field, a.old_value, a.new_value, a.created_at
+		FROM feedback_audit a JOIN "user" u ON u.id = a.user_id
+		WHERE a.feedback_id = ANY($1)
+		ORDER BY a.created_at, a.id
+	`)
+	rows, err := r.db.Query(ctx, query, feedbackIDs)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[FeedbackAuditEntity])
+}
+
+// UpdateFeedbackStatus sets the status fields of the feedback and audits every field the user changed,
+// in a single transaction. It returns the feedback as it was before the update.
+func (r *QuestionRepository) UpdateFeedbackStatus(ctx context.Context, feedbackID int, userID int, isAcknowledged bool, isCompleted bool) (*FeedbackEntity, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		SELECT id, name, email, topic, text, coalesce(is_acknowledged, false), coalesce(is_completed, false), created_at,
+			question_id, category
+		FROM feedback WHERE id = $1 FOR UPDATE
+	`)
+	rows, err := tx.Query(ctx, query, feedbackID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[FeedbackEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrFeedbackNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	query = fmt.Sprintf("UPDATE feedback SET is_acknowledged = $2, is_completed = $3 WHERE id = $1")
+	_, err = tx.Exec(ctx, query, feedbackID, isAcknowledged, isCompleted)
+	if err != nil {
+		return nil, err
+	}
+	changes := []struct {
+		field    string
+		oldValue bool
+		newValue bool
+	}{
+		{"is_acknowledged", previous.IsAcknowledged, isAcknowledged},
+		{"is_completed", previous.IsCompleted, isCompleted},
+	}
+	for _, change := range changes {
+		if change.oldValue == change.newValue {
+			continue
+		}
+		query = fmt.Sprintf("INSERT INTO feedback_audit (feedback_id, user_id, field, old_value, new_value) VALUES ($1, $2, $3, $4, $5)")
+		_, err = tx.Exec(ctx, query, feedbackID, userID, change.field, change.oldValue, change.newValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// SaveAnswer inserts the answer and, when reschedule is given, reschedules the review of its question in the same
+// transaction. The review is locked while it is rescheduled so that concurrent answers are applied one after the other.
+func (r *QuestionRepository) SaveAnswer(ctx context.Context, answer Answer, reschedule func(Review) Review) error {
+	selectedOptions := answer.SelectedOptions
+	if selectedOptions == nil {
+		selectedOptions = []string{}
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf("INSERT INTO answer (user_id, question_id, selected_options, is_correct, source) VALUES ($1, $2, $3, $4, $5)")
+	_, err = tx.Exec(ctx, query, answer.UserID, answer.QuestionID, selectedOptions, answer.IsCorrect, answer.Source)
+	if err != nil {
+		return err
+	}
+	if reschedule == nil {
+		return tx.Commit(ctx)
+	}
+
+	// the review is created first when the question was never answered so that there is a row to lock
+	err = saveReview(ctx, tx, newReview(answer.UserID, answer.QuestionID), "ON CONFLICT DO NOTHING")
+	if err != nil {
+		return err
+	}
+	review, err := findReview(ctx, tx, answer.UserID, answer.QuestionID, "FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	err = saveReview(ctx, tx, reschedule(*review), `
+		ON CONFLICT (user_id, question_id) DO UPDATE SET
+			ease = excluded.ease, interval_days = excluded.interval_days, repetitions = excluded.repetitions,
+			due_at = excluded.due_at, reviewed_at = excluded.reviewed_at
+	`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetPracticeStats returns the answer history of the user for every question of the rules, all rules when none is given.
+// Selections on the home page are left out as they are saved while the user is still choosing.
+func (r *QuestionRepository) GetPracticeStats(ctx context.Context, userID int, rules []string) ([]PracticeStatEntity, error) {
+	if rules == nil {
+		rules = []string{}
+	}
+	query := fmt.Sprintf(`
+		SELECT q.id, q.rule_id, count(a.id), count(a.id) FILTER (WHERE NOT a.is_correct), max(a.created_at),
+			(array_agg(a.is_correct ORDER BY a.created_at DESC, a.id DESC) FILTER (WHERE a.id IS NOT NULL))[1]
+		FROM question q LEFT JOIN answer a ON a.question_id = q.id AND a.user_id = $1 AND a.source <> 'home'
+		WHERE cardinality($2::text[]) = 0 OR q.rule_id = ANY($2)
+		GROUP BY q.id
+	`)
+	rows, err := r.db.Query(ctx, query, userID, rules)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[PracticeStatEntity])
+}
+
+// GetRuleProgress returns for every rule how many of its questions the user read, answered, and answered correctly
+// the latest time, in the order of the rules
+func (r *QuestionRepository) GetRuleProgress(ctx context.Context, userID int) ([]RuleProgressEntity, error) {
+	query := fmt.Sprintf(`
+		WITH latest AS (
+			SELECT DISTINCT ON (question_id) question_id, is_correct
+			FROM answer WHERE user_id = $1
+			ORDER BY question_id, created_at DESC, id DESC
+		)
+		SELECT r.id, r.name, r.sort_order, count(q.id), count(qr.question_id), count(l.question_id),
+			count(l.question_id) FILTER (WHERE l.is_correct)
+		FROM rule r
+			JOIN question q ON q.rule_id = r.id
+			LEFT JOIN question_read qr ON qr.question_id = q.id AND qr.user_id = $1
+			LEFT JOIN latest l ON l.question_id = q.id
+		GROUP BY r.id
+		ORDER BY r.sort_order
+	`)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[RuleProgressEntity])
+}
+
+// GetWeeklyAccuracy returns the number of answers and correct answers of the user by rule and week,
+// for the current week and the weeks before it. Weeks start on Monday in UTC.
+func (r *QuestionRepository) GetWeeklyAccuracy(ctx context.Context, userID int, weeks int) ([]AccuracyEntity, error) {
+	query := fmt.Sprintf(`
+		SELECT q.rule_id, date_trunc('week', a.created_at, 'UTC') AS week_start, count(*), count(*) FILTER (WHERE a.is_correct)
+		FROM answer a JOIN question q ON a.question_id = q.id
+		WHERE a.user_id = $1 AND a.created_at >= date_trunc('week', now(), 'UTC') - make_interval(weeks => $2 - 1)
+		GROUP BY q.rule_id, week_start
+		ORDER BY week_start
+	`)
+	rows, err := r.db.Query(ctx, query, userID, weeks)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[AccuracyEntity])
+}
+
+// findReview returns the spaced repetition schedule of a question for a user,
+// ErrReviewNotFound if the user never answered the question. lock is appended to the query to lock the row.
+func findReview(ctx context.Context, db queryer, userID int, questionID int, lock string) (*Review, error) {
+	query := fmt.Sprintf(`
+		SELECT user_id, question_id, ease, interval_days, repetitions, due_at, reviewed_at
+		FROM review WHERE user_id = $1 AND question_id = $2 %s
+	`, lock)
+	rows, err := db.Query(ctx, query, userID, questionID)
+	if err != nil {
+		return nil, err
+	}
+	reviewEntity, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[ReviewEntity])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Review{
+		UserID:       reviewEntity.UserID,
+		QuestionID:   reviewEntity.QuestionID,
+		Ease:         reviewEntity.Ease,
+		IntervalDays: reviewEntity.IntervalDays,
+		Repetitions:  reviewEntity.Repetitions,
+		DueAt:        reviewEntity.DueAt,
+		ReviewedAt:   reviewEntity.ReviewedAt,
+	}, nil
+}
+
+// saveReview inserts the review, onConflict is appended to the query to update or keep an existing review
+func saveReview(ctx context.Context, tx pgx.Tx, review Review, onConflict string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO review (user_id, question_id, ease, interval_days, repetitions, due_at, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) %s
+	`, onConflict)
+	_, err := tx.Exec(ctx, query, review.UserID, review.QuestionID, review.Ease, review.IntervalDays,
+		review.Repetitions, review.DueAt, review.ReviewedAt)
+	return err
+}
+
+// FindNextReviewQuestionID returns the question the user should review next: the question that has been due
+// the longest, or a random question the user never answered when none is due. ErrNoReviewDue if there is neither.
+// It also returns whether the question is new and how many questions are due.
+func (r *QuestionRepository) FindNextReviewQuestionID(ctx context.Context, userID int) (int, bool, int, error) {
+	query := fmt.Sprintf(`
+		SELECT q.id, rv.question_id IS NULL AS is_new, count(rv.question_id) OVER () AS due_count
+		FROM question q LEFT JOIN review rv ON rv.question_id = q.id AND rv.user_id = $1
+		WHERE rv.question_id IS NULL OR rv.due_at <= now()
+		ORDER BY rv.due_at NULLS LAST, RANDOM()
+		LIMIT 1
+	`)
+	var questionID, dueCount int
+	var isNew bool
+	err := r.db.QueryRow(ctx, query, userID).Scan(&questionID, &isNew, &dueCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, 0, ErrNoReviewDue
+	}
+	if err != nil {
+		return 0, false, 0, err
+	}
+	return questionID, isNew, dueCount, nil
+}
+
+// GetProgress returns the latest selections since the user last cleared them and the questions marked as read
+func (r *QuestionRepository) GetProgress(ctx context.Context, userID int) (*Progress, error) {
+	progress := &Progress{
+		Selections: make(map[int][]string),
+		Read:       make(map[int]bool),
+	}
+	query := fmt.Sprintf(`
+		SELECT DISTINCT ON (a.question_id) a.question_id, a.selected_options
+		FROM answer a LEFT JOIN progress p ON a.user_id = p.user_id
+		WHERE a.user_id = $1 AND a.source = 'home' AND (p.cleared_at IS NULL OR a.created_at > p.cleared_at)
+		ORDER BY a.question_id, a.created_at DESC, a.id DESC
+	`)
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var questionID int
+		var selectedOptions []string
+		err = rows.Scan(&questionID, &selectedOptions)
+		if err != nil {
+			return nil, err
+		}
+		progress.Selections[questionID] = selectedOptions
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	query = fmt.Sprintf("SELECT question_id FROM question_read WHERE user_id = $1")
+	rows, err = r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var questionID int
+		err = rows.Scan(&questionID)
+		if err != nil {
+			return nil, err
+		}
+		progress.Read[questionID] = true
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	query = fmt.Sprintf("SELECT imported_at IS NOT NULL FROM progress WHERE user_id = $1")
+	err = r.db.QueryRow(ctx, query, userID).Scan(&progress.IsImported)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return progress, nil
+}
+
+func (r *QuestionRepository) SetQuestionRead(ctx context.Context, userID int, questionID int, read bool) error {
+	query := fmt.Sprintf("DELETE FROM question_read WHERE user_id = $1 AND question_id = $2")
+	if read {
+		query = fmt.Sprintf("INSERT INTO question_read (user_id, question_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")
+	}
+	_, err := r.db.Exec(ctx, query, userID, questionID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return ErrQuestionNotFound
+	}
+	return err
+}
+
+// ClearSelections hides every answer given until now from the selections of the user, the answers are kept for the history
+func (r *QuestionRepository) ClearSelections(ctx context.Context, userID int) error {
+	query := fmt.Sprintf(`
+		INSERT INTO progress (user_id, cleared_at) VALUES ($1, now())
+		ON CONFLICT (user_id) DO UPDATE SET cleared_at = now()
+	`)
+	_, err := r.db.Exec(ctx, query, userID)
+	return err
+}
+
+// ImportProgress saves the answers and read marks imported from the browser in a single transaction.
+// It returns false without saving anything if the progress of the user was already imported.
+func (r *QuestionRepository) ImportProgress(ctx context.Context, userID int, answers []Answer, readQuestionIDs []int) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		INSERT INTO progress (user_id, imported_at) VALUES ($1, now())
+		ON CONFLICT (user_id) DO UPDATE SET imported_at = now() WHERE progress.imported_at IS NULL
+	`)
+	tag, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	for _, answer := range answers {
+		query = fmt.Sprintf("INSERT INTO answer (user_id, question_id, selected_options, is_correct, source) VALUES ($1, $2, $3, $4, $5)")
+		_, err = tx.Exec(ctx, query, userID, answer.QuestionID, answer.SelectedOptions, answer.IsCorrect, answer.Source)
+		if err != nil {
+			return false, err
+		}
+	}
+	query = fmt.Sprintf("INSERT INTO question_read (user_id, question_id) SELECT $1, unnest($2::bigint[]) ON CONFLICT DO NOTHING")
+	_, err = tx.Exec(ctx, query, userID, readQuestionIDs)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// InsertQuestionSet creates a question set without any question and returns its id
+func (r *QuestionRepository) InsertQuestionSet(ctx context.Context, set QuestionSet) (int, error) {
+	query := fmt.Sprintf("INSERT INTO question_set (name, description, owner_id, share_code) VALUES ($1, $2, $3, $4) RETURNING id")
+	var id int
+	err := r.db.QueryRow(ctx, query, set.Name, set.Description, set.OwnerID, set.ShareCode).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return 0, errShareCodeTaken
+	}
+	return id, err
+}
+
+func (r *QuestionRepository) FindQuestionSetByShareCode(ctx context.Context, shareCode string) (*QuestionSet, error) {
+	return findQuestionSet(ctx, r.db, shareCode)
+}
+
+func findQuestionSet(ctx context.Context, db queryer, shareCode string) (*QuestionSet, error) {
+	query := fmt.Sprintf(`
+		SELECT s.id, s.name, s.description, s.owner_id, s.share_code, s.created_at, s.updated_at,
+			coalesce(array_agg(sq.question_id ORDER BY sq.position) FILTER (WHERE sq.question_id IS NOT NULL), '{}')
+		FROM question_set s LEFT JOIN question_set_question sq ON sq.question_set_id = s.id
+		WHERE s.share_code = $1
+		GROUP BY s.id
+	`)
+	rows, err := db.Query(ctx, query, shareCode)
+	if err != nil {
+		return nil, err
+	}
+	set, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[QuestionSet])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQuestionSetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ListQuestionSetsByOwnerID returns the question sets of the user, the last updated first
+func (r *QuestionRepository) ListQuestionSetsByOwnerID(ctx context.Context, ownerID int) ([]QuestionSet, error) {
+	query := fmt.Sprintf(`
+		SELECT s.id, s.name, s.description, s.owner_id, s.share_code, s.created_at, s.updated_at,
+			coalesce(array_agg(sq.question_id ORDER BY sq.position) FILTER (WHERE sq.question_id IS NOT NULL), '{}')
+		FROM question_set s LEFT JOIN question_set_question sq ON sq.question_set_id = s.id
+		WHERE s.owner_id = $1
+		GROUP BY s.id
+		ORDER BY s.updated_at DESC
+	`)
+	rows, err := r.db.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[QuestionSet])
+}
+
+// UpdateQuestionSet applies update to the question set of the share code and saves its name, description and
+// questions in their order, in a single transaction. The set is locked while it is updated so that concurrent
+// changes are applied one after the other, an error returned by update is returned without saving anything.
+func (r *QuestionRepository) UpdateQuestionSet(ctx context.Context, shareCode string, update func(QuestionSet) (QuestionSet, error)) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf("SELECT id FROM question_set WHERE share_code = $1 FOR UPDATE")
+	_, err = tx.Exec(ctx, query, shareCode)
+	if err != nil {
+		return err
+	}
+	current, err := findQuestionSet(ctx, tx, shareCode)
+	if err != nil {
+		return err
+	}
+	set, err := update(*current)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf("UPDATE question_set SET name = $2, description = $3, updated_at = now() WHERE id = $1")
+	_, err = tx.Exec(ctx, query, current.ID, set.Name, set.Description)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf("DELETE FROM question_set_question WHERE question_set_id = $1")
+	_, err = tx.Exec(ctx, query, current.ID)
+	if err != nil {
+		return err
+	}
+	questionIDs := set.QuestionIDs
+	if questionIDs == nil {
+		questionIDs = []int{}
+	}
+	query = fmt.Sprintf(`
+		INSERT INTO question_set_question (question_set_id, question_id, position)
+		SELECT $1, ids.id, ids.position FROM unnest($2::bigint[]) WITH ORDINALITY AS ids(id, position)
+	`)
+	_, err = tx.Exec(ctx, query, current.ID, questionIDs)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *QuestionRepository) DeleteQuestionSet(ctx context.Context, id int) error {
+	query := fmt.Sprintf("DELETE FROM question_set WHERE id = $1")
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// UseExamTicket saves the ticket as used, returning false if it was used already. The tickets used before
+// expiredBefore are forgotten, they have expired and are refused anyway.
+func (r *QuestionRepository) UseExamTicket(ctx context.Context, ticketID string, expiredBefore time.Time) (bool, error) {
+	query := fmt.Sprintf("DELETE FROM used_exam_ticket WHERE used_at < $1")
+	_, err := r.db.Exec(ctx, query, expiredBefore)
+	if err != nil {
+		return false, err
+	}
+	query = fmt.Sprintf("INSERT INTO used_exam_ticket (id) VALUES ($1) ON CONFLICT DO NOTHING")
+	tag, err := r.db.Exec(ctx, query, ticketID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// queryer runs queries on the pool or within a transaction
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// FindQuestionContent returns the text, choices and references of the question
+func (r *QuestionRepository) FindQuestionContent(ctx context.Context, questionID int) (*QuestionContent, error) {
+	return findQuestionContent(ctx, r.db, questionID)
+}
+
+func findQuestionContent(ctx context.Context, db queryer, questionID int) (*QuestionContent, error) {
+	query := fmt.Sprintf("SELECT text FROM question WHERE id = $1")
+	rows, err := db.Query(ctx, query, questionID)
+	if err != nil {
+		return nil, err
+	}
+	text, err := pgx.CollectOneRow(rows, pgx.RowTo[string])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQuestionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	query = fmt.Sprintf("SELECT option, text, is_answer FROM choice WHERE question_id = $1 ORDER BY option")
+	rows, err = db.Query(ctx, query, questionID)
+	if err != nil {
+		return nil, err
+	}
+	choices, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ChoiceContent])
+	if err != nil {
+		return nil, err
+	}
+	query = fmt.Sprintf("SELECT text FROM reference WHERE question_id = $1 ORDER BY id")
+	rows, err = db.Query(ctx, query, questionID)
+	if err != nil {
+		return nil, err
+	}
+	references, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	return &QuestionContent{Text: text, Choices: choices, References: references}, nil
+}
+
+// ListQuestionRevisions returns the revisions of the question with the names of their authors, the latest first
+func (r *QuestionRepository) ListQuestionRevisions(ctx context.Context, questionID int) ([]QuestionRevision, error) {
+	query := fmt.Sprintf(`
+		SELECT r.id, r.question_id, r.user_id, u.name, r.before, r.after, r.is_answer_changed, r.reverted_revision_id, r.created_at
+		FROM question_revision r JOIN "user" u ON u.id = r.user_id
+		WHERE r.question_id = $1
+		ORDER BY r.id DESC
+	`)
+	rows, err := r.db.Query(ctx, query, questionID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[QuestionRevision])
+}
+
+func (r *QuestionRepository) FindQuestionRevision(ctx context.Context, id int) (*QuestionRevision, error) {
+	query := fmt.Sprintf(`
+		SELECT r.id, r.question_id, r.user_id, u.name, r.before, r.after, r.is_answer_changed, r.reverted_revision_id, r.created_at
+		FROM question_revision r JOIN "user" u ON u.id = r.user_id
+		WHERE r.id = $1
+	`)
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := pgx.CollectOneRow(rows, pgx.RowToStructByPos[QuestionRevision])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// FindAnswerCorrections returns when the answers of the questions were last corrected, by question id.
+// Questions whose answers were never corrected are left out.
+func (r *QuestionRepository) FindAnswerCorrections(ctx context.Context, questionIDs []int) (map[int]time.Time, error) {
+	query := fmt.Sprintf(`
+		SELECT question_id, max(created_at) FROM question_revision
+		WHERE question_id = ANY($1) AND is_answer_changed
+		GROUP BY question_id
+	`)
+	rows, err := r.db.Query(ctx, query, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	corrections := make(map[int]time.Time)
+	var questionID int
+	var correctedAt time.Time
+	_, err = pgx.ForEachRow(rows, []any{&questionID, &correctedAt}, func() error {
+		corrections[questionID] = correctedAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// UpdateQuestionContent replaces the text, choices and references of the question and saves the revision of the change,
+// in a single transaction. Choices keep their id when their option stays. ErrQuestionEditConflict when the latest
+// revision of the question is not latestRevisionID, an edit that changes nothing is not saved.
+func (r *QuestionRepository) UpdateQuestionContent(ctx context.Context, questionID int, userID int, content QuestionContent, latestRevisionID int, revertedRevisionID *int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf("SELECT id FROM question WHERE id = $1 FOR UPDATE")
+	_, err = tx.Exec(ctx, query, questionID)
+	if err != nil {
+		return err
+	}
+	before, err := findQuestionContent(ctx, tx, questionID)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf("SELECT coalesce(max(id), 0) FROM question_revision WHERE question_id = $1")
+	var revisionID int
+	err = tx.QueryRow(ctx, query, questionID).Scan(&revisionID)
+	if err != nil {
+		return err
+	}
+	if revisionID != latestRevisionID {
+		return ErrQuestionEditConflict
+	}
+	if before.Equal(content) {
+		return nil
+	}
+
+	query = fmt.Sprintf("UPDATE question SET text = $2, tsv = setweight(to_tsvector($2), 'A') WHERE id = $1")
+	_, err = tx.Exec(ctx, query, questionID, content.Text)
+	if err != nil {
+		return err
+	}
+	err = WriteQuestionContent(ctx, tx, questionID, content)
+	if err != nil {
+		return err
+	}
+	// new words of the text are offered as search corrections
+	query = fmt.Sprintf("INSERT INTO search_word SELECT unnest(tsvector_to_array(to_tsvector('simple', $1))) ON CONFLICT DO NOTHING")
+	_, err = tx.Exec(ctx, query, content.Text)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf(`
+		INSERT INTO question_revision (question_id, user_id, before, after, is_answer_changed, reverted_revision_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`)
+	_, err = tx.Exec(ctx, query, questionID, userID, before, content, IsAnswerChanged(*before, content), revertedRevisionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// WriteQuestionContent replaces the choices and references of the question, choices keep their id when their option
+// stays so that the answers saved for them still refer to them
+func WriteQuestionContent(ctx context.Context, tx pgx.Tx, questionID int, content QuestionContent) error {
+	options := make([]string, 0, len(content.Choices))
+	for _, choice := range content.Choices {
+		options = append(options, choice.Option)
+	}
+	query := fmt.Sprintf("DELETE FROM choice WHERE question_id = $1 AND NOT option = ANY($2)")
+	_, err := tx.Exec(ctx, query, questionID, options)
+	if err != nil {
+		return err
+	}
+	for _, choice := range content.Choices {
+		query = fmt.Sprintf("UPDATE choice SET text = $3, is_answer = $4 WHERE question_id = $1 AND option = $2")
+		tag, err := tx.Exec(ctx, query, questionID, choice.Option, choice.Text, choice.IsAnswer)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() > 0 {
+			continue
+		}
+		query = fmt.Sprintf("INSERT INTO choice (question_id, option, text, is_answer) VALUES ($1, $2, $3, $4)")
+		_, err = tx.Exec(ctx, query, questionID, choice.Option, choice.Text, choice.IsAnswer)
+		if err != nil {
+			return err
+		}
 	}
-	query := fmt.Sprintf("INSERT INTO feedback (email, name, topic, text, is_acknowledged, is_completed) VALUES ($1, $2, $3, $4,$5, $6)")
-	_, err := r.db.Exec(ctx, query, feedbackEntity.Email, feedbackEntity.Name, feedbackEntity.Topic, feedbackEntity.Text, feedbackEntity.IsAcknowledged, feedbackEntity.IsCompleted)
+	query = fmt.Sprintf("DELETE FROM reference WHERE question_id = $1")
+	_, err = tx.Exec(ctx, query, questionID)
 	if err != nil {
 		return err
 	}
-	return nil
+	query = fmt.Sprintf("INSERT INTO reference (question_id, text) SELECT $1, unnest($2::text[])")
+	_, err = tx.Exec(ctx, query, questionID, content.References)
+	return err
 }
@@ -0,0 +1,64 @@
+package trainer
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	initialEase = 2.5
+	minEase     = 1.3
+	// passingQuality is the lowest quality of an answer that keeps the question on its schedule
+	passingQuality = 3
+)
+
+// newReview returns the schedule of a question that has never been answered
+func newReview(userID int, questionID int) Review {
+	return Review{
+		UserID:     userID,
+		QuestionID: questionID,
+		Ease:       initialEase,
+	}
+}
+
+// answerQuality rates an answer on the SM-2 scale of 0 to 5. A correct answer is rated 4,
+// a wrong answer is rated 2 when it earns at least half of the point under partial scoring and 1 otherwise.
+func answerQuality(result *AnswerResult) int {
+	switch {
+	case result.IsCorrect:
+		return 4
+	case ScoringPartial.Score(result) >= 0.5:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// next schedules the review of the question after an answer of the given quality, following SM-2.
+// A correct answer before the question is due does not move the schedule forward, so answering a question
+// again and again on the same day does not push it months away. A wrong answer always starts over.
+func (r Review) next(quality int, now time.Time) Review {
+	if quality >= passingQuality && r.Repetitions > 0 && now.Before(r.DueAt) {
+		r.ReviewedAt = now
+		return r
+	}
+	if quality >= passingQuality {
+		switch r.Repetitions {
+		case 0:
+			r.IntervalDays = 1
+		case 1:
+			r.IntervalDays = 6
+		default:
+			r.IntervalDays = int(math.Round(float64(r.IntervalDays) * r.Ease))
+		}
+		r.Repetitions++
+	} else {
+		r.Repetitions = 0
+		r.IntervalDays = 1
+	}
+	missing := float64(5 - quality)
+	r.Ease = math.Max(minEase, r.Ease+0.1-missing*(0.08+missing*0.02))
+	r.DueAt = now.AddDate(0, 0, r.IntervalDays)
+	r.ReviewedAt = now
+	return r
+}
@@ -0,0 +1,54 @@
+package trainer
+
+import (
+	"context"
+	"testing"
+)
+
+// fakeAnswerRepository applies the reschedule of the saved answers to the review it holds
+type fakeAnswerRepository struct {
+	Repository
+	answers []Answer
+	review  Review
+}
+
+func (r *fakeAnswerRepository) SaveAnswer(_ context.Context, answer Answer, reschedule func(Review) Review) error {
+	r.answers = append(r.answers, answer)
+	if reschedule != nil {
+		r.review = reschedule(r.review)
+	}
+	return nil
+}
+
+func TestSaveAnswerReschedulesBySource(t *testing.T) {
+	choices := []Choice{{Option: "a", IsAnswer: true}, {Option: "b"}}
+	result, err := GradeAnswer(1, choices, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source      AnswerSource
+		reschedules bool
+	}{
+		{AnswerSourceHome, false},
+		{AnswerSourcePractice, true},
+		{AnswerSourceReview, true},
+		{AnswerSourceExam, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.source), func(t *testing.T) {
+			repository := &fakeAnswerRepository{review: newReview(7, 1)}
+			service := NewService(repository, nil)
+			err := service.saveAnswer(context.Background(), Answer{UserID: 7, QuestionID: 1, IsCorrect: true, Source: tt.source}, result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(repository.answers) != 1 || repository.answers[0].Source != tt.source {
+				t.Fatalf("saved answers = %+v, want one answer from %s", repository.answers, tt.source)
+			}
+			if rescheduled := repository.review.Repetitions > 0; rescheduled != tt.reschedules {
+				t.Errorf("rescheduled = %t, want %t", rescheduled, tt.reschedules)
+			}
+		})
+	}
+}
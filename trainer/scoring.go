@@ -0,0 +1,83 @@
+package trainer
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrInvalidScoring = errors.New("invalid scoring method")
+
+// Scoring is how an answered question is turned into points, every question is worth at most 1 point
+type Scoring string
+
+const (
+	// ScoringExact gives the point only when exactly the answers were selected
+	ScoringExact Scoring = "exact"
+	// ScoringPartial gives a share of the point for every selected answer out of the answers and the selected choices,
+	// so that a wrong choice costs as much as a missing answer and the choices left alone do not count
+	ScoringPartial Scoring = "partial"
+	// ScoringNegative gives a share of the point for every selected answer and takes one away for every selected
+	// choice that is not an answer, a question never scores below 0
+	ScoringNegative Scoring = "negative"
+)
+
+// ParseScoring returns the scoring method of its name, ErrInvalidScoring if it is unknown
+func ParseScoring(name string) (Scoring, error) {
+	switch scoring := Scoring(name); scoring {
+	case ScoringExact, ScoringPartial, ScoringNegative:
+		return scoring, nil
+	default:
+		return "", ErrInvalidScoring
+	}
+}
+
+// Description explains the scoring method to the user
+func (s Scoring) Description() string {
+	switch s {
+	case ScoringPartial:
+		return "Partial credit for every correct choice selected, a wrong choice counts as a missed one"
+	case ScoringNegative:
+		return "Credit for every correct choice, deducted for every wrong choice"
+	default:
+		return "A point only when exactly the correct choices are selected"
+	}
+}
+
+// Score returns the points between 0 and 1 earned by the answer under the scoring method
+func (s Scoring) Score(result *AnswerResult) float64 {
+	var correct, wrong, missing int
+	for _, choice := range result.Choices {
+		switch choice.Verdict {
+		case VerdictCorrect:
+			correct++
+		case VerdictWrong:
+			wrong++
+		case VerdictMissing:
+			missing++
+		}
+	}
+
+	switch s {
+	case ScoringPartial:
+		judged := correct + wrong + missing
+		if judged == 0 {
+			return s.exact(result)
+		}
+		return float64(correct) / float64(judged)
+	case ScoringNegative:
+		answers := correct + missing
+		if answers == 0 {
+			return s.exact(result)
+		}
+		return math.Max(0, float64(correct-wrong)/float64(answers))
+	default:
+		return s.exact(result)
+	}
+}
+
+func (s Scoring) exact(result *AnswerResult) float64 {
+	if result.IsCorrect {
+		return 1
+	}
+	return 0
+}
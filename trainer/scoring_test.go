@@ -0,0 +1,78 @@
+package trainer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoringScore(t *testing.T) {
+	// a and c are the answers of the question
+	choices := []Choice{
+		{Option: "a", IsAnswer: true},
+		{Option: "b"},
+		{Option: "c", IsAnswer: true},
+		{Option: "d"},
+		{Option: "e"},
+	}
+	tests := []struct {
+		name     string
+		selected []string
+		exact    float64
+		partial  float64
+		negative float64
+	}{
+		{"exactly the answers", []string{"a", "c"}, 1, 1, 1},
+		{"one answer of two", []string{"a"}, 0, 0.5, 0.5},
+		{"answers and a wrong choice", []string{"a", "b", "c"}, 0, 2.0 / 3, 0.5},
+		{"an answer and a wrong choice", []string{"a", "b"}, 0, 1.0 / 3, 0},
+		{"more wrong than right", []string{"a", "b", "d"}, 0, 0.25, 0},
+		{"only wrong choices", []string{"b", "d", "e"}, 0, 0, 0},
+		{"nothing selected", nil, 0, 0, 0},
+		{"everything selected", []string{"a", "b", "c", "d", "e"}, 0, 0.4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GradeAnswer(1, choices, tt.selected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for scoring, want := range map[Scoring]float64{
+				ScoringExact:    tt.exact,
+				ScoringPartial:  tt.partial,
+				ScoringNegative: tt.negative,
+			} {
+				if got := scoring.Score(result); math.Abs(got-want) > 1e-9 {
+					t.Errorf("%s Score() = %v, want %v", scoring, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScoringScoreWithoutAnswers(t *testing.T) {
+	// a question without any answer is only right when nothing is selected
+	choices := []Choice{{Option: "a"}, {Option: "b"}}
+	for _, scoring := range []Scoring{ScoringExact, ScoringPartial, ScoringNegative} {
+		blank, _ := GradeAnswer(1, choices, nil)
+		if got := scoring.Score(blank); got != 1 {
+			t.Errorf("%s Score() with nothing selected = %v, want 1", scoring, got)
+		}
+		wrong, _ := GradeAnswer(1, choices, []string{"a"})
+		if got := scoring.Score(wrong); got != 0 {
+			t.Errorf("%s Score() with a wrong choice = %v, want 0", scoring, got)
+		}
+	}
+}
+
+func TestParseScoring(t *testing.T) {
+	for _, name := range []string{"exact", "partial", "negative"} {
+		scoring, err := ParseScoring(name)
+		if err != nil || string(scoring) != name {
+			t.Errorf("ParseScoring(%q) = %q, %v", name, scoring, err)
+		}
+	}
+	_, err := ParseScoring("")
+	if err != ErrInvalidScoring {
+		t.Errorf("ParseScoring(\"\") error = %v, want ErrInvalidScoring", err)
+	}
+}
@@ -0,0 +1,80 @@
+package trainer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// searchSynonym rewrites a colloquial handball term into the wording used by the IHF questions
+type searchSynonym struct {
+	pattern   *regexp.Regexp
+	canonical string
+}
+
+// searchSynonyms is the handball synonym dictionary applied to search terms before they reach the database.
+// The patterns are matched case-insensitively on word boundaries, in order.
+var searchSynonyms = []searchSynonym{
+	{regexp.MustCompile(`(?i)\b(7|seven)[ -]?(m|meters?|metres?)\b( throws?)?`), "7-metre throw"},
+	{regexp.MustCompile(`(?i)\b(9|nine)[ -]?(m|meters?|metres?)( line)?\b`), "free-throw line"},
+	{regexp.MustCompile(`(?i)\b(6|six)[ -]?(m|meters?|metres?)( line)?\b`), "goal-area line"},
+	{regexp.MustCompile(`(?i)\b(2|two)[ -]?(min|mins|minutes?)\b( suspensions?)?`), "2-minute suspension"},
+	{regexp.MustCompile(`(?i)\b(gk|goalie|keeper)\b`), "goalkeeper"},
+	{regexp.MustCompile(`(?i)\bsar\b`), "substitution area"},
+	{regexp.MustCompile(`(?i)\btime ?outs?\b`), "time-out"},
+	{regexp.MustCompile(`(?i)\byellow cards?\b`), "warning"},
+	{regexp.MustCompile(`(?i)\bred cards?\b`), "disqualification"},
+	{regexp.MustCompile(`(?i)\bblue cards?\b`), "disqualification with written report"},
+	{regexp.MustCompile(`(?i)\bpassive\b( play)?`), "passive play"},
+}
+
+// normalizeSearch trims the search term and replaces the synonyms with their canonical IHF wording
+func normalizeSearch(search string) string {
+	search = strings.Join(strings.Fields(search), " ")
+	for _, synonym := range searchSynonyms {
+		search = synonym.pattern.ReplaceAllString(search, synonym.canonical)
+	}
+	return search
+}
+
+// searchTerms splits a search into the lower case words to be spell checked,
+// dropping the websearch operators understood by postgres
+func searchTerms(search string) []string {
+	var terms []string
+	for _, field := range strings.Fields(search) {
+		term, _ := searchTerm(field)
+		if term == "" || term == "or" {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// searchTerm returns the lower case word of a search field without its surrounding punctuation and operators,
+// and the position of the word in the field
+func searchTerm(field string) (string, int) {
+	const punctuation = `"'.,;:!?()`
+	trimmed := strings.TrimLeft(field, punctuation)
+	start := len(field) - len(trimmed)
+	word := strings.TrimRight(trimmed, punctuation)
+	if strings.HasPrefix(word, "-") {
+		word = word[1:]
+		start++
+	}
+	return strings.ToLower(word), start
+}
+
+// replaceTerms rebuilds the search with each misspelt term replaced by its correction,
+// keeping the rest of the search as the user typed it. Only whole words are replaced.
+func replaceTerms(search string, corrections map[string]string) string {
+	fields := strings.Fields(search)
+	for i, field := range fields {
+		term, start := searchTerm(field)
+		correction, ok := corrections[term]
+		if !ok || term == "" {
+			continue
+		}
+		fields[i] = field[:start] + correction + field[start+len(term):]
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,45 @@
+package trainer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms(`"Goalkeper" OR -pasive, (throw)`)
+	want := []string{"goalkeper", "pasive", "throw"}
+	if !slices.Equal(got, want) {
+		t.Errorf("searchTerms() = %q, want %q", got, want)
+	}
+}
+
+func TestReplaceTerms(t *testing.T) {
+	tests := []struct {
+		name        string
+		search      string
+		corrections map[string]string
+		want        string
+	}{
+		{"replaces a misspelt word", "goalkeper throw", map[string]string{"goalkeper": "goalkeeper"}, "goalkeeper throw"},
+		{"keeps punctuation and operators", `"goalkeper" -pasive,`, map[string]string{"goalkeper": "goalkeeper", "pasive": "passive"}, `"goalkeeper" -passive,`},
+		{"matches case-insensitively", "Goalkeper", map[string]string{"goalkeper": "goalkeeper"}, "goalkeeper"},
+		{"does not replace inside other words", "throwing throw", map[string]string{"throw": "thrown"}, "throwing thrown"},
+		{
+			"is independent of the order of the corrections",
+			"pas pass passs",
+			map[string]string{"pas": "pass", "passs": "pass", "as": "at", "ss": "s"},
+			"pass pass pass",
+		},
+		{"leaves the search alone without corrections", "red card", nil, "red card"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				got := replaceTerms(tt.search, tt.corrections)
+				if got != tt.want {
+					t.Fatalf("replaceTerms(%q) = %q, want %q", tt.search, got, tt.want)
+				}
+			}
+		})
+	}
+}
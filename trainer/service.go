@@ -1,44 +1,922 @@
 package trainer
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	netmail "net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aattwwss/ihf-referee-rules/account"
+)
+
+const (
+	maxFeedbackNameLength = 100
+	minFeedbackTextLength = 10
+	maxFeedbackTextLength = 5000
+	maxSetNameLength      = 100
+	// shareCodeBytes is the randomness of a share code, so that the links of sets cannot be guessed
+	shareCodeBytes    = 9
+	shareCodeAttempts = 5
+)
 
 type Repository interface {
 	GetAllQuestions(ctx context.Context) ([]Question, error)
+	FindQuestionsByIDs(ctx context.Context, ids []int) ([]Question, error)
 	GetQuestionByID(ctx context.Context, id int) (*Question, error)
-	GetRandomQuestion(ctx context.Context, rules []string) (*Question, error)
+	GetRandomQuestion(ctx context.Context, rules []string, excludeIDs []int) (*Question, error)
 	GetChoicesByQuestionID(ctx context.Context, questionID int) ([]Choice, error)
-	ListQuestions(ctx context.Context, rules []string, search string, lastRuleSortOrder int, lastQuestionNumber int, limit int) ([]Question, error)
-	InsertFeedback(ctx context.Context, feedback Feedback) error
+	GetAllRules(ctx context.Context) ([]Rule, error)
+	ListQuestions(ctx context.Context, rules []string, search string, after *QuestionCursor, limit int) ([]Question, error)
+	FindSearchCorrections(ctx context.Context, terms []string) (map[string]string, error)
+	InsertFeedback(ctx context.Context, feedback Feedback) (*Feedback, error)
+	ListFeedback(ctx context.Context, filter FeedbackFilter) ([]FeedbackEntity, error)
+	FindFeedbackAudits(ctx context.Context, feedbackIDs []int) ([]FeedbackAuditEntity, error)
+	CountOpenReports(ctx context.Context, questionIDs []int) (map[int]int, error)
+	UpdateFeedbackStatus(ctx context.Context, feedbackID int, userID int, isAcknowledged bool, isCompleted bool) (*FeedbackEntity, error)
+	SaveAnswer(ctx context.Context, answer Answer, reschedule func(Review) Review) error
+	GetProgress(ctx context.Context, userID int) (*Progress, error)
+	SetQuestionRead(ctx context.Context, userID int, questionID int, read bool) error
+	ClearSelections(ctx context.Context, userID int) error
+	ImportProgress(ctx context.Context, userID int, answers []Answer, readQuestionIDs []int) (bool, error)
+	FindNextReviewQuestionID(ctx context.Context, userID int) (int, bool, int, error)
+	GetPracticeStats(ctx context.Context, userID int, rules []string) ([]PracticeStatEntity, error)
+	GetRuleProgress(ctx context.Context, userID int) ([]RuleProgressEntity, error)
+	GetWeeklyAccuracy(ctx context.Context, userID int, weeks int) ([]AccuracyEntity, error)
+	InsertQuestionSet(ctx context.Context, set QuestionSet) (int, error)
+	FindQuestionSetByShareCode(ctx context.Context, shareCode string) (*QuestionSet, error)
+	ListQuestionSetsByOwnerID(ctx context.Context, ownerID int) ([]QuestionSet, error)
+	UpdateQuestionSet(ctx context.Context, shareCode string, update func(QuestionSet) (QuestionSet, error)) error
+	DeleteQuestionSet(ctx context.Context, id int) error
+	FindQuestionContent(ctx context.Context, questionID int) (*QuestionContent, error)
+	ListQuestionRevisions(ctx context.Context, questionID int) ([]QuestionRevision, error)
+	FindQuestionRevision(ctx context.Context, id int) (*QuestionRevision, error)
+	FindAnswerCorrections(ctx context.Context, questionIDs []int) (map[int]time.Time, error)
+	UpdateQuestionContent(ctx context.Context, questionID int, userID int, content QuestionContent, latestRevisionID int, revertedRevisionID *int) error
+	UseExamTicket(ctx context.Context, ticketID string, expiredBefore time.Time) (bool, error)
+}
+
+// Notifier tells people about feedback. It is called after the feedback is saved and must not block the request,
+// failures to notify are its own to handle.
+type Notifier interface {
+	// FeedbackSubmitted tells the admins about new feedback or a new report on a question
+	FeedbackSubmitted(feedback Feedback)
+	// FeedbackCompleted acknowledges to the submitter that an admin has completed the feedback
+	FeedbackCompleted(feedback Feedback)
 }
 
 type QuestionService struct {
 	repository Repository
+	notifier   Notifier
 }
 
-func NewService(repository Repository) *QuestionService {
-	return &QuestionService{repository: repository}
+func NewService(repository Repository, notifier Notifier) *QuestionService {
+	return &QuestionService{repository: repository, notifier: notifier}
 }
 
 func (s *QuestionService) GetAllQuestions(ctx context.Context) ([]Question, error) {
-	return s.repository.GetAllQuestions(ctx)
+	questions, err := s.repository.GetAllQuestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return questions, s.markAnswerCorrections(ctx, questions)
+}
+
+// GetQuestionsByIDs returns the questions with the given ids, in the order of the ids
+func (s *QuestionService) GetQuestionsByIDs(ctx context.Context, ids []int) ([]Question, error) {
+	return s.repository.FindQuestionsByIDs(ctx, ids)
+}
+
+// GetQuestionsByRules returns the questions of the given rules in their order, all questions when no rule is given
+func (s *QuestionService) GetQuestionsByRules(ctx context.Context, rules []string) ([]Question, error) {
+	questions, err := s.repository.GetAllQuestions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return questions, nil
+	}
+	return slices.DeleteFunc(questions, func(question Question) bool {
+		return !slices.Contains(rules, question.Rule.ID)
+	}), nil
 }
 
 func (s *QuestionService) GetQuestionByID(ctx context.Context, id int) (*Question, error) {
-	return s.repository.GetQuestionByID(ctx, id)
+	question, err := s.repository.GetQuestionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return question, s.markAnswerCorrection(ctx, question)
 }
 
-func (s *QuestionService) GetRandomQuestion(ctx context.Context, rules []string) (*Question, error) {
-	return s.repository.GetRandomQuestion(ctx, rules)
+// GetRandomQuestion returns a random question of the rules, all rules when none is given. Questions in excludeIDs
+// are only returned once every question of the rules is excluded.
+func (s *QuestionService) GetRandomQuestion(ctx context.Context, rules []string, excludeIDs []int) (*Question, error) {
+	question, err := s.repository.GetRandomQuestion(ctx, rules, excludeIDs)
+	if errors.Is(err, ErrQuestionNotFound) && len(excludeIDs) > 0 {
+		question, err = s.repository.GetRandomQuestion(ctx, rules, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return question, s.markAnswerCorrection(ctx, question)
 }
 
 func (s *QuestionService) GetChoicesByQuestionID(ctx context.Context, questionID int) ([]Choice, error) {
 	return s.repository.GetChoicesByQuestionID(ctx, questionID)
 }
 
-func (s *QuestionService) ListQuestions(ctx context.Context, rules []string, search string, lastRuleSortOrder int, lastQuestionNumber int, limit int) ([]Question, error) {
-	return s.repository.ListQuestions(ctx, rules, search, lastRuleSortOrder, lastQuestionNumber, limit)
+func (s *QuestionService) GetAllRules(ctx context.Context) ([]Rule, error) {
+	return s.repository.GetAllRules(ctx)
+}
+
+// ListQuestions searches the questions, replacing handball synonyms with the IHF wording
+// and suggesting a correction for misspelt words. When the search as typed finds nothing on the first page,
+// the questions for the suggested correction are returned instead.
+// cursor is the NextCursor of the previous page, empty for the first page.
+func (s *QuestionService) ListQuestions(ctx context.Context, rules []string, search string, cursor string, limit int) (*QuestionSearchResult, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive, got %d", limit)
+	}
+	var after *QuestionCursor
+	if cursor != "" {
+		var err error
+		after, err = DecodeQuestionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	search = normalizeSearch(search)
+	result, err := s.listQuestionsPage(ctx, rules, search, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	if search == "" || after != nil {
+		return result, nil
+	}
+
+	corrections, err := s.repository.FindSearchCorrections(ctx, searchTerms(search))
+	if err != nil {
+		return nil, err
+	}
+	if len(corrections) == 0 {
+		return result, nil
+	}
+	suggestion := replaceTerms(search, corrections)
+	if len(result.Questions) == 0 {
+		result, err = s.listQuestionsPage(ctx, rules, suggestion, after, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result.Suggestion = suggestion
+	return result, nil
+}
+
+// listQuestionsPage fetches one more question than the limit to know whether there is a next page
+func (s *QuestionService) listQuestionsPage(ctx context.Context, rules []string, search string, after *QuestionCursor, limit int) (*QuestionSearchResult, error) {
+	questions, err := s.repository.ListQuestions(ctx, rules, search, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	result := &QuestionSearchResult{
+		Questions: questions,
+		Search:    search,
+	}
+	if len(questions) > limit {
+		result.Questions = questions[:limit]
+		result.NextCursor = cursorFromQuestion(result.Questions[limit-1]).Encode()
+	}
+	return result, nil
 }
 
+// SubmitFeedback saves the feedback, FeedbackErrors if any of its fields is invalid
 func (s *QuestionService) SubmitFeedback(ctx context.Context, feedback Feedback) error {
-	return s.repository.InsertFeedback(ctx, feedback)
+	feedback, err := validateFeedback(feedback)
+	if err != nil {
+		return err
+	}
+	return s.insertFeedback(ctx, feedback)
+}
+
+// ReportQuestion saves a report of a problem with the question as feedback on the questions topic
+func (s *QuestionService) ReportQuestion(ctx context.Context, questionID int, report Feedback) error {
+	report.QuestionID = &questionID
+	report.Topic = "questions"
+	report, err := validateFeedback(report)
+	if err != nil {
+		return err
+	}
+	_, err = s.repository.GetQuestionByID(ctx, questionID)
+	if err != nil {
+		return err
+	}
+	return s.insertFeedback(ctx, report)
+}
+
+// insertFeedback saves the feedback and notifies the admins
+func (s *QuestionService) insertFeedback(ctx context.Context, feedback Feedback) error {
+	inserted, err := s.repository.InsertFeedback(ctx, feedback)
+	if err != nil {
+		return err
+	}
+	s.notifier.FeedbackSubmitted(*inserted)
+	return nil
+}
+
+// ListFeedback returns the feedback matching the filter with the changes of their status, the latest first.
+// Reports come with their question and the number of open reports on it.
+func (s *QuestionService) ListFeedback(ctx context.Context, filter FeedbackFilter) ([]Feedback, error) {
+	err := account.Authorize(ctx, account.PermissionManageFeedback)
+	if err != nil {
+		return nil, err
+	}
+	feedbackEntities, err := s.repository.ListFeedback(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	feedbackIDs := make([]int, 0, len(feedbackEntities))
+	var questionIDs []int
+	for _, feedbackEntity := range feedbackEntities {
+		feedbackIDs = append(feedbackIDs, feedbackEntity.ID)
+		if feedbackEntity.QuestionID != nil && !slices.Contains(questionIDs, *feedbackEntity.QuestionID) {
+			questionIDs = append(questionIDs, *feedbackEntity.QuestionID)
+		}
+	}
+	audits, err := s.repository.FindFeedbackAudits(ctx, feedbackIDs)
+	if err != nil {
+		return nil, err
+	}
+	auditMap := make(map[int][]FeedbackAuditEntity)
+	for _, audit := range audits {
+		auditMap[audit.FeedbackID] = append(auditMap[audit.FeedbackID], audit)
+	}
+	questionMap := make(map[int]*Question)
+	openReportCounts := make(map[int]int)
+	if len(questionIDs) > 0 {
+		questions, err := s.repository.FindQuestionsByIDs(ctx, questionIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range questions {
+			questionMap[questions[i].ID] = &questions[i]
+		}
+		openReportCounts, err = s.repository.CountOpenReports(ctx, questionIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	feedback := make([]Feedback, 0, len(feedbackEntities))
+	for _, feedbackEntity := range feedbackEntities {
+		item := newFeedback(feedbackEntity)
+		item.Audits = auditMap[feedbackEntity.ID]
+		if item.QuestionID != nil {
+			item.Question = questionMap[*item.QuestionID]
+			item.OpenReportCount = openReportCounts[*item.QuestionID]
+		}
+		feedback = append(feedback, item)
+	}
+	return feedback, nil
+}
+
+// SetFeedbackStatus moves the feedback to the status on behalf of the admin, a completed feedback is also acknowledged
+func (s *QuestionService) SetFeedbackStatus(ctx context.Context, userID int, feedbackID int, status FeedbackStatus) error {
+	err := account.Authorize(ctx, account.PermissionManageFeedback)
+	if err != nil {
+		return err
+	}
+	var isAcknowledged, isCompleted bool
+	switch status {
+	case FeedbackStatusNew:
+	case FeedbackStatusAcknowledged:
+		isAcknowledged = true
+	case FeedbackStatusCompleted:
+		isAcknowledged, isCompleted = true, true
+	default:
+		return ErrInvalidFeedbackStatus
+	}
+	previous, err := s.repository.UpdateFeedbackStatus(ctx, feedbackID, userID, isAcknowledged, isCompleted)
+	if err != nil {
+		return err
+	}
+	if isCompleted && !previous.IsCompleted {
+		feedback := newFeedback(*previous)
+		feedback.IsAcknowledged, feedback.IsCompleted = isAcknowledged, isCompleted
+		s.notifier.FeedbackCompleted(feedback)
+	}
+	return nil
+}
+
+// CheckAnswer grades the options selected for a question, returning the verdict of every choice
+func (s *QuestionService) CheckAnswer(ctx context.Context, questionID int, selected []string) (*AnswerResult, error) {
+	choices, err := s.repository.GetChoicesByQuestionID(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(choices) == 0 {
+		return nil, ErrQuestionNotFound
+	}
+	return GradeAnswer(questionID, choices, selected)
+}
+
+// RecordAnswer grades and saves the options selected by the user for a question
+func (s *QuestionService) RecordAnswer(ctx context.Context, userID int, questionID int, selected []string, source AnswerSource) (*Answer, error) {
+	result, err := s.CheckAnswer(ctx, questionID, selected)
+	if err != nil {
+		return nil, err
+	}
+	answer := Answer{
+		UserID:          userID,
+		QuestionID:      questionID,
+		SelectedOptions: selected,
+		IsCorrect:       result.IsCorrect,
+		Source:          source,
+	}
+	err = s.saveAnswer(ctx, answer, result)
+	if err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// saveAnswer saves a graded answer and reschedules the review of its question when the source of the answer counts
+// as a review. Selections on the home page do not, as they are saved while the user is still choosing.
+func (s *QuestionService) saveAnswer(ctx context.Context, answer Answer, result *AnswerResult) error {
+	if !answer.Source.Reschedules() {
+		return s.repository.SaveAnswer(ctx, answer, nil)
+	}
+	return s.repository.SaveAnswer(ctx, answer, func(review Review) Review {
+		return review.next(answerQuality(result), time.Now())
+	})
+}
+
+// GetAdaptiveQuestion chooses a question of the rules for the user to practise, favouring the rules the user gets
+// wrong most and the questions the user got wrong or has not seen for a while. Questions in excludeIDs, the ones
+// already practised in this session, are only chosen again once every question of the rules has been practised.
+func (s *QuestionService) GetAdaptiveQuestion(ctx context.Context, userID int, rules []string, excludeIDs []int) (*PracticeQuestion, error) {
+	stats, err := s.repository.GetPracticeStats(ctx, userID, rules)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	candidate, ok := chooseAdaptive(stats, excludeIDs, now, mathrand.Float64)
+	if !ok {
+		return nil, ErrQuestionNotFound
+	}
+	question, err := s.GetQuestionByID(ctx, candidate.stat.QuestionID)
+	if err != nil {
+		return nil, err
+	}
+	return &PracticeQuestion{
+		Question: *question,
+		Reason:   candidate.reason(question.Rule, now),
+	}, nil
+}
+
+// GetDashboard returns the progress of the user on every rule with the accuracy of the last weeks
+func (s *QuestionService) GetDashboard(ctx context.Context, userID int) (*Dashboard, error) {
+	progressEntities, err := s.repository.GetRuleProgress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	accuracyEntities, err := s.repository.GetWeeklyAccuracy(ctx, userID, trendWeeks)
+	if err != nil {
+		return nil, err
+	}
+
+	weeks := lastWeeks(time.Now(), trendWeeks)
+	dashboard := &Dashboard{Total: RuleProgress{Trend: emptyTrend(weeks)}}
+	for _, progressEntity := range progressEntities {
+		progress := RuleProgress{
+			Rule: Rule{
+				ID:        progressEntity.RuleID,
+				Name:      progressEntity.RuleName,
+				SortOrder: progressEntity.SortOrder,
+			},
+			QuestionCount:  progressEntity.QuestionCount,
+			ReadCount:      progressEntity.ReadCount,
+			AttemptedCount: progressEntity.AttemptedCount,
+			CorrectCount:   progressEntity.CorrectCount,
+			Trend:          emptyTrend(weeks),
+		}
+		for _, accuracyEntity := range accuracyEntities {
+			if accuracyEntity.RuleID != progress.Rule.ID {
+				continue
+			}
+			for i := range weeks {
+				if weeks[i].Equal(accuracyEntity.WeekStart) {
+					progress.Trend[i].AnswerCount += accuracyEntity.AnswerCount
+					progress.Trend[i].CorrectCount += accuracyEntity.CorrectCount
+					dashboard.Total.Trend[i].AnswerCount += accuracyEntity.AnswerCount
+					dashboard.Total.Trend[i].CorrectCount += accuracyEntity.CorrectCount
+				}
+			}
+		}
+		dashboard.Total.QuestionCount += progress.QuestionCount
+		dashboard.Total.ReadCount += progress.ReadCount
+		dashboard.Total.AttemptedCount += progress.AttemptedCount
+		dashboard.Total.CorrectCount += progress.CorrectCount
+		dashboard.Rules = append(dashboard.Rules, progress)
+	}
+	return dashboard, nil
+}
+
+// GetNextReview returns the question the user should review next, due questions first and then questions
+// never answered. ErrNoReviewDue when every question has been answered and none is due.
+func (s *QuestionService) GetNextReview(ctx context.Context, userID int) (*ReviewQuestion, error) {
+	questionID, isNew, dueCount, err := s.repository.FindNextReviewQuestionID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	question, err := s.GetQuestionByID(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	return &ReviewQuestion{
+		Question: *question,
+		IsNew:    isNew,
+		DueCount: dueCount,
+	}, nil
+}
+
+func (s *QuestionService) GetProgress(ctx context.Context, userID int) (*Progress, error) {
+	return s.repository.GetProgress(ctx, userID)
+}
+
+func (s *QuestionService) SetQuestionRead(ctx context.Context, userID int, questionID int, read bool) error {
+	return s.repository.SetQuestionRead(ctx, userID, questionID, read)
+}
+
+func (s *QuestionService) ClearSelections(ctx context.Context, userID int) error {
+	return s.repository.ClearSelections(ctx, userID)
+}
+
+// ImportLocalProgress saves the progress kept in the browser before the user logged in.
+// It is only imported once per user, later imports are ignored.
+func (s *QuestionService) ImportLocalProgress(ctx context.Context, userID int, local LocalProgress) error {
+	questions, err := s.repository.GetAllQuestions(ctx)
+	if err != nil {
+		return err
+	}
+	choiceQuestions := make(map[int]Question)
+	ruleQuestionNumbers := make(map[string]int)
+	for _, question := range questions {
+		for _, choice := range question.Choices {
+			choiceQuestions[choice.ID] = question
+		}
+		ruleQuestionNumbers[question.RuleQuestionNumber] = question.ID
+	}
+
+	selections := make(map[int][]string)
+	for key, checked := range local.ChoiceCheckMap {
+		// keys are "choice<choice id>-<option>"
+		idAndOption := strings.SplitN(strings.TrimPrefix(key, "choice"), "-", 2)
+		if !checked || len(idAndOption) != 2 {
+			continue
+		}
+		choiceID, err := strconv.Atoi(idAndOption[0])
+		if err != nil {
+			continue
+		}
+		question, ok := choiceQuestions[choiceID]
+		if !ok {
+			continue
+		}
+		selections[question.ID] = append(selections[question.ID], idAndOption[1])
+	}
+	var answers []Answer
+	for _, question := range questions {
+		selected, ok := selections[question.ID]
+		if !ok {
+			continue
+		}
+		slices.Sort(selected)
+		result, err := GradeAnswer(question.ID, question.Choices, selected)
+		if err != nil {
+			continue
+		}
+		answers = append(answers, Answer{
+			UserID:          userID,
+			QuestionID:      question.ID,
+			SelectedOptions: selected,
+			IsCorrect:       result.IsCorrect,
+			Source:          AnswerSourceHome,
+		})
+	}
+
+	var readQuestionIDs []int
+	for key, checked := range local.ReadCheckMap {
+		// keys are "question-<rule question number>"
+		questionID, ok := ruleQuestionNumbers[strings.TrimPrefix(key, "question-")]
+		if checked && ok {
+			readQuestionIDs = append(readQuestionIDs, questionID)
+		}
+	}
+	_, err = s.repository.ImportProgress(ctx, userID, answers, readQuestionIDs)
+	return err
+}
+
+// GradeExam grades the options selected for each question of an exam, questions without selections are graded as blank.
+// The ticket of the exam is used up, ErrInvalidExam is returned when it was already submitted.
+// The answers are recorded for logged in users, userID is 0 for anonymous exams.
+func (s *QuestionService) GradeExam(ctx context.Context, userID int, ticketID string, questionIDs []int, selections map[int][]string) (*ExamResult, error) {
+	seen := make(map[int]bool, len(questionIDs))
+	for _, questionID := range questionIDs {
+		if seen[questionID] {
+			return nil, ErrDuplicateExamQuestion
+		}
+		seen[questionID] = true
+	}
+	questions, err := s.repository.FindQuestionsByIDs(ctx, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) != len(questionIDs) {
+		return nil, ErrQuestionNotFound
+	}
+	examResult := &ExamResult{}
+	for _, question := range questions {
+		result, err := GradeAnswer(question.ID, question.Choices, selections[question.ID])
+		if err != nil {
+			return nil, err
+		}
+		if result.IsCorrect {
+			examResult.CorrectCount++
+		}
+		examResult.Questions = append(examResult.Questions, ExamQuestionResult{
+			Question: question,
+			Result:   *result,
+		})
+	}
+	isUnused, err := s.repository.UseExamTicket(ctx, ticketID, time.Now().Add(-examTicketTTL))
+	if err != nil {
+		return nil, err
+	}
+	if !isUnused {
+		return nil, ErrInvalidExam
+	}
+	if userID == 0 {
+		return examResult, nil
+	}
+	for _, question := range examResult.Questions {
+		err = s.saveAnswer(ctx, Answer{
+			UserID:          userID,
+			QuestionID:      question.Question.ID,
+			SelectedOptions: selections[question.Question.ID],
+			IsCorrect:       question.Result.IsCorrect,
+			Source:          AnswerSourceExam,
+		}, &question.Result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return examResult, nil
+}
+
+func (s *QuestionService) ListQuestionSets(ctx context.Context, userID int) ([]QuestionSet, error) {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return nil, err
+	}
+	return s.repository.ListQuestionSetsByOwnerID(ctx, userID)
+}
+
+// CreateQuestionSet creates an empty question set owned by the user with a new share code and returns the share code
+func (s *QuestionService) CreateQuestionSet(ctx context.Context, userID int, name string, description string) (string, error) {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return "", err
+	}
+	name, err = validateSetName(name)
+	if err != nil {
+		return "", err
+	}
+	for range shareCodeAttempts {
+		shareCode, err := newShareCode()
+		if err != nil {
+			return "", err
+		}
+		_, err = s.repository.InsertQuestionSet(ctx, QuestionSet{
+			Name:        name,
+			Description: strings.TrimSpace(description),
+			OwnerID:     userID,
+			ShareCode:   shareCode,
+		})
+		if errors.Is(err, errShareCodeTaken) {
+			continue
+		}
+		return shareCode, err
+	}
+	return "", errShareCodeTaken
+}
+
+// GetQuestionSet returns the question set of the share code with its questions in their order, anyone with the code can see it
+func (s *QuestionService) GetQuestionSet(ctx context.Context, shareCode string) (*QuestionSet, []Question, error) {
+	set, err := s.repository.FindQuestionSetByShareCode(ctx, shareCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	questions, err := s.repository.FindQuestionsByIDs(ctx, set.QuestionIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return set, questions, s.markAnswerCorrections(ctx, questions)
+}
+
+func (s *QuestionService) UpdateQuestionSet(ctx context.Context, userID int, shareCode string, name string, description string) error {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return err
+	}
+	name, err = validateSetName(name)
+	if err != nil {
+		return err
+	}
+	return s.updateOwnedQuestionSet(ctx, userID, shareCode, func(set QuestionSet) (QuestionSet, error) {
+		set.Name = name
+		set.Description = strings.TrimSpace(description)
+		return set, nil
+	})
+}
+
+// AddToQuestionSet appends the questions to the end of the set, skipping the questions already in it
+func (s *QuestionService) AddToQuestionSet(ctx context.Context, userID int, shareCode string, questionIDs []int) error {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return err
+	}
+	questions, err := s.repository.FindQuestionsByIDs(ctx, questionIDs)
+	if err != nil {
+		return err
+	}
+	if len(questions) != len(questionIDs) {
+		return ErrQuestionNotFound
+	}
+	return s.updateOwnedQuestionSet(ctx, userID, shareCode, func(set QuestionSet) (QuestionSet, error) {
+		for _, questionID := range questionIDs {
+			if !slices.Contains(set.QuestionIDs, questionID) {
+				set.QuestionIDs = append(set.QuestionIDs, questionID)
+			}
+		}
+		return set, nil
+	})
+}
+
+func (s *QuestionService) RemoveFromQuestionSet(ctx context.Context, userID int, shareCode string, questionID int) error {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return err
+	}
+	return s.updateOwnedQuestionSet(ctx, userID, shareCode, func(set QuestionSet) (QuestionSet, error) {
+		idx := slices.Index(set.QuestionIDs, questionID)
+		if idx < 0 {
+			return set, ErrQuestionNotFound
+		}
+		set.QuestionIDs = slices.Delete(set.QuestionIDs, idx, idx+1)
+		return set, nil
+	})
+}
+
+// MoveInQuestionSet moves the question by offset places in the set, -1 to move it up by one.
+// The question stays at the start or the end of the set when moved past it.
+func (s *QuestionService) MoveInQuestionSet(ctx context.Context, userID int, shareCode string, questionID int, offset int) error {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return err
+	}
+	return s.updateOwnedQuestionSet(ctx, userID, shareCode, func(set QuestionSet) (QuestionSet, error) {
+		idx := slices.Index(set.QuestionIDs, questionID)
+		if idx < 0 {
+			return set, ErrQuestionNotFound
+		}
+		target := min(max(idx+offset, 0), len(set.QuestionIDs)-1)
+		set.QuestionIDs = slices.Insert(slices.Delete(set.QuestionIDs, idx, idx+1), target, questionID)
+		return set, nil
+	})
+}
+
+func (s *QuestionService) DeleteQuestionSet(ctx context.Context, userID int, shareCode string) error {
+	err := account.Authorize(ctx, account.PermissionManageSets)
+	if err != nil {
+		return err
+	}
+	set, err := s.ownedQuestionSet(ctx, userID, shareCode)
+	if err != nil {
+		return err
+	}
+	return s.repository.DeleteQuestionSet(ctx, set.ID)
+}
+
+// updateOwnedQuestionSet applies update to the question set of the share code, locked against concurrent changes,
+// ErrQuestionSetNotFound if the user does not own it
+func (s *QuestionService) updateOwnedQuestionSet(ctx context.Context, userID int, shareCode string, update func(QuestionSet) (QuestionSet, error)) error {
+	return s.repository.UpdateQuestionSet(ctx, shareCode, func(set QuestionSet) (QuestionSet, error) {
+		if !set.IsOwner(userID) {
+			return set, ErrQuestionSetNotFound
+		}
+		return update(set)
+	})
+}
+
+// ownedQuestionSet returns the question set of the share code, ErrQuestionSetNotFound if the user does not own it
+func (s *QuestionService) ownedQuestionSet(ctx context.Context, userID int, shareCode string) (*QuestionSet, error) {
+	set, err := s.repository.FindQuestionSetByShareCode(ctx, shareCode)
+	if err != nil {
+		return nil, err
+	}
+	if !set.IsOwner(userID) {
+		return nil, ErrQuestionSetNotFound
+	}
+	return set, nil
+}
+
+// GetQuestionEditor returns the question with its content for admins to edit and its revisions
+func (s *QuestionService) GetQuestionEditor(ctx context.Context, questionID int) (*QuestionEditor, error) {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return nil, err
+	}
+	question, err := s.GetQuestionByID(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.repository.FindQuestionContent(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.repository.ListQuestionRevisions(ctx, questionID)
+	if err != nil {
+		return nil, err
+	}
+	return &QuestionEditor{Question: *question, Content: *content, Revisions: revisions}, nil
+}
+
+// EditQuestion saves the content of the question edited by the admin as a new revision.
+// latestRevisionID is the latest revision when the admin loaded the question, to reject edits made in the meantime.
+func (s *QuestionService) EditQuestion(ctx context.Context, userID int, questionID int, content QuestionContent, latestRevisionID int) error {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return err
+	}
+	content, err = validateQuestionContent(content)
+	if err != nil {
+		return err
+	}
+	return s.repository.UpdateQuestionContent(ctx, questionID, userID, content, latestRevisionID, nil)
+}
+
+// RevertQuestionRevision restores the content of the question from before the revision, saved as a new revision
+func (s *QuestionService) RevertQuestionRevision(ctx context.Context, userID int, questionID int, revisionID int, latestRevisionID int) error {
+	err := account.Authorize(ctx, account.PermissionManageContent)
+	if err != nil {
+		return err
+	}
+	revision, err := s.repository.FindQuestionRevision(ctx, revisionID)
+	if err != nil {
+		return err
+	}
+	if revision.QuestionID != questionID {
+		return ErrRevisionNotFound
+	}
+	return s.repository.UpdateQuestionContent(ctx, questionID, userID, revision.Before, latestRevisionID, &revision.ID)
+}
+
+// markAnswerCorrections sets when the answers of the questions were last corrected
+func (s *QuestionService) markAnswerCorrections(ctx context.Context, questions []Question) error {
+	questionIDs := make([]int, 0, len(questions))
+	for _, question := range questions {
+		questionIDs = append(questionIDs, question.ID)
+	}
+	corrections, err := s.repository.FindAnswerCorrections(ctx, questionIDs)
+	if err != nil {
+		return err
+	}
+	for i := range questions {
+		if correctedAt, ok := corrections[questions[i].ID]; ok {
+			questions[i].AnswerCorrectedAt = &correctedAt
+		}
+	}
+	return nil
+}
+
+func (s *QuestionService) markAnswerCorrection(ctx context.Context, question *Question) error {
+	questions := []Question{*question}
+	err := s.markAnswerCorrections(ctx, questions)
+	if err != nil {
+		return err
+	}
+	question.AnswerCorrectedAt = questions[0].AnswerCorrectedAt
+	return nil
+}
+
+// validateQuestionContent trims the content and checks that it has a text, choices with distinct options and texts,
+// and a correct choice. Blank references are dropped.
+func validateQuestionContent(content QuestionContent) (QuestionContent, error) {
+	content.Text = strings.TrimSpace(content.Text)
+	if content.Text == "" {
+		return content, ErrEmptyQuestionText
+	}
+	choices := make([]ChoiceContent, 0, len(content.Choices))
+	for _, choice := range content.Choices {
+		choice.Option = strings.TrimSpace(choice.Option)
+		choice.Text = strings.TrimSpace(choice.Text)
+		if choice.Option == "" || choice.Text == "" {
+			return content, ErrInvalidChoices
+		}
+		if _, ok := (QuestionContent{Choices: choices}).Choice(choice.Option); ok {
+			return content, ErrInvalidChoices
+		}
+		choices = append(choices, choice)
+	}
+	slices.SortFunc(choices, func(a, b ChoiceContent) int {
+		return strings.Compare(a.Option, b.Option)
+	})
+	content.Choices = choices
+	if len(content.Answers()) == 0 {
+		return content, ErrNoAnswer
+	}
+	var references []string
+	for _, reference := range content.References {
+		if reference = strings.TrimSpace(reference); reference != "" {
+			references = append(references, reference)
+		}
+	}
+	content.References = references
+	return content, nil
+}
+
+func newFeedback(feedbackEntity FeedbackEntity) Feedback {
+	feedback := Feedback{
+		ID:             feedbackEntity.ID,
+		Name:           feedbackEntity.Name,
+		Email:          feedbackEntity.Email,
+		Topic:          feedbackEntity.Topic,
+		Text:           feedbackEntity.Text,
+		IsAcknowledged: feedbackEntity.IsAcknowledged,
+		IsCompleted:    feedbackEntity.IsCompleted,
+		CreatedAt:      feedbackEntity.CreatedAt,
+		QuestionID:     feedbackEntity.QuestionID,
+	}
+	if feedbackEntity.Category != nil {
+		feedback.Category = ReportCategory(*feedbackEntity.Category)
+	}
+	return feedback
+}
+
+// validateFeedback trims the fields of the feedback and checks them, returning FeedbackErrors with every invalid field
+func validateFeedback(feedback Feedback) (Feedback, error) {
+	errs := FeedbackErrors{}
+	feedback.Name = strings.TrimSpace(feedback.Name)
+	if feedback.Name == "" || utf8.RuneCountInString(feedback.Name) > maxFeedbackNameLength {
+		errs["name"] = fmt.Sprintf("Enter a name of at most %d characters.", maxFeedbackNameLength)
+	} else if strings.ContainsFunc(feedback.Name, unicode.IsControl) {
+		// the name is put in the subject of the emails to the admins
+		errs["name"] = "Enter a name on a single line."
+	}
+	address, err := netmail.ParseAddress(strings.TrimSpace(feedback.Email))
+	if err != nil || address.Name != "" {
+		errs["email"] = "Enter a valid email address."
+	} else {
+		feedback.Email = address.Address
+	}
+	if !slices.Contains(FeedbackTopics, feedback.Topic) {
+		errs["topic"] = "Choose a topic."
+	}
+	if feedback.QuestionID != nil && !slices.Contains(ReportCategories, feedback.Category) {
+		errs["category"] = "Choose what is wrong with the question."
+	}
+	feedback.Text = strings.TrimSpace(feedback.Text)
+	if length := utf8.RuneCountInString(feedback.Text); length < minFeedbackTextLength || length > maxFeedbackTextLength {
+		errs["text"] = fmt.Sprintf("Write between %d and %d characters.", minFeedbackTextLength, maxFeedbackTextLength)
+	}
+	if len(errs) > 0 {
+		return feedback, errs
+	}
+	return feedback, nil
+}
+
+func validateSetName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxSetNameLength {
+		return "", ErrInvalidSetName
+	}
+	return name, nil
+}
+
+// newShareCode returns a random code that is safe to use in a link
+func newShareCode() (string, error) {
+	b := make([]byte, shareCodeBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the timestamp, a dot and the body,
+	// keyed with the shared secret
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	// IDHeader is the same across the retries of an event, so that receivers can drop duplicates
+	IDHeader = "X-Webhook-ID"
+
+	maxAttempts    = 4
+	initialBackoff = time.Second
+	requestTimeout = 10 * time.Second
+)
+
+// Event is the JSON body posted to the webhooks
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Sender posts events to every configured url, retrying failed deliveries with exponential backoff
+type Sender struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+	// backoff is the wait before the first retry, doubled for every following one
+	backoff time.Duration
+}
+
+func NewSender(urls []string, secret string) *Sender {
+	return &Sender{
+		urls:    urls,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: requestTimeout},
+		backoff: initialBackoff,
+	}
+}
+
+// Send delivers the event to every url, returning the errors of the urls that failed every attempt
+func (s *Sender) Send(ctx context.Context, eventType string, data any) error {
+	if len(s.urls) == 0 {
+		return nil
+	}
+	id, err := newEventID()
+	if err != nil {
+		return err
+	}
+	event := Event{ID: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, url := range s.urls {
+		err = s.deliver(ctx, url, event, body)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver posts the body to the url until it is accepted, the error is permanent or the attempts run out
+func (s *Sender) deliver(ctx context.Context, url string, event Event, body []byte) error {
+	backoff := s.backoff
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var retry bool
+		retry, err = s.post(ctx, url, event, body)
+		if err == nil || !retry || attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// post makes one delivery attempt, a retry is worth it after a network error, a server error or too many requests
+func (s *Sender) post(ctx context.Context, url string, event Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	// the timestamp is signed with the body so that a captured request cannot be replayed later
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, event.ID)
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(s.secret, timestamp, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// Sign returns the hex HMAC-SHA256 of the timestamp and the body, receivers compute it to verify a delivery
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivery is a request received by the test receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// receiver answers the deliveries with the statuses in turn, the last one for every following delivery
+type receiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []delivery
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, delivery{header: req.Header.Clone(), body: body, at: time.Now()})
+	status := r.statuses[min(len(r.deliveries), len(r.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deliveries
+}
+
+func newTestSender(urls []string, backoff time.Duration) *Sender {
+	sender := NewSender(urls, "webhook-secret")
+	sender.backoff = backoff
+	return sender
+}
+
+func TestSign(t *testing.T) {
+	// computed with: printf '1700000000.{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	want := "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := Sign([]byte("secret"), "1700000000", []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
+
+func TestSendSignsDelivery(t *testing.T) {
+	rcv := &receiver{statuses: []int{http.StatusNoContent}}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	err := newTestSender([]string{server.URL}, time.Millisecond).Send(context.Background(), "feedback.created", map[string]int{"id": 7})
+	if err != nil {
+		t.Fatalf("Send() error = %s", err)
+	}
+	deliveries := rcv.received()
+	if len(deliveries) != 1 {
+		t.Fatalf("received %d deliveries, want 1", len(deliveries))
+	}
+	d := deliveries[0]
+	timestamp := d.header.Get(TimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Errorf("%s = %q, want a unix timestamp", TimestampHeader, timestamp)
+	}
+	if got, want := d.header.Get(SignatureHeader), "sha256="+Sign([]byte("webhook-secret"), timestamp, d.body); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if got := d.header.Get(EventHeader); got != "feedback.created" {
+		t.Errorf("%s = %q, want feedback.created", EventHeader, got)
+	}
+	if got := d.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var event Event
+	err = json.Unmarshal(d.body, &event)
+	if err != nil {
+		t.Fatalf("body is not an event: %s", err)
+	}
+	if event.Type != "feedback.created" || event.ID == "" || event.ID != d.header.Get(IDHeader) {
+		t.Errorf("event = %+v with %s %q", event, IDHeader, d.header.Get(IDHeader))
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+	tests := []struct {
+		name       string
+		statuses   []int
+		deliveries int
+		wantErr    bool
+	}{
+		{"accepted at once", []int{http.StatusOK}, 1, false},
+		{"server error then accepted", []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, 3, false},
+		{"too many requests then accepted", []int{http.StatusTooManyRequests, http.StatusAccepted}, 2, false},
+		{"server error on every attempt", []int{http.StatusInternalServerError}, maxAttempts, true},
+		{"client error is not retried", []int{http.StatusBadRequest}, 1, true},
+		{"unauthorized is not retried", []int{http.StatusServiceUnavailable, http.StatusUnauthorized}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := &receiver{statuses: tt.statuses}
+			server := httptest.NewServer(rcv)
+			defer server.Close()
+
+			err := newTestSender([]string{server.URL}, backoff).Send(context.Background(), "feedback.created", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, want error %t", err, tt.wantErr)
+			}
+			deliveries := rcv.received()
+			if len(deliveries) != tt.deliveries {
+				t.Fatalf("received %d deliveries, want %d", len(deliveries), tt.deliveries)
+			}
+			wait := backoff
+			for i := 1; i < len(deliveries); i++ {
+				if gap := deliveries[i].at.Sub(deliveries[i-1].at); gap < wait {
+					t.Errorf("retry %d after %s, want a backoff of at least %s", i, gap, wait)
+				}
+				if deliveries[i].header.Get(IDHeader) != deliveries[0].header.Get(IDHeader) {
+					t.Errorf("retry %d has another event id", i)
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+func TestSendStopsRetryingWhenCancelled(t *testing.T) {
+	rcv := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := newTestSender([]string{server.URL}, time.Hour).Send(ctx, "feedback.created", nil)
+	if err == nil {
+		t.Fatal("Send() error = nil, want the delivery to fail")
+	}
+	if len(rcv.received()) != 1 {
+		t.Errorf("received %d deliveries, want 1 before the context is done", len(rcv.received()))
+	}
+}
+
+func TestSendDeliversToEveryURL(t *testing.T) {
+	failing := &receiver{statuses: []int{http.StatusNotFound}}
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+	accepting := &receiver{statuses: []int{http.StatusOK}}
+	acceptingServer := httptest.NewServer(accepting)
+	defer acceptingServer.Close()
+
+	err := newTestSender([]string{failingServer.URL, acceptingServer.URL}, time.Millisecond).Send(context.Background(), "feedback.created", nil)
+	if err == nil {
+		t.Error("Send() error = nil, want the error of the failing url")
+	}
+	if len(failing.received()) != 1 || len(accepting.received()) != 1 {
+		t.Errorf("received %d and %d deliveries, want 1 each", len(failing.received()), len(accepting.received()))
+	}
+}